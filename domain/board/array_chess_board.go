@@ -2,6 +2,9 @@ package board
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 
 	logging "jesus_chess/domain/logging"
@@ -11,14 +14,67 @@ type ArrayChessBoard struct {
 	board           [BoardHeight][BoardWidth]*Piece
 	sideToMove      Color
 	moveHistory     []Move
+	stateHistory    []boardState
 	castlingRights  CastlingRights
+	enPassantSquare Square
+	hasEnPassant    bool
+	halfmoveClock   int
+	fullmoveNumber  int
+	hash            uint64
 	kingSquares     map[Color]Square
-	attackedSquares map[Color][]Square
+	validationMode  ValidationMode
 	logger          *logging.Logger
 }
 
+// boardState holds the parts of the position that cannot be recomputed
+// from a move when it is undone.
+type boardState struct {
+	castlingRights  CastlingRights
+	enPassantSquare Square
+	hasEnPassant    bool
+	halfmoveClock   int
+	hash            uint64
+}
+
+var (
+	knightOffsets    = [8][2]int{{2, 1}, {2, -1}, {-2, 1}, {-2, -1}, {1, 2}, {1, -2}, {-1, 2}, {-1, -2}}
+	kingOffsets      = [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	bishopDirections = [4][2]int{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}}
+	rookDirections   = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	queenDirections  = [8][2]int{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}, {1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	promotionPieces  = [4]PieceName{Queen, Rook, Bishop, Knight}
+)
+
+// pieceTable holds one shared instance of every piece so that the board and
+// generated moves can refer to pieces without allocating.
+var pieceTable = [2][6]Piece{
+	{{Pawn, White}, {Knight, White}, {Bishop, White}, {Rook, White}, {Queen, White}, {King, White}},
+	{{Pawn, Black}, {Knight, Black}, {Bishop, Black}, {Rook, Black}, {Queen, Black}, {King, Black}},
+}
+
+func staticPiece(name PieceName, color Color) *Piece {
+	colorIndex := 0
+	if color == Black {
+		colorIndex = 1
+	}
+	switch name {
+	case Pawn:
+		return &pieceTable[colorIndex][0]
+	case Knight:
+		return &pieceTable[colorIndex][1]
+	case Bishop:
+		return &pieceTable[colorIndex][2]
+	case Rook:
+		return &pieceTable[colorIndex][3]
+	case Queen:
+		return &pieceTable[colorIndex][4]
+	default:
+		return &pieceTable[colorIndex][5]
+	}
+}
+
 func NewArrayChessBoard(logger *logging.Logger) *ArrayChessBoard {
-	cb := &ArrayChessBoard{sideToMove: White, logger: logger}
+	cb := &ArrayChessBoard{sideToMove: White, fullmoveNumber: 1, logger: logger}
 
 	for rank := 0; rank < BoardHeight; rank++ {
 		for file := 0; file < BoardWidth; file++ {
@@ -28,15 +84,15 @@ func NewArrayChessBoard(logger *logging.Logger) *ArrayChessBoard {
 
 	// Place pawns
 	for i := 0; i < BoardWidth; i++ {
-		cb.board[1][i] = &Piece{Pawn, White}
-		cb.board[BoardHeight-2][i] = &Piece{Pawn, Black}
+		cb.board[1][i] = staticPiece(Pawn, White)
+		cb.board[BoardHeight-2][i] = staticPiece(Pawn, Black)
 	}
 
 	// Place other pieces
 	pieceNames := []PieceName{Rook, Knight, Bishop, Queen, King, Bishop, Knight, Rook}
 	for i, pieceName := range pieceNames {
-		cb.board[0][i] = &Piece{pieceName, White}
-		cb.board[BoardHeight-1][i] = &Piece{pieceName, Black}
+		cb.board[0][i] = staticPiece(pieceName, White)
+		cb.board[BoardHeight-1][i] = staticPiece(pieceName, Black)
 	}
 
 	// Initialize castling rights
@@ -52,212 +108,190 @@ func NewArrayChessBoard(logger *logging.Logger) *ArrayChessBoard {
 	cb.kingSquares[White] = Square{Rank: 0, File: 4}
 	cb.kingSquares[Black] = Square{Rank: 7, File: 4}
 
-	// Initialize attacked squares
-	cb.attackedSquares = make(map[Color][]Square)
-	cb.attackedSquares[White] = []Square{}
-	cb.attackedSquares[Black] = []Square{}
-	for rank := 0; rank < BoardHeight; rank++ {
-		for file := 0; file < BoardWidth; file++ {
-			piece := cb.board[rank][file]
-			if piece == nil {
-				continue
-			}
-			cb.attackedSquares[piece.Color] = append(cb.attackedSquares[piece.Color], cb.getAttackedSquares(Square{Rank: rank, File: file})...)
-		}
-	}
-
 	// Initialize move history
 	cb.moveHistory = []Move{}
+	cb.stateHistory = []boardState{}
 
 	// Set the initial side to move
 	cb.sideToMove = White
+	cb.hash = cb.computeHash()
 
 	return cb
 }
 
-func (cb *ArrayChessBoard) getAttackedSquares(sq Square) []Square {
-	piece := cb.board[sq.Rank][sq.File]
-	if piece == nil {
-		return []Square{}
+func (cb *ArrayChessBoard) validateAttackedSquare(sq Square, attackingColor Color) bool {
+	if !onBoard(sq) {
+		return false
 	}
-
-	attackedSquares := []Square{}
-
-	if piece.Name == Pawn {
-		attackedSquares = cb.getPawnAttackedSquares(sq, piece.Color)
+	if cb.board[sq.Rank][sq.File] != nil && cb.board[sq.Rank][sq.File].Color == attackingColor {
+		return false
 	}
+	return true
+}
 
-	if piece.Name == Knight {
-		attackedSquares = cb.getKnightAttackedSquares(sq)
-	}
+func onBoard(sq Square) bool {
+	return sq.Rank >= 0 && sq.Rank < BoardHeight && sq.File >= 0 && sq.File < BoardWidth
+}
 
-	if piece.Name == Bishop || piece.Name == Queen {
-		attackedSquares = cb.getDiagonallyAttackedSquares(sq)
-	}
+func (cb *ArrayChessBoard) PieceAt(sq Square) *Piece {
+	return cb.board[sq.Rank][sq.File]
+}
 
-	if piece.Name == Rook || piece.Name == Queen {
-		attackedSquares = cb.getHorizontallyAndVerticallyAttackedSquares(sq)
+func (cb *ArrayChessBoard) IsOccupied(sq Square) bool {
+	if !onBoard(sq) {
+		return false
 	}
+	return cb.board[sq.Rank][sq.File] != nil
+}
 
-	if piece.Name == King {
-		attackedSquares = []Square{
-			{Rank: sq.Rank + 1, File: sq.File},
-			{Rank: sq.Rank - 1, File: sq.File},
-			{Rank: sq.Rank, File: sq.File + 1},
-			{Rank: sq.Rank, File: sq.File - 1},
-			{Rank: sq.Rank + 1, File: sq.File + 1},
-			{Rank: sq.Rank + 1, File: sq.File - 1},
-			{Rank: sq.Rank - 1, File: sq.File + 1},
-			{Rank: sq.Rank - 1, File: sq.File - 1},
-		}
-	}
+func (cb *ArrayChessBoard) SideToMove() Color {
+	return cb.sideToMove
+}
 
-	validAttackedSquares := []Square{}
-	for _, attackedSquare := range attackedSquares {
-		if cb.validateAttackedSquare(attackedSquare, piece.Color) {
-			validAttackedSquares = append(validAttackedSquares, attackedSquare)
-		}
-	}
+func (cb *ArrayChessBoard) CastlingRights() CastlingRights {
+	return cb.castlingRights
+}
 
-	return validAttackedSquares
+func (cb *ArrayChessBoard) GenerateLegalMoves() []Move {
+	var buf MoveList
+	cb.GenerateMoves(&buf)
+	moves := make([]Move, buf.Len())
+	copy(moves, buf.Moves())
+	return moves
 }
 
-func (cb *ArrayChessBoard) getPawnAttackedSquares(sq Square, color Color) []Square {
-	if color == White {
-		return []Square{
-			{Rank: sq.Rank + 1, File: sq.File - 1},
-			{Rank: sq.Rank + 1, File: sq.File + 1},
-		}
-	} else {
-		return []Square{
-			{Rank: sq.Rank - 1, File: sq.File - 1},
-			{Rank: sq.Rank - 1, File: sq.File + 1},
-		}
-	}
+// generationMode selects which class of moves a generator emits. Captures
+// include every promotion and en passant; quiets are everything else.
+type generationMode int
+
+const (
+	generateAll generationMode = iota
+	generateCaptures
+	generateQuiets
+)
+
+// GenerateMoves fills buf with every legal move for the side to move. The
+// buffer is cleared first; nothing is allocated.
+func (cb *ArrayChessBoard) GenerateMoves(buf *MoveList) {
+	cb.generateLegalMoves(buf, generateAll)
 }
 
-func (cb *ArrayChessBoard) getKnightAttackedSquares(sq Square) []Square {
-	return []Square{
-		{Rank: sq.Rank + 2, File: sq.File + 1},
-		{Rank: sq.Rank + 2, File: sq.File - 1},
-		{Rank: sq.Rank - 2, File: sq.File + 1},
-		{Rank: sq.Rank - 2, File: sq.File - 1},
-		{Rank: sq.Rank + 1, File: sq.File + 2},
-		{Rank: sq.Rank + 1, File: sq.File - 2},
-		{Rank: sq.Rank - 1, File: sq.File + 2},
-		{Rank: sq.Rank - 1, File: sq.File - 2},
-	}
+// GenerateCaptures fills buf with the legal captures and promotions.
+func (cb *ArrayChessBoard) GenerateCaptures(buf *MoveList) {
+	cb.generateLegalMoves(buf, generateCaptures)
 }
 
-func (cb *ArrayChessBoard) getDiagonallyAttackedSquares(sq Square) []Square {
-	attackedSquares := []Square{}
-	currentSquare := sq
-	for currentSquare.Rank < BoardHeight && currentSquare.File < BoardWidth {
-		currentSquare.Rank++
-		currentSquare.File++
-		attackedSquares = append(attackedSquares, currentSquare)
-		if cb.IsOccupied(currentSquare) {
-			break
-		}
-	}
-	currentSquare = sq
-	for currentSquare.Rank >= 0 && currentSquare.File >= 0 {
-		currentSquare.Rank--
-		currentSquare.File--
-		attackedSquares = append(attackedSquares, currentSquare)
-		if cb.IsOccupied(currentSquare) {
-			break
-		}
-	}
-	return attackedSquares
+// GenerateQuiets fills buf with the legal moves that neither capture nor
+// promote, castling included.
+func (cb *ArrayChessBoard) GenerateQuiets(buf *MoveList) {
+	cb.generateLegalMoves(buf, generateQuiets)
 }
 
-func (cb *ArrayChessBoard) getHorizontallyAndVerticallyAttackedSquares(sq Square) []Square {
-	attackedSquares := []Square{}
-	currentSquare := sq
-	for currentSquare.Rank < BoardHeight {
-		currentSquare.Rank++
-		attackedSquares = append(attackedSquares, currentSquare)
-		if cb.IsOccupied(currentSquare) {
-			break
-		}
+// GenerateEvasions fills buf with the legal moves out of check. Only king
+// moves are tried against a double check; otherwise the other pieces may
+// only capture the checker or block its line. The buffer is left empty
+// when the side to move is not in check.
+func (cb *ArrayChessBoard) GenerateEvasions(buf *MoveList) {
+	buf.Clear()
+	color := cb.sideToMove
+	kingSquare, ok := cb.kingSquares[color]
+	if !ok {
+		return
 	}
-	currentSquare = sq
-	for currentSquare.Rank >= 0 {
-		currentSquare.Rank--
-		attackedSquares = append(attackedSquares, currentSquare)
-		if cb.IsOccupied(currentSquare) {
-			break
-		}
+	checkers, checker := cb.findCheckers(kingSquare, OppositeColor(color))
+	if checkers == 0 {
+		return
 	}
-	currentSquare = sq
-	for currentSquare.File < BoardWidth {
-		currentSquare.File++
-		attackedSquares = append(attackedSquares, currentSquare)
-		if cb.IsOccupied(currentSquare) {
-			break
+
+	var targets [BoardHeight][BoardWidth]bool
+	if checkers == 1 {
+		targets[checker.Rank][checker.File] = true
+		if name := cb.board[checker.Rank][checker.File].Name; name == Bishop || name == Rook || name == Queen {
+			dr, df := sign(checker.Rank-kingSquare.Rank), sign(checker.File-kingSquare.File)
+			for sq := (Square{Rank: kingSquare.Rank + dr, File: kingSquare.File + df}); sq != checker; sq = (Square{Rank: sq.Rank + dr, File: sq.File + df}) {
+				targets[sq.Rank][sq.File] = true
+			}
 		}
 	}
-	currentSquare = sq
-	for currentSquare.File >= 0 {
-		currentSquare.File--
-		attackedSquares = append(attackedSquares, currentSquare)
-		if cb.IsOccupied(currentSquare) {
-			break
+
+	cb.generatePseudoLegalMoves(buf, generateAll)
+	kept := 0
+	for i := 0; i < buf.count; i++ {
+		move := buf.moves[i]
+		if move.Piece.Name == King && !move.IsCastling ||
+			targets[move.To.Rank][move.To.File] ||
+			move.IsEnPassant && checkers == 1 && (Square{Rank: move.From.Rank, File: move.To.File}) == checker {
+			buf.moves[kept] = move
+			kept++
 		}
 	}
-	return attackedSquares
+	buf.count = kept
+	cb.removeIllegalMoves(buf)
 }
 
-func (cb *ArrayChessBoard) validateAttackedSquare(sq Square, attackingColor Color) bool {
-	if sq.Rank < 0 || sq.Rank >= BoardHeight {
-		return false
-	}
-	if sq.File < 0 || sq.File >= BoardWidth {
-		return false
-	}
-	if cb.board[sq.Rank][sq.File] != nil && cb.board[sq.Rank][sq.File].Color == attackingColor {
-		return false
+// GenerateQuietChecks fills buf with the legal quiet moves that give check.
+func (cb *ArrayChessBoard) GenerateQuietChecks(buf *MoveList) {
+	cb.generateLegalMoves(buf, generateQuiets)
+	enemy := OppositeColor(cb.sideToMove)
+	kept := 0
+	for i := 0; i < buf.count; i++ {
+		move := buf.moves[i]
+		cb.MakeMove(move)
+		givesCheck := cb.kingAttacked(enemy)
+		cb.UndoMove()
+		if givesCheck {
+			buf.moves[kept] = move
+			kept++
+		}
 	}
-	return true
+	buf.count = kept
 }
 
-func (cb *ArrayChessBoard) PieceAt(sq Square) *Piece {
-	return cb.board[sq.Rank][sq.File]
+func (cb *ArrayChessBoard) generateLegalMoves(buf *MoveList, mode generationMode) {
+	cb.generatePseudoLegalMoves(buf, mode)
+	cb.removeIllegalMoves(buf)
 }
 
-func (cb *ArrayChessBoard) IsOccupied(sq Square) bool {
-	if sq.Rank < 0 || sq.Rank >= BoardHeight {
-		return false
-	}
-	if sq.File < 0 || sq.File >= BoardWidth {
-		return false
-	}
-	return cb.board[sq.Rank][sq.File] != nil
+func (cb *ArrayChessBoard) generatePseudoLegalMoves(buf *MoveList, mode generationMode) {
+	buf.Clear()
+	cb.generatePawnMoves(buf, mode)
+	cb.generateKnightMoves(buf, mode)
+	cb.generateSlidingPieceMoves(buf, mode, Bishop)
+	cb.generateSlidingPieceMoves(buf, mode, Rook)
+	cb.generateSlidingPieceMoves(buf, mode, Queen)
+	cb.generateKingMoves(buf, mode)
 }
 
-func (cb *ArrayChessBoard) SideToMove() Color {
-	return cb.sideToMove
-}
-
-func (cb *ArrayChessBoard) CastlingRights() CastlingRights {
-	return cb.castlingRights
+// addMove appends move to buf if it belongs to the requested class.
+func (cb *ArrayChessBoard) addMove(buf *MoveList, mode generationMode, move Move) {
+	tactical := move.CapturedPiece != nil || move.Promotion != nil
+	if mode == generateCaptures && !tactical || mode == generateQuiets && tactical {
+		return
+	}
+	buf.Add(move)
 }
 
-func (cb *ArrayChessBoard) GenerateLegalMoves() []Move {
-	moves := []Move{}
-
-	moves = append(moves, cb.generateLegalPawnMoves()...)
-	moves = append(moves, cb.generateLegalKnightMoves()...)
-	moves = append(moves, cb.generateLegalBishopMoves()...)
-	moves = append(moves, cb.generateLegalRookMoves()...)
-	moves = append(moves, cb.generateLegalQueenMoves()...)
-	moves = append(moves, cb.generateLegalKingMoves()...)
-	return moves
+// removeIllegalMoves drops the moves in buf that leave the mover's king in
+// check, keeping the remaining moves in order.
+func (cb *ArrayChessBoard) removeIllegalMoves(buf *MoveList) {
+	color := cb.sideToMove
+	kept := 0
+	for i := 0; i < buf.count; i++ {
+		move := buf.moves[i]
+		if cb.MakeMove(move) != nil {
+			continue
+		}
+		legal := !cb.kingAttacked(color)
+		cb.UndoMove()
+		if legal {
+			buf.moves[kept] = move
+			kept++
+		}
+	}
+	buf.count = kept
 }
 
-func (cb *ArrayChessBoard) generateLegalPawnMoves() []Move {
-	moves := []Move{}
+func (cb *ArrayChessBoard) generatePawnMoves(buf *MoveList, mode generationMode) {
 	color := cb.sideToMove
 	direction := 1
 	startRank := 1
@@ -270,348 +304,714 @@ func (cb *ArrayChessBoard) generateLegalPawnMoves() []Move {
 	for file := 0; file < BoardWidth; file++ {
 		for rank := 0; rank < BoardHeight; rank++ {
 			piece := cb.board[rank][file]
-			if piece != nil && piece.Name == Pawn && piece.Color == color {
-				from := Square{Rank: rank, File: file}
-				forward := Square{Rank: rank + direction, File: file}
-				if !cb.IsOccupied(forward) && forward.Rank >= 0 && forward.Rank < BoardHeight {
-					if forward.Rank == promotionRank {
-						promotions := []PieceName{Queen, Rook, Bishop, Knight}
-						for _, promo := range promotions {
-							moves = append(moves, Move{From: from, To: forward, Piece: *piece, Promotion: &Piece{Name: promo, Color: color}, PreviousCastlingRights: cb.castlingRights})
-						}
-					} else {
-						moves = append(moves, Move{From: from, To: forward, Piece: *piece, PreviousCastlingRights: cb.castlingRights})
-					}
-					if rank == startRank {
-						twoForward := Square{Rank: rank + 2*direction, File: file}
-						if !cb.IsOccupied(twoForward) {
-							moves = append(moves, Move{From: from, To: twoForward, Piece: *piece, PreviousCastlingRights: cb.castlingRights})
-						}
+			if piece == nil || piece.Name != Pawn || piece.Color != color {
+				continue
+			}
+			from := Square{Rank: rank, File: file}
+			forward := Square{Rank: rank + direction, File: file}
+			if onBoard(forward) && !cb.IsOccupied(forward) {
+				cb.addPawnMove(buf, mode, Move{From: from, To: forward, Piece: *piece, PreviousCastlingRights: cb.castlingRights}, promotionRank)
+				if rank == startRank {
+					twoForward := Square{Rank: rank + 2*direction, File: file}
+					if !cb.IsOccupied(twoForward) {
+						cb.addMove(buf, mode, Move{From: from, To: twoForward, Piece: *piece, PreviousCastlingRights: cb.castlingRights})
 					}
 				}
-				attacks := cb.getPawnAttackedSquares(from, color)
-				for _, target := range attacks {
-					if target.Rank < 0 || target.Rank >= BoardHeight || target.File < 0 || target.File >= BoardWidth {
-						continue
-					}
-					targetPiece := cb.PieceAt(target)
-					if targetPiece != nil && targetPiece.Color != color {
-						moves = append(moves, Move{From: from, To: target, Piece: *piece, CapturedPiece: targetPiece, PreviousCastlingRights: cb.castlingRights})
-					}
+			}
+			for _, df := range [2]int{-1, 1} {
+				target := Square{Rank: rank + direction, File: file + df}
+				if !onBoard(target) {
+					continue
+				}
+				targetPiece := cb.board[target.Rank][target.File]
+				if targetPiece != nil && targetPiece.Color != color {
+					cb.addPawnMove(buf, mode, Move{From: from, To: target, Piece: *piece, CapturedPiece: targetPiece, PreviousCastlingRights: cb.castlingRights}, promotionRank)
+				} else if targetPiece == nil && cb.hasEnPassant && target == cb.enPassantSquare {
+					cb.addMove(buf, mode, Move{From: from, To: target, Piece: *piece, IsEnPassant: true, CapturedPiece: staticPiece(Pawn, OppositeColor(color)), PreviousCastlingRights: cb.castlingRights})
 				}
 			}
 		}
 	}
-	return moves
 }
 
-func (cb *ArrayChessBoard) generateLegalKnightMoves() []Move {
-	moves := []Move{}
+// addPawnMove adds move, expanding it into the four promotions when the pawn
+// reaches the last rank.
+func (cb *ArrayChessBoard) addPawnMove(buf *MoveList, mode generationMode, move Move, promotionRank int) {
+	if move.To.Rank != promotionRank {
+		cb.addMove(buf, mode, move)
+		return
+	}
+	for _, promo := range promotionPieces {
+		move.Promotion = staticPiece(promo, move.Piece.Color)
+		cb.addMove(buf, mode, move)
+	}
+}
+
+func (cb *ArrayChessBoard) generateKnightMoves(buf *MoveList, mode generationMode) {
+	cb.generateStepMoves(buf, mode, Knight, knightOffsets[:])
+}
+
+func (cb *ArrayChessBoard) generateStepMoves(buf *MoveList, mode generationMode, name PieceName, offsets [][2]int) {
 	color := cb.sideToMove
 	for rank := 0; rank < BoardHeight; rank++ {
 		for file := 0; file < BoardWidth; file++ {
 			piece := cb.board[rank][file]
-			if piece != nil && piece.Name == Knight && piece.Color == color {
-				from := Square{Rank: rank, File: file}
-				for _, to := range cb.getKnightAttackedSquares(from) {
-					if !cb.validateAttackedSquare(to, color) {
-						continue
-					}
-					move := Move{From: from, To: to, Piece: *piece, PreviousCastlingRights: cb.castlingRights}
-					targetPiece := cb.PieceAt(to)
-					if targetPiece != nil && targetPiece.Color != color {
-						move.CapturedPiece = targetPiece
-					}
-					moves = append(moves, move)
+			if piece == nil || piece.Name != name || piece.Color != color {
+				continue
+			}
+			from := Square{Rank: rank, File: file}
+			for _, offset := range offsets {
+				to := Square{Rank: rank + offset[0], File: file + offset[1]}
+				if !cb.validateAttackedSquare(to, color) {
+					continue
 				}
+				cb.addMove(buf, mode, Move{From: from, To: to, Piece: *piece, CapturedPiece: cb.board[to.Rank][to.File], PreviousCastlingRights: cb.castlingRights})
 			}
 		}
 	}
-	return moves
 }
 
-func (cb *ArrayChessBoard) generateLegalBishopMoves() []Move {
-	return cb.generateSlidingPieceMoves(Bishop)
-}
-
-func (cb *ArrayChessBoard) generateLegalRookMoves() []Move {
-	return cb.generateSlidingPieceMoves(Rook)
-}
-
-func (cb *ArrayChessBoard) generateLegalQueenMoves() []Move {
-	return cb.generateSlidingPieceMoves(Queen)
-}
-
-func (cb *ArrayChessBoard) generateSlidingPieceMoves(name PieceName) []Move {
-	moves := []Move{}
+func (cb *ArrayChessBoard) generateSlidingPieceMoves(buf *MoveList, mode generationMode, name PieceName) {
 	color := cb.sideToMove
-	dirs := [][]int{}
+	var dirs [][2]int
 	if name == Bishop {
-		dirs = [][]int{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}}
+		dirs = bishopDirections[:]
 	} else if name == Rook {
-		dirs = [][]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+		dirs = rookDirections[:]
 	} else {
-		dirs = [][]int{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}, {1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+		dirs = queenDirections[:]
 	}
 	for rank := 0; rank < BoardHeight; rank++ {
 		for file := 0; file < BoardWidth; file++ {
 			piece := cb.board[rank][file]
-			if piece != nil && piece.Name == name && piece.Color == color {
-				from := Square{Rank: rank, File: file}
-				for _, dir := range dirs {
-					for i := 1; i < BoardHeight; i++ {
-						sq := Square{Rank: rank + dir[0]*i, File: file + dir[1]*i}
-						if sq.Rank < 0 || sq.Rank >= BoardHeight || sq.File < 0 || sq.File >= BoardWidth {
-							break
-						}
-						targetPiece := cb.PieceAt(sq)
-						if targetPiece == nil {
-							moves = append(moves, Move{From: from, To: sq, Piece: *piece, PreviousCastlingRights: cb.castlingRights})
-						} else {
-							if targetPiece.Color != color {
-								moves = append(moves, Move{From: from, To: sq, Piece: *piece, CapturedPiece: targetPiece, PreviousCastlingRights: cb.castlingRights})
-							}
-							break
-						}
+			if piece == nil || piece.Name != name || piece.Color != color {
+				continue
+			}
+			from := Square{Rank: rank, File: file}
+			for _, dir := range dirs {
+				for i := 1; i < BoardHeight; i++ {
+					sq := Square{Rank: rank + dir[0]*i, File: file + dir[1]*i}
+					if !onBoard(sq) {
+						break
 					}
+					targetPiece := cb.board[sq.Rank][sq.File]
+					if targetPiece == nil {
+						cb.addMove(buf, mode, Move{From: from, To: sq, Piece: *piece, PreviousCastlingRights: cb.castlingRights})
+						continue
+					}
+					if targetPiece.Color != color {
+						cb.addMove(buf, mode, Move{From: from, To: sq, Piece: *piece, CapturedPiece: targetPiece, PreviousCastlingRights: cb.castlingRights})
+					}
+					break
 				}
 			}
 		}
 	}
-	return moves
 }
 
-func (cb *ArrayChessBoard) generateLegalKingMoves() []Move {
-	moves := []Move{}
+func (cb *ArrayChessBoard) generateKingMoves(buf *MoveList, mode generationMode) {
 	color := cb.sideToMove
-	for rank := 0; rank < BoardHeight; rank++ {
-		for file := 0; file < BoardWidth; file++ {
-			piece := cb.board[rank][file]
-			if piece != nil && piece.Name == King && piece.Color == color {
-				from := Square{Rank: rank, File: file}
-				for _, to := range cb.getAttackedSquares(from) {
-					if !cb.validateAttackedSquare(to, color) {
-						continue
-					}
-					move := Move{From: from, To: to, Piece: *piece}
-					targetPiece := cb.PieceAt(to)
-					if targetPiece != nil && targetPiece.Color != color {
-						move.CapturedPiece = targetPiece
-					}
-					moves = append(moves, move)
-				}
-				if cb.InCheck(color) {
-					continue
-				}
+	from, ok := cb.kingSquares[color]
+	if !ok {
+		return
+	}
+	cb.generateStepMoves(buf, mode, King, kingOffsets[:])
 
-				r := from.Rank
-				rights := cb.castlingRights
-
-				// King-side castling
-				if (color == White && rights.WhiteKingSide) || (color == Black && rights.BlackKingSide) {
-					squares := []Square{{Rank: r, File: 5}, {Rank: r, File: 6}}
-					if cb.isCastlingPathClearAndSafe(squares, color) && cb.board[r][7] != nil && cb.board[r][7].Name == Rook {
-						moves = append(moves, Move{
-							From:                   from,
-							To:                     Square{Rank: r, File: 6},
-							Piece:                  *piece,
-							IsCastling:             true,
-							PreviousCastlingRights: cb.castlingRights,
-						})
-					}
-				}
+	if mode == generateCaptures || cb.kingAttacked(color) {
+		return
+	}
+	piece := cb.board[from.Rank][from.File]
+	r := from.Rank
+	rights := cb.castlingRights
+	enemy := OppositeColor(color)
+
+	// King-side castling
+	if (color == White && rights.WhiteKingSide) || (color == Black && rights.BlackKingSide) {
+		if cb.isOwnRook(Square{Rank: r, File: 7}, color) &&
+			cb.isCastlingPathClearAndSafe(Square{Rank: r, File: 5}, enemy) &&
+			cb.isCastlingPathClearAndSafe(Square{Rank: r, File: 6}, enemy) {
+			cb.addMove(buf, mode, Move{
+				From:                   from,
+				To:                     Square{Rank: r, File: 6},
+				Piece:                  *piece,
+				IsCastling:             true,
+				PreviousCastlingRights: cb.castlingRights,
+			})
+		}
+	}
 
-				// Queen-side castling
-				if (color == White && rights.WhiteQueenSide) || (color == Black && rights.BlackQueenSide) {
-					squares := []Square{{Rank: r, File: 3}, {Rank: r, File: 2}, {Rank: r, File: 1}}
-					if cb.isCastlingPathClearAndSafe(squares[:2], color) && cb.board[r][0] != nil && cb.board[r][0].Name == Rook {
-						// We include file 1 in clearance but not for check
-						if !cb.IsOccupied(squares[2]) {
-							moves = append(moves, Move{
-								From:                   from,
-								To:                     Square{Rank: r, File: 2},
-								Piece:                  *piece,
-								IsCastling:             true,
-								PreviousCastlingRights: cb.castlingRights,
-							})
-						}
-					}
+	// Queen-side castling
+	if (color == White && rights.WhiteQueenSide) || (color == Black && rights.BlackQueenSide) {
+		// The b-file square must be empty but may be attacked
+		if cb.isOwnRook(Square{Rank: r, File: 0}, color) &&
+			!cb.IsOccupied(Square{Rank: r, File: 1}) &&
+			cb.isCastlingPathClearAndSafe(Square{Rank: r, File: 3}, enemy) &&
+			cb.isCastlingPathClearAndSafe(Square{Rank: r, File: 2}, enemy) {
+			cb.addMove(buf, mode, Move{
+				From:                   from,
+				To:                     Square{Rank: r, File: 2},
+				Piece:                  *piece,
+				IsCastling:             true,
+				PreviousCastlingRights: cb.castlingRights,
+			})
+		}
+	}
+}
+
+func (cb *ArrayChessBoard) isOwnRook(sq Square, color Color) bool {
+	piece := cb.board[sq.Rank][sq.File]
+	return piece != nil && piece.Name == Rook && piece.Color == color
+}
+
+func (cb *ArrayChessBoard) isCastlingPathClearAndSafe(sq Square, enemy Color) bool {
+	return !cb.IsOccupied(sq) && !cb.squareAttackedBy(sq, enemy)
+}
+
+// squareAttackedBy reports whether any piece of the attacker's colour
+// attacks sq, looking outwards from the square instead of scanning the board.
+func (cb *ArrayChessBoard) squareAttackedBy(sq Square, attacker Color) bool {
+	pawnRank := sq.Rank - 1
+	if attacker == Black {
+		pawnRank = sq.Rank + 1
+	}
+	for _, df := range [2]int{-1, 1} {
+		if cb.hasPiece(Square{Rank: pawnRank, File: sq.File + df}, Pawn, attacker) {
+			return true
+		}
+	}
+	for _, offset := range knightOffsets {
+		if cb.hasPiece(Square{Rank: sq.Rank + offset[0], File: sq.File + offset[1]}, Knight, attacker) {
+			return true
+		}
+	}
+	for _, offset := range kingOffsets {
+		if cb.hasPiece(Square{Rank: sq.Rank + offset[0], File: sq.File + offset[1]}, King, attacker) {
+			return true
+		}
+	}
+	return cb.rayAttackedBy(sq, attacker, bishopDirections[:], Bishop) ||
+		cb.rayAttackedBy(sq, attacker, rookDirections[:], Rook)
+}
+
+// rayAttackedBy reports whether the first piece along any of dirs is an
+// attacker's slider or queen.
+func (cb *ArrayChessBoard) rayAttackedBy(sq Square, attacker Color, dirs [][2]int, slider PieceName) bool {
+	for _, dir := range dirs {
+		current := Square{Rank: sq.Rank + dir[0], File: sq.File + dir[1]}
+		for onBoard(current) {
+			piece := cb.board[current.Rank][current.File]
+			if piece != nil {
+				if piece.Color == attacker && (piece.Name == slider || piece.Name == Queen) {
+					return true
 				}
+				break
 			}
+			current.Rank += dir[0]
+			current.File += dir[1]
 		}
 	}
-	return moves
+	return false
 }
 
-func (cb *ArrayChessBoard) isCastlingPathClearAndSafe(squares []Square, color Color) bool {
-	for _, sq := range squares {
-		if cb.IsOccupied(sq) {
-			return false
+// AttackersTo returns the squares of the pieces of color attacking sq, as
+// bits set at their Index. The removed squares, given the same way, are
+// treated as empty, so the sliders behind them attack through.
+func (cb *ArrayChessBoard) AttackersTo(sq Square, color Color, removed uint64) uint64 {
+	var attackers uint64
+	present := func(from Square, name PieceName) bool {
+		return cb.hasPiece(from, name, color) && removed&(uint64(1)<<from.Index()) == 0
+	}
+	pawnRank := sq.Rank - 1
+	if color == Black {
+		pawnRank = sq.Rank + 1
+	}
+	for _, df := range [2]int{-1, 1} {
+		if from := (Square{Rank: pawnRank, File: sq.File + df}); present(from, Pawn) {
+			attackers |= uint64(1) << from.Index()
 		}
-		// Temporarily move king to check for attacks
-		original := cb.sideToMove
-		cb.sideToMove = color
-		inCheck := cb.squareAttackedBy(sq, oppositeColor(color))
-		cb.sideToMove = original
-		if inCheck {
-			return false
+	}
+	for _, offset := range knightOffsets {
+		if from := (Square{Rank: sq.Rank + offset[0], File: sq.File + offset[1]}); present(from, Knight) {
+			attackers |= uint64(1) << from.Index()
 		}
 	}
-	return true
+	for _, offset := range kingOffsets {
+		if from := (Square{Rank: sq.Rank + offset[0], File: sq.File + offset[1]}); present(from, King) {
+			attackers |= uint64(1) << from.Index()
+		}
+	}
+	for _, dir := range queenDirections {
+		slider := PieceName(Rook)
+		if dir[0] != 0 && dir[1] != 0 {
+			slider = Bishop
+		}
+		current := Square{Rank: sq.Rank + dir[0], File: sq.File + dir[1]}
+		for onBoard(current) {
+			piece := cb.board[current.Rank][current.File]
+			if piece != nil && removed&(uint64(1)<<current.Index()) == 0 {
+				if piece.Color == color && (piece.Name == slider || piece.Name == Queen) {
+					attackers |= uint64(1) << current.Index()
+				}
+				break
+			}
+			current.Rank += dir[0]
+			current.File += dir[1]
+		}
+	}
+	return attackers
 }
 
-// Simple method to check if a square is attacked
-func (cb *ArrayChessBoard) squareAttackedBy(sq Square, attacker Color) bool {
-	for rank := 0; rank < BoardHeight; rank++ {
-		for file := 0; file < BoardWidth; file++ {
-			piece := cb.board[rank][file]
-			if piece != nil && piece.Color == attacker {
-				from := Square{Rank: rank, File: file}
-				attacks := cb.getAttackedSquares(from)
-				for _, a := range attacks {
-					if a == sq {
-						return true
-					}
+// findCheckers counts the attacker's pieces that attack sq and returns the
+// square of one of them.
+func (cb *ArrayChessBoard) findCheckers(sq Square, attacker Color) (int, Square) {
+	count := 0
+	var found Square
+	record := func(from Square) {
+		count++
+		found = from
+	}
+	pawnRank := sq.Rank - 1
+	if attacker == Black {
+		pawnRank = sq.Rank + 1
+	}
+	for _, df := range [2]int{-1, 1} {
+		if from := (Square{Rank: pawnRank, File: sq.File + df}); cb.hasPiece(from, Pawn, attacker) {
+			record(from)
+		}
+	}
+	for _, offset := range knightOffsets {
+		if from := (Square{Rank: sq.Rank + offset[0], File: sq.File + offset[1]}); cb.hasPiece(from, Knight, attacker) {
+			record(from)
+		}
+	}
+	for _, dir := range queenDirections {
+		slider := PieceName(Rook)
+		if dir[0] != 0 && dir[1] != 0 {
+			slider = Bishop
+		}
+		current := Square{Rank: sq.Rank + dir[0], File: sq.File + dir[1]}
+		for onBoard(current) {
+			piece := cb.board[current.Rank][current.File]
+			if piece != nil {
+				if piece.Color == attacker && (piece.Name == slider || piece.Name == Queen) {
+					record(current)
 				}
+				break
 			}
+			current.Rank += dir[0]
+			current.File += dir[1]
 		}
 	}
-	return false
+	return count, found
 }
 
-func (cb *ArrayChessBoard) InCheck(color Color) bool {
-	kingSquare := cb.findKing(color)
-	if kingSquare == nil {
+func sign(x int) int {
+	if x > 0 {
+		return 1
+	}
+	if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+func (cb *ArrayChessBoard) hasPiece(sq Square, name PieceName, color Color) bool {
+	if !onBoard(sq) {
 		return false
 	}
+	piece := cb.board[sq.Rank][sq.File]
+	return piece != nil && piece.Name == name && piece.Color == color
+}
 
-	inCheck := cb.squareAttackedBy(*kingSquare, oppositeColor(color))
-	return inCheck
+func (cb *ArrayChessBoard) kingAttacked(color Color) bool {
+	kingSquare, ok := cb.kingSquares[color]
+	if !ok {
+		return false
+	}
+	return cb.squareAttackedBy(kingSquare, OppositeColor(color))
 }
 
-func (cb *ArrayChessBoard) findKing(color Color) *Square {
-	for rank := 0; rank < BoardHeight; rank++ {
-		for file := 0; file < BoardWidth; file++ {
-			piece := cb.board[rank][file]
-			if piece != nil && piece.Name == King && piece.Color == color {
-				return &Square{Rank: rank, File: file}
+func (cb *ArrayChessBoard) InCheck(color Color) bool {
+	return cb.kingAttacked(color)
+}
+
+// normalizeMove fills in the fields of move that follow from the current
+// position, so callers such as the UCI handler only need to supply the
+// squares and the promotion piece.
+func (cb *ArrayChessBoard) normalizeMove(move Move) (Move, error) {
+	if !onBoard(move.From) || !onBoard(move.To) {
+		return Move{}, fmt.Errorf("move out of bounds: %v to %v", move.From, move.To)
+	}
+	piece := cb.board[move.From.Rank][move.From.File]
+	if piece == nil {
+		return Move{}, fmt.Errorf("no piece on square rank %d, file %d", move.From.Rank, move.From.File)
+	}
+	if piece.Color != cb.sideToMove {
+		return Move{}, fmt.Errorf("piece on square rank %d, file %d does not belong to side to move", move.From.Rank, move.From.File)
+	}
+
+	move.Piece = *piece
+	move.CapturedPiece = cb.board[move.To.Rank][move.To.File]
+	move.IsCastling = piece.Name == King && (move.To.File-move.From.File == 2 || move.From.File-move.To.File == 2)
+	move.IsEnPassant = false
+	move.PreviousCastlingRights = cb.castlingRights
+
+	if piece.Name == Pawn {
+		if move.From.File != move.To.File && move.CapturedPiece == nil {
+			if !cb.hasEnPassant || move.To != cb.enPassantSquare {
+				return Move{}, fmt.Errorf("pawn capture to empty square rank %d, file %d", move.To.Rank, move.To.File)
+			}
+			move.IsEnPassant = true
+			move.CapturedPiece = staticPiece(Pawn, OppositeColor(piece.Color))
+		}
+		if move.To.Rank == 0 || move.To.Rank == BoardHeight-1 {
+			if move.Promotion == nil {
+				return Move{}, fmt.Errorf("promotion piece required for pawn move to rank %d", move.To.Rank)
 			}
+			move.Promotion = staticPiece(move.Promotion.Name, piece.Color)
+		} else {
+			move.Promotion = nil
 		}
+	} else {
+		move.Promotion = nil
 	}
-	return nil
+
+	return move, nil
 }
 
 func (cb *ArrayChessBoard) MakeMove(move Move) error {
+	move, err := cb.normalizeMove(move)
+	if err != nil {
+		return err
+	}
+
+	cb.stateHistory = append(cb.stateHistory, boardState{
+		castlingRights:  cb.castlingRights,
+		enPassantSquare: cb.enPassantSquare,
+		hasEnPassant:    cb.hasEnPassant,
+		halfmoveClock:   cb.halfmoveClock,
+		hash:            cb.hash,
+	})
+	cb.moveHistory = append(cb.moveHistory, move)
+
+	color := move.Piece.Color
+	cb.hash ^= castlingKey(cb.castlingRights)
+	if cb.hasEnPassant {
+		cb.hash ^= zobristEnPassant[cb.enPassantSquare.File]
+	}
+	cb.hash ^= pieceKey(&move.Piece, move.From)
+	if move.CapturedPiece != nil {
+		if move.IsEnPassant {
+			cb.hash ^= pieceKey(move.CapturedPiece, Square{Rank: move.From.Rank, File: move.To.File})
+		} else {
+			cb.hash ^= pieceKey(move.CapturedPiece, move.To)
+		}
+	}
+	if move.Promotion != nil {
+		cb.hash ^= pieceKey(move.Promotion, move.To)
+	} else {
+		cb.hash ^= pieceKey(&move.Piece, move.To)
+	}
 	cb.board[move.To.Rank][move.To.File] = cb.board[move.From.Rank][move.From.File]
 	cb.board[move.From.Rank][move.From.File] = nil
-	cb.moveHistory = append(cb.moveHistory, move)
-	cb.sideToMove = oppositeColor(cb.sideToMove)
 	if move.IsCastling {
+		rook := staticPiece(Rook, color)
 		if move.To.File == 2 { // Queen-side castling
 			cb.board[move.From.Rank][0] = nil
-			cb.board[move.From.Rank][3] = &Piece{Rook, cb.sideToMove}
+			cb.board[move.From.Rank][3] = rook
+			cb.hash ^= pieceKey(rook, Square{Rank: move.From.Rank, File: 0}) ^ pieceKey(rook, Square{Rank: move.From.Rank, File: 3})
 		} else if move.To.File == 6 { // King-side castling
 			cb.board[move.From.Rank][7] = nil
-			cb.board[move.From.Rank][5] = &Piece{Rook, cb.sideToMove}
+			cb.board[move.From.Rank][5] = rook
+			cb.hash ^= pieceKey(rook, Square{Rank: move.From.Rank, File: 7}) ^ pieceKey(rook, Square{Rank: move.From.Rank, File: 5})
 		}
 	}
 	if move.Promotion != nil {
 		cb.board[move.To.Rank][move.To.File] = move.Promotion
 	}
 	if move.IsEnPassant {
-		if cb.sideToMove == White {
-			cb.board[move.From.Rank][move.To.File] = nil
-		} else {
-			cb.board[move.From.Rank+1][move.To.File] = nil
+		cb.board[move.From.Rank][move.To.File] = nil
+	}
+	if move.Piece.Name == King {
+		cb.kingSquares[color] = move.To
+	}
+
+	cb.hasEnPassant = false
+	if move.Piece.Name == Pawn && (move.To.Rank-move.From.Rank == 2 || move.From.Rank-move.To.Rank == 2) {
+		// Only record the square when an enemy pawn could actually capture
+		enemy := OppositeColor(color)
+		if cb.hasPiece(Square{Rank: move.To.Rank, File: move.To.File - 1}, Pawn, enemy) ||
+			cb.hasPiece(Square{Rank: move.To.Rank, File: move.To.File + 1}, Pawn, enemy) {
+			cb.enPassantSquare = Square{Rank: (move.From.Rank + move.To.Rank) / 2, File: move.From.File}
+			cb.hasEnPassant = true
 		}
 	}
+
+	if move.Piece.Name == Pawn || move.CapturedPiece != nil {
+		cb.halfmoveClock = 0
+	} else {
+		cb.halfmoveClock++
+	}
+	if color == Black {
+		cb.fullmoveNumber++
+	}
+
 	cb.updateCastlingRights(move)
+	cb.sideToMove = OppositeColor(color)
+
+	cb.hash ^= castlingKey(cb.castlingRights)
+	if cb.hasEnPassant {
+		cb.hash ^= zobristEnPassant[cb.enPassantSquare.File]
+	}
+	cb.hash ^= zobristSide
 
 	return nil
 }
 
+// updateCastlingRights clears the rights of any king or rook whose home
+// square was left or captured on.
 func (cb *ArrayChessBoard) updateCastlingRights(move Move) {
 	if cb.castlingRights == (CastlingRights{false, false, false, false}) {
 		return
 	}
-	if move.Piece.Name != King && move.Piece.Name != Rook {
-		return
-	}
-	if move.Piece.Name == King {
-		if move.Piece.Color == White {
+	for _, sq := range [2]Square{move.From, move.To} {
+		switch sq {
+		case Square{Rank: 0, File: 4}:
 			cb.castlingRights.WhiteKingSide = false
 			cb.castlingRights.WhiteQueenSide = false
-		} else {
+		case Square{Rank: 0, File: 0}:
+			cb.castlingRights.WhiteQueenSide = false
+		case Square{Rank: 0, File: 7}:
+			cb.castlingRights.WhiteKingSide = false
+		case Square{Rank: 7, File: 4}:
 			cb.castlingRights.BlackKingSide = false
 			cb.castlingRights.BlackQueenSide = false
-		}
-	}
-	if move.Piece.Name == Rook {
-		if move.Piece.Color == White {
-			if move.From.File == 0 {
-				cb.castlingRights.WhiteQueenSide = false
-			} else if move.From.File == 7 {
-				cb.castlingRights.WhiteKingSide = false
-			}
-		} else {
-			if move.From.File == 0 {
-				cb.castlingRights.BlackQueenSide = false
-			} else if move.From.File == 7 {
-				cb.castlingRights.BlackKingSide = false
-			}
+		case Square{Rank: 7, File: 0}:
+			cb.castlingRights.BlackQueenSide = false
+		case Square{Rank: 7, File: 7}:
+			cb.castlingRights.BlackKingSide = false
 		}
 	}
 }
 
-func oppositeColor(color Color) Color {
+// OppositeColor returns the other side's color.
+func OppositeColor(color Color) Color {
 	if color == White {
 		return Black
 	}
 	return White
 }
 
+// Display returns a plain text diagram of the board from white's side, with
+// black pieces in lower case, rank and file labels and the FEN underneath.
 func (cb *ArrayChessBoard) Display() string {
-	var result string
+	var result strings.Builder
 	for rank := BoardHeight - 1; rank >= 0; rank-- {
+		result.WriteString(fmt.Sprintf("%d ", rank+1))
 		for file := 0; file < BoardWidth; file++ {
 			piece := cb.board[rank][file]
 			if piece == nil {
-				result += ". "
+				result.WriteString(". ")
 			} else {
-				result += string(piece.Name) + " "
+				result.WriteString(piece.Symbol() + " ")
 			}
 		}
-		result += "\n"
+		result.WriteString("\n")
 	}
-	result += "\n"
-	result += fmt.Sprintf("Side to move: %s\n", cb.sideToMove)
-	result += fmt.Sprintf("Castling rights: %v\n", cb.castlingRights)
+	result.WriteString("  a b c d e f g h\n")
+	result.WriteString("\n")
+	result.WriteString(fmt.Sprintf("FEN: %s\n", cb.FEN()))
 
-	return result
+	return result.String()
 }
 
 func (cb *ArrayChessBoard) IsMoveLegal(move Move) bool {
+	_, legal := cb.LegalMove(move)
+	return legal
+}
+
+// LegalMove checks a move known only by its squares and promotion, such as
+// one from a transposition table, without generating the moves of the
+// position. The move must take a piece of the side to move along a path the
+// piece can travel and not leave its king in check. Castling, en passant and
+// promotions are looked up among the generated moves instead. The move is
+// returned as the generator would produce it.
+func (cb *ArrayChessBoard) LegalMove(move Move) (Move, bool) {
+	if !onBoard(move.From) || !onBoard(move.To) || move.From == move.To {
+		return Move{}, false
+	}
+	piece := cb.board[move.From.Rank][move.From.File]
+	if piece == nil || piece.Color != cb.sideToMove {
+		return Move{}, false
+	}
+	target := cb.board[move.To.Rank][move.To.File]
+	if target != nil && target.Color == piece.Color {
+		return Move{}, false
+	}
+
+	df := move.To.File - move.From.File
+	special := move.Promotion != nil ||
+		piece.Name == King && (df == 2 || df == -2) ||
+		piece.Name == Pawn && (move.To.Rank == 0 || move.To.Rank == BoardHeight-1 || df != 0 && target == nil)
+	if special {
+		var buf MoveList
+		cb.GenerateMoves(&buf)
+		for _, legal := range buf.Moves() {
+			if legal.Equal(move) {
+				return legal, true
+			}
+		}
+		return Move{}, false
+	}
+	if !cb.canReach(piece, move.From, move.To, target != nil) {
+		return Move{}, false
+	}
+
+	move = Move{From: move.From, To: move.To, Piece: *piece, CapturedPiece: target, PreviousCastlingRights: cb.castlingRights}
+	if cb.MakeMove(move) != nil {
+		return Move{}, false
+	}
+	legal := !cb.kingAttacked(piece.Color)
+	cb.UndoMove()
+	return move, legal
+}
+
+// canReach reports whether piece on from moves to to, capturing if capture
+// is set, by the way the piece moves and with nothing in its path. Castling,
+// en passant and promotions are left to the generator.
+func (cb *ArrayChessBoard) canReach(piece *Piece, from, to Square, capture bool) bool {
+	dr, df := to.Rank-from.Rank, to.File-from.File
+	switch piece.Name {
+	case Pawn:
+		direction, startRank := 1, 1
+		if piece.Color == Black {
+			direction, startRank = -1, 6
+		}
+		if capture {
+			return dr == direction && (df == 1 || df == -1)
+		}
+		if df != 0 {
+			return false
+		}
+		return dr == direction ||
+			dr == 2*direction && from.Rank == startRank && !cb.IsOccupied(Square{Rank: from.Rank + direction, File: from.File})
+	case Knight:
+		for _, offset := range knightOffsets {
+			if dr == offset[0] && df == offset[1] {
+				return true
+			}
+		}
+		return false
+	case King:
+		return dr >= -1 && dr <= 1 && df >= -1 && df <= 1
+	}
+
+	diagonal := dr == df || dr == -df
+	straight := dr == 0 || df == 0
+	if piece.Name == Bishop && !diagonal || piece.Name == Rook && !straight || !diagonal && !straight {
+		return false
+	}
+	step := Square{Rank: sign(dr), File: sign(df)}
+	for sq := (Square{Rank: from.Rank + step.Rank, File: from.File + step.File}); sq != to; sq.Rank, sq.File = sq.Rank+step.Rank, sq.File+step.File {
+		if cb.IsOccupied(sq) {
+			return false
+		}
+	}
 	return true
 }
 
+// SetPosition replaces the position with the one described by fen. The
+// position is parsed and validated before anything is changed, so on error
+// the previous position is left untouched. In lenient mode a position that
+// parses but breaks the rules of chess is accepted and the reasons logged.
 func (cb *ArrayChessBoard) SetPosition(fen string) error {
-	// Reset the board
-	for rank := 0; rank < BoardHeight; rank++ {
+	candidate, err := parseFEN(fen, cb.logger)
+	if err != nil {
+		return err
+	}
+
+	if err := candidate.Validate(); err != nil {
+		if cb.validationMode == StrictValidation {
+			return err
+		}
+		cb.logger.Info("accepting position in lenient mode: " + err.Error())
+	}
+
+	candidate.validationMode = cb.validationMode
+	*cb = *candidate
+	return nil
+}
+
+// FEN returns the Forsyth-Edwards Notation of the current position.
+func (cb *ArrayChessBoard) FEN() string {
+	var fen strings.Builder
+	for rank := BoardHeight - 1; rank >= 0; rank-- {
+		empty := 0
 		for file := 0; file < BoardWidth; file++ {
-			cb.board[rank][file] = nil
+			piece := cb.board[rank][file]
+			if piece == nil {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			fen.WriteString(piece.Symbol())
+		}
+		if empty > 0 {
+			fen.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			fen.WriteString("/")
 		}
 	}
 
+	side := "w"
+	if cb.sideToMove == Black {
+		side = "b"
+	}
+
+	castling := ""
+	if cb.castlingRights.WhiteKingSide {
+		castling += "K"
+	}
+	if cb.castlingRights.WhiteQueenSide {
+		castling += "Q"
+	}
+	if cb.castlingRights.BlackKingSide {
+		castling += "k"
+	}
+	if cb.castlingRights.BlackQueenSide {
+		castling += "q"
+	}
+	if castling == "" {
+		castling = "-"
+	}
+
+	enPassant := "-"
+	if cb.hasEnPassant {
+		enPassant = squareName(cb.enPassantSquare)
+	}
+
+	return fmt.Sprintf("%s %s %s %s %d %d", fen.String(), side, castling, enPassant, cb.halfmoveClock, cb.fullmoveNumber)
+}
+
+// parseFEN builds a board from fen without checking that the position is
+// legal.
+func parseFEN(fen string, logger *logging.Logger) (*ArrayChessBoard, error) {
+	cb := &ArrayChessBoard{logger: logger, fullmoveNumber: 1}
+
 	// Split the FEN string into parts
-	parts := strings.Split(fen, " ")
-	if len(parts) < 4 {
-		return fmt.Errorf("invalid fen string: %s", fen)
+	parts := strings.Fields(fen)
+	if len(parts) < 4 || len(parts) > 6 {
+		return nil, fmt.Errorf("invalid fen string: %s", fen)
 	}
 
 	// Parse the board position
 	ranks := strings.Split(parts[0], "/")
 	if len(ranks) != BoardHeight {
-		return fmt.Errorf("invalid fen board layout: %s", parts[0])
+		return nil, fmt.Errorf("invalid fen board layout: %s", parts[0])
 	}
 
 	for rank := 0; rank < BoardHeight; rank++ {
@@ -627,20 +1027,23 @@ func (cb *ArrayChessBoard) SetPosition(fen string) error {
 				} else if char >= 'A' && char <= 'Z' {
 					color = White
 				} else {
-					return fmt.Errorf("invalid fen piece character: %c", char)
+					return nil, fmt.Errorf("invalid fen piece character: %c", char)
 				}
 
 				pieceName, err := charToPieceName(char)
 				if err != nil {
-					return err
+					return nil, err
 				}
 
-				cb.board[rank][file] = &Piece{Name: pieceName, Color: color}
+				if file >= BoardWidth {
+					return nil, fmt.Errorf("invalid fen rank length: %s", ranks[BoardHeight-1-rank])
+				}
+				cb.board[rank][file] = staticPiece(pieceName, color)
 				file++
 			}
 		}
 		if file != BoardWidth {
-			return fmt.Errorf("invalid fen rank length: %s", ranks[BoardHeight-1-rank])
+			return nil, fmt.Errorf("invalid fen rank length: %s", ranks[BoardHeight-1-rank])
 		}
 	}
 
@@ -651,25 +1054,24 @@ func (cb *ArrayChessBoard) SetPosition(fen string) error {
 	case "b":
 		cb.sideToMove = Black
 	default:
-		return fmt.Errorf("invalid fen side to move: %s", parts[1])
+		return nil, fmt.Errorf("invalid fen side to move: %s", parts[1])
 	}
 
 	// Parse castling rights
-	cb.castlingRights = CastlingRights{}
-	for _, char := range parts[2] {
-		switch char {
-		case 'K':
-			cb.castlingRights.WhiteKingSide = true
-		case 'Q':
-			cb.castlingRights.WhiteQueenSide = true
-		case 'k':
-			cb.castlingRights.BlackKingSide = true
-		case 'q':
-			cb.castlingRights.BlackQueenSide = true
-		case '-':
-			// No castling rights
-		default:
-			return fmt.Errorf("invalid fen castling rights: %s", parts[2])
+	if parts[2] != "-" {
+		for _, char := range parts[2] {
+			switch char {
+			case 'K':
+				cb.castlingRights.WhiteKingSide = true
+			case 'Q':
+				cb.castlingRights.WhiteQueenSide = true
+			case 'k':
+				cb.castlingRights.BlackKingSide = true
+			case 'q':
+				cb.castlingRights.BlackQueenSide = true
+			default:
+				return nil, fmt.Errorf("invalid fen castling rights: %s", parts[2])
+			}
 		}
 	}
 
@@ -677,17 +1079,31 @@ func (cb *ArrayChessBoard) SetPosition(fen string) error {
 	if parts[3] != "-" {
 		enPassantSquare, err := parseSquare(parts[3])
 		if err != nil {
-			return fmt.Errorf("invalid fen en passant square: %s", parts[3])
+			return nil, fmt.Errorf("invalid fen en passant square: %s", parts[3])
 		}
-		// En passant square is not stored directly in this implementation
-		_ = enPassantSquare
+		cb.enPassantSquare = enPassantSquare
+		cb.hasEnPassant = true
 	}
 
-	// Reset move history and attacked squares
+	// Parse the move counters, which older FEN producers leave out
+	if len(parts) > 4 {
+		halfmoveClock, err := strconv.Atoi(parts[4])
+		if err != nil || halfmoveClock < 0 {
+			return nil, fmt.Errorf("invalid fen halfmove clock: %s", parts[4])
+		}
+		cb.halfmoveClock = halfmoveClock
+	}
+	if len(parts) > 5 {
+		fullmoveNumber, err := strconv.Atoi(parts[5])
+		if err != nil || fullmoveNumber < 1 {
+			return nil, fmt.Errorf("invalid fen fullmove number: %s", parts[5])
+		}
+		cb.fullmoveNumber = fullmoveNumber
+	}
+
+	// Reset move history
 	cb.moveHistory = []Move{}
-	cb.attackedSquares = make(map[Color][]Square)
-	cb.attackedSquares[White] = []Square{}
-	cb.attackedSquares[Black] = []Square{}
+	cb.stateHistory = []boardState{}
 
 	// Update king squares
 	cb.kingSquares = make(map[Color]Square)
@@ -699,8 +1115,9 @@ func (cb *ArrayChessBoard) SetPosition(fen string) error {
 			}
 		}
 	}
+	cb.hash = cb.computeHash()
 
-	return nil
+	return cb, nil
 }
 
 func charToPieceName(char rune) (PieceName, error) {
@@ -734,6 +1151,15 @@ func parseSquare(square string) (Square, error) {
 	return Square{Rank: rank, File: file}, nil
 }
 
+// LastMove returns the move made most recently, if any move has been made
+// since the position was set.
+func (cb *ArrayChessBoard) LastMove() (Move, bool) {
+	if len(cb.moveHistory) == 0 {
+		return Move{}, false
+	}
+	return cb.moveHistory[len(cb.moveHistory)-1], true
+}
+
 func (cb *ArrayChessBoard) UndoMove() error {
 	if len(cb.moveHistory) == 0 {
 		return fmt.Errorf("no moves to undo")
@@ -741,68 +1167,124 @@ func (cb *ArrayChessBoard) UndoMove() error {
 
 	lastMove := cb.moveHistory[len(cb.moveHistory)-1]
 	cb.moveHistory = cb.moveHistory[:len(cb.moveHistory)-1]
+	state := cb.stateHistory[len(cb.stateHistory)-1]
+	cb.stateHistory = cb.stateHistory[:len(cb.stateHistory)-1]
+	color := lastMove.Piece.Color
 
 	// Revert the move
 	cb.board[lastMove.From.Rank][lastMove.From.File] = cb.board[lastMove.To.Rank][lastMove.To.File]
 	cb.board[lastMove.To.Rank][lastMove.To.File] = nil
 
 	// Handle captures
-	if lastMove.CapturedPiece != nil {
+	if lastMove.CapturedPiece != nil && !lastMove.IsEnPassant {
 		cb.board[lastMove.To.Rank][lastMove.To.File] = lastMove.CapturedPiece
 	}
 
 	// Handle promotions
 	if lastMove.Promotion != nil {
-		cb.board[lastMove.From.Rank][lastMove.From.File] = &Piece{Name: Pawn, Color: lastMove.Piece.Color}
+		cb.board[lastMove.From.Rank][lastMove.From.File] = staticPiece(Pawn, color)
 	}
 
 	// Handle castling
 	if lastMove.IsCastling {
 		if lastMove.To.File == 2 { // Queen-side castling
 			cb.board[lastMove.From.Rank][3] = nil
-			cb.board[lastMove.From.Rank][0] = &Piece{Rook, lastMove.Piece.Color}
+			cb.board[lastMove.From.Rank][0] = staticPiece(Rook, color)
 		} else if lastMove.To.File == 6 { // King-side castling
 			cb.board[lastMove.From.Rank][5] = nil
-			cb.board[lastMove.From.Rank][7] = &Piece{Rook, lastMove.Piece.Color}
+			cb.board[lastMove.From.Rank][7] = staticPiece(Rook, color)
 		}
 	}
 
 	// Handle en passant
 	if lastMove.IsEnPassant {
-		if lastMove.Piece.Color == White {
-			cb.board[lastMove.To.Rank-1][lastMove.To.File] = lastMove.CapturedPiece
-		} else {
-			cb.board[lastMove.To.Rank+1][lastMove.To.File] = lastMove.CapturedPiece
-		}
+		cb.board[lastMove.From.Rank][lastMove.To.File] = lastMove.CapturedPiece
+	}
+
+	if lastMove.Piece.Name == King {
+		cb.kingSquares[color] = lastMove.From
 	}
 
-	// Restore castling rights
-	cb.castlingRights = lastMove.PreviousCastlingRights
+	// Restore castling rights, en passant square, move counters and hash
+	cb.castlingRights = state.castlingRights
+	cb.enPassantSquare = state.enPassantSquare
+	cb.hasEnPassant = state.hasEnPassant
+	cb.halfmoveClock = state.halfmoveClock
+	cb.hash = state.hash
+	if color == Black {
+		cb.fullmoveNumber--
+	}
 
 	// Restore the side to move
-	cb.sideToMove = oppositeColor(cb.sideToMove)
+	cb.sideToMove = color
 
 	return nil
 }
 
+// MakeNullMove passes the turn to the opponent without moving, for null
+// move pruning. The fifty-move counter restarts so that no repetition is
+// found across the pass. The null move must be undone with UndoNullMove
+// before any earlier move is undone.
+func (cb *ArrayChessBoard) MakeNullMove() {
+	cb.stateHistory = append(cb.stateHistory, boardState{
+		castlingRights:  cb.castlingRights,
+		enPassantSquare: cb.enPassantSquare,
+		hasEnPassant:    cb.hasEnPassant,
+		halfmoveClock:   cb.halfmoveClock,
+		hash:            cb.hash,
+	})
+	if cb.hasEnPassant {
+		cb.hash ^= zobristEnPassant[cb.enPassantSquare.File]
+		cb.hasEnPassant = false
+	}
+	cb.halfmoveClock = 0
+	cb.sideToMove = OppositeColor(cb.sideToMove)
+	cb.hash ^= zobristSide
+}
+
+func (cb *ArrayChessBoard) UndoNullMove() {
+	state := cb.stateHistory[len(cb.stateHistory)-1]
+	cb.stateHistory = cb.stateHistory[:len(cb.stateHistory)-1]
+	cb.enPassantSquare = state.enPassantSquare
+	cb.hasEnPassant = state.hasEnPassant
+	cb.halfmoveClock = state.halfmoveClock
+	cb.hash = state.hash
+	cb.sideToMove = OppositeColor(cb.sideToMove)
+}
+
+// Clone returns an independent copy of the board, move history included,
+// so that the position can be searched on another goroutine.
+func (cb *ArrayChessBoard) Clone() ChessBoard {
+	clone := *cb
+	clone.moveHistory = slices.Clone(cb.moveHistory)
+	clone.stateHistory = slices.Clone(cb.stateHistory)
+	clone.kingSquares = maps.Clone(cb.kingSquares)
+	return &clone
+}
+
+// Perft counts the leaf nodes of the legal move tree to the given depth.
+// Move buffers are allocated once per call and reused at every node.
 func (cb *ArrayChessBoard) Perft(depth int) int {
 	if depth == 0 {
 		return 1
 	}
+	buffers := make([]MoveList, depth)
+	return cb.perft(depth, buffers)
+}
 
-	nodes := 0
-	moves := cb.GenerateLegalMoves()
+func (cb *ArrayChessBoard) perft(depth int, buffers []MoveList) int {
+	buf := &buffers[depth-1]
+	cb.GenerateMoves(buf)
+	if depth == 1 {
+		return buf.Len()
+	}
 
-	for _, move := range moves {
-		err := cb.MakeMove(move)
-		if err != nil {
+	nodes := 0
+	for i := 0; i < buf.Len(); i++ {
+		if err := cb.MakeMove(buf.At(i)); err != nil {
 			panic(fmt.Sprintf("MakeMove failed: %v", err))
 		}
-
-		if !cb.InCheck(oppositeColor(cb.sideToMove)) {
-			nodes += cb.Perft(depth - 1)
-		}
-
+		nodes += cb.perft(depth-1, buffers)
 		if err := cb.UndoMove(); err != nil {
 			panic(fmt.Sprintf("UndoMove failed: %v", err))
 		}
@@ -81,29 +81,163 @@ func TestNewArrayChessBoard(t *testing.T) {
 	}
 
 	for color, expectedSquares := range expectedAttackedSquares {
-		actualSquares := cb.attackedSquares[color]
 		expectedSet := make(map[Square]bool)
 		for _, square := range expectedSquares {
 			expectedSet[square] = true
 		}
-
-		for _, square := range actualSquares {
-			if !expectedSet[square] {
-				t.Errorf("unexpected attacked square %v for color %v", square, color)
+		// The squares a side attacks, its own pieces' excepted
+		for rank := 0; rank < BoardHeight; rank++ {
+			for file := 0; file < BoardWidth; file++ {
+				square := Square{Rank: rank, File: file}
+				attacked := cb.squareAttackedBy(square, color) && cb.validateAttackedSquare(square, color)
+				if attacked && !expectedSet[square] {
+					t.Errorf("unexpected attacked square %v for color %v", square, color)
+				}
+				if !attacked && expectedSet[square] {
+					t.Errorf("expected attacked square %v for color %v not found", square, color)
+				}
 			}
 		}
+	}
+}
 
-		for _, square := range expectedSquares {
-			found := false
-			for _, actualSquare := range actualSquares {
-				if actualSquare == square {
-					found = true
-					break
-				}
-			}
-			if !found {
-				t.Errorf("expected attacked square %v for color %v not found", square, color)
-			}
+func TestFENRoundTrip(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 b - - 12 47",
+	}
+	for _, fen := range fens {
+		cb := NewArrayChessBoard(logger)
+		if err := cb.SetPosition(fen); err != nil {
+			t.Fatalf("failed to set position %s: %v", fen, err)
+		}
+		if got := cb.FEN(); got != fen {
+			t.Errorf("expected fen %s, got %s", fen, got)
+		}
+	}
+
+	cb := NewArrayChessBoard(logger)
+	cb.MakeMove(Move{From: Square{Rank: 1, File: 4}, To: Square{Rank: 3, File: 4}})
+	cb.MakeMove(Move{From: Square{Rank: 7, File: 6}, To: Square{Rank: 5, File: 5}})
+	expected := "rnbqkb1r/pppppppp/5n2/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 1 2"
+	if got := cb.FEN(); got != expected {
+		t.Errorf("expected fen %s after 1. e4 Nf6, got %s", expected, got)
+	}
+}
+
+func TestNullMove(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := NewArrayChessBoard(logger)
+	if err := cb.SetPosition("rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 4 3"); err != nil {
+		t.Fatalf("failed to set position: %v", err)
+	}
+	before := cb.FEN()
+	hashBefore := cb.Hash()
+
+	cb.MakeNullMove()
+	passed := "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3"
+	if got := cb.FEN(); got != passed {
+		t.Errorf("expected fen %s after a null move, got %s", passed, got)
+	}
+	expected := NewArrayChessBoard(logger)
+	if err := expected.SetPosition(passed); err != nil {
+		t.Fatalf("failed to set position: %v", err)
+	}
+	if cb.Hash() != expected.Hash() {
+		t.Errorf("expected the hash of %s after a null move", passed)
+	}
+	if cb.RepetitionCount() != 0 {
+		t.Errorf("expected no repetition across a null move")
+	}
+
+	cb.UndoNullMove()
+	if got := cb.FEN(); got != before {
+		t.Errorf("expected fen %s after undoing the null move, got %s", before, got)
+	}
+	if cb.Hash() != hashBefore {
+		t.Errorf("expected the hash to be restored")
+	}
+}
+
+func TestClone(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := NewArrayChessBoard(logger)
+	cb.MakeMove(Move{From: Square{Rank: 1, File: 4}, To: Square{Rank: 3, File: 4}})
+	clone := cb.Clone()
+	if clone.FEN() != cb.FEN() || clone.Hash() != cb.Hash() {
+		t.Fatalf("expected the clone to match %s, got %s", cb.FEN(), clone.FEN())
+	}
+
+	// Moves on the clone must not affect the original, and the clone must
+	// be able to undo the moves made before it was cloned
+	before := cb.FEN()
+	clone.MakeMove(Move{From: Square{Rank: 6, File: 4}, To: Square{Rank: 4, File: 4}})
+	clone.MakeMove(Move{From: Square{Rank: 0, File: 4}, To: Square{Rank: 1, File: 4}})
+	if cb.FEN() != before {
+		t.Errorf("expected the original to stay at %s, got %s", before, cb.FEN())
+	}
+	for i := 0; i < 3; i++ {
+		if err := clone.UndoMove(); err != nil {
+			t.Fatalf("failed to undo move %d on the clone: %v", i, err)
 		}
 	}
+	if clone.FEN() != NewArrayChessBoard(logger).FEN() {
+		t.Errorf("expected the clone back at the start position, got %s", clone.FEN())
+	}
+	if cb.FEN() != before {
+		t.Errorf("expected the original to stay at %s, got %s", before, cb.FEN())
+	}
+}
+
+func TestAttackersTo(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	cb := NewArrayChessBoard(logger)
+	if err := cb.SetPosition("3q4/8/2p1k3/3p4/4P3/1B2N3/3R4/3R2K1 w - - 0 1"); err != nil {
+		t.Fatalf("failed to set position: %v", err)
+	}
+	squares := func(names ...string) uint64 {
+		var set uint64
+		for _, name := range names {
+			set |= uint64(1) << Square{Rank: int(name[1] - '1'), File: int(name[0] - 'a')}.Index()
+		}
+		return set
+	}
+	d5 := Square{Rank: 4, File: 3}
+
+	tests := []struct {
+		color    Color
+		removed  uint64
+		expected uint64
+	}{
+		{White, 0, squares("e4", "e3", "b3", "d2")},
+		{White, squares("d2"), squares("e4", "e3", "b3", "d1")},
+		{White, squares("e4"), squares("e3", "b3", "d2")},
+		{Black, 0, squares("c6", "e6", "d8")},
+	}
+	for _, test := range tests {
+		if got := cb.AttackersTo(d5, test.color, test.removed); got != test.expected {
+			t.Errorf("expected attackers %x for %s with %x removed, got %x", test.expected, test.color, test.removed, got)
+		}
+	}
+	if got := SquareAt(d5.Index()); got != d5 {
+		t.Errorf("expected %v back from its index, got %v", d5, got)
+	}
 }
@@ -1,6 +1,9 @@
 package board
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	BoardHeight = 8
@@ -42,6 +45,15 @@ type Piece struct {
 	Color Color
 }
 
+// Symbol returns the FEN letter for the piece: upper case for white, lower
+// case for black.
+func (p Piece) Symbol() string {
+	if p.Color == Black {
+		return strings.ToLower(string(p.Name))
+	}
+	return string(p.Name)
+}
+
 type CastlingRights struct {
 	WhiteKingSide  bool
 	WhiteQueenSide bool
@@ -60,16 +72,44 @@ type Move struct {
 	PreviousCastlingRights CastlingRights
 }
 
+// Equal reports whether both moves go between the same squares with the
+// same promotion, ignoring the fields derived from the position.
+func (m Move) Equal(other Move) bool {
+	if m.From != other.From || m.To != other.To {
+		return false
+	}
+	if m.Promotion == nil || other.Promotion == nil {
+		return m.Promotion == nil && other.Promotion == nil
+	}
+	return m.Promotion.Name == other.Promotion.Name
+}
+
 type ChessBoard interface {
 	PieceAt(square Square) *Piece
 	IsOccupied(square Square) bool
 	SideToMove() Color
 	CastlingRights() CastlingRights
 	GenerateLegalMoves() []Move
+	GenerateMoves(buf *MoveList)
+	GenerateCaptures(buf *MoveList)
+	GenerateQuiets(buf *MoveList)
+	GenerateEvasions(buf *MoveList)
+	GenerateQuietChecks(buf *MoveList)
 	IsMoveLegal(move Move) bool
+	LegalMove(move Move) (Move, bool)
 	InCheck(color Color) bool
+	AttackersTo(square Square, color Color, removed uint64) uint64
 	MakeMove(move Move) error
 	UndoMove() error
+	MakeNullMove()
+	UndoNullMove()
+	LastMove() (Move, bool)
+	Clone() ChessBoard
 	SetPosition(fen string) error
+	FEN() string
+	Hash() uint64
+	HalfmoveClock() int
+	RepetitionCount() int
+	HasInsufficientMaterial() bool
 	Display() string
 }
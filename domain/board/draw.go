@@ -0,0 +1,50 @@
+package board
+
+func (cb *ArrayChessBoard) HalfmoveClock() int {
+	return cb.halfmoveClock
+}
+
+// RepetitionCount returns how many times the current position occurred
+// earlier in the move history. Only positions since the last capture or
+// pawn move are compared, as no earlier position can repeat.
+func (cb *ArrayChessBoard) RepetitionCount() int {
+	count := 0
+	n := len(cb.stateHistory)
+	for i := n - 2; i >= 0 && i >= n-cb.halfmoveClock; i -= 2 {
+		if cb.stateHistory[i].hash == cb.hash {
+			count++
+		}
+	}
+	return count
+}
+
+// HasInsufficientMaterial reports whether neither side can possibly mate:
+// bare kings, a single minor piece, or only bishops all on squares of the
+// same colour.
+func (cb *ArrayChessBoard) HasInsufficientMaterial() bool {
+	minors := 0
+	knights := 0
+	bishopSquareColors := [2]bool{}
+	for rank := 0; rank < BoardHeight; rank++ {
+		for file := 0; file < BoardWidth; file++ {
+			piece := cb.board[rank][file]
+			if piece == nil {
+				continue
+			}
+			switch piece.Name {
+			case Pawn, Rook, Queen:
+				return false
+			case Knight:
+				minors++
+				knights++
+			case Bishop:
+				minors++
+				bishopSquareColors[(rank+file)%2] = true
+			}
+		}
+	}
+	if minors <= 1 {
+		return true
+	}
+	return knights == 0 && !(bishopSquareColors[0] && bishopSquareColors[1])
+}
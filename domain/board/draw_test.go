@@ -0,0 +1,97 @@
+package board
+
+import (
+	"math/rand"
+	"testing"
+
+	logging "jesus_chess/domain/logging"
+)
+
+func TestIncrementalHashMatchesRecomputedHash(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(31))
+	cb := NewArrayChessBoard(logger)
+	for _, fen := range stagedGenerationPositions {
+		cb.SetPosition(fen)
+		start := cb.Hash()
+		played := 0
+		for ; played < 40; played++ {
+			moves := cb.GenerateLegalMoves()
+			if len(moves) == 0 {
+				break
+			}
+			cb.MakeMove(moves[rng.Intn(len(moves))])
+			if cb.Hash() != cb.computeHash() {
+				t.Fatalf("incremental hash differs from recomputed hash after %d moves: %s", played+1, cb.FEN())
+			}
+		}
+		for ; played > 0; played-- {
+			cb.UndoMove()
+		}
+		if cb.Hash() != start {
+			t.Fatalf("hash not restored after undoing every move from %s", fen)
+		}
+	}
+}
+
+func TestRepetitionCount(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := NewArrayChessBoard(logger)
+	shuffle := []Move{
+		{From: Square{Rank: 0, File: 6}, To: Square{Rank: 2, File: 5}},
+		{From: Square{Rank: 7, File: 6}, To: Square{Rank: 5, File: 5}},
+		{From: Square{Rank: 2, File: 5}, To: Square{Rank: 0, File: 6}},
+		{From: Square{Rank: 5, File: 5}, To: Square{Rank: 7, File: 6}},
+	}
+	for round := 1; round <= 2; round++ {
+		for _, move := range shuffle {
+			if err := cb.MakeMove(move); err != nil {
+				t.Fatalf("failed to make move: %v", err)
+			}
+		}
+		if count := cb.RepetitionCount(); count != round {
+			t.Errorf("expected the start position repeated %d times, got %d", round, count)
+		}
+	}
+
+	// A pawn move makes every earlier position unreachable
+	cb.MakeMove(Move{From: Square{Rank: 1, File: 4}, To: Square{Rank: 3, File: 4}})
+	if count := cb.RepetitionCount(); count != 0 {
+		t.Errorf("expected no repetitions after a pawn move, got %d", count)
+	}
+}
+
+func TestHasInsufficientMaterial(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	tests := []struct {
+		fen          string
+		insufficient bool
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/4KN2 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1", false},
+		{"4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"4k1b1/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"4k3/8/8/8/8/8/8/4KNN1 w - - 0 1", false},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false},
+	}
+	cb := NewArrayChessBoard(logger)
+	for _, test := range tests {
+		cb.SetPosition(test.fen)
+		if got := cb.HasInsufficientMaterial(); got != test.insufficient {
+			t.Errorf("%s: expected insufficient material %v, got %v", test.fen, test.insufficient, got)
+		}
+	}
+}
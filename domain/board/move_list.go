@@ -0,0 +1,39 @@
+package board
+
+// MaxMoves is an upper bound on the number of legal moves in any chess
+// position (the known maximum is 218).
+const MaxMoves = 256
+
+// MoveList is a fixed-capacity move buffer. Callers keep one per ply and
+// reuse it across calls so that move generation does not allocate.
+type MoveList struct {
+	moves [MaxMoves]Move
+	count int
+}
+
+func (ml *MoveList) Clear() {
+	ml.count = 0
+}
+
+func (ml *MoveList) Add(move Move) {
+	ml.moves[ml.count] = move
+	ml.count++
+}
+
+func (ml *MoveList) Len() int {
+	return ml.count
+}
+
+func (ml *MoveList) At(i int) Move {
+	return ml.moves[i]
+}
+
+func (ml *MoveList) Swap(i, j int) {
+	ml.moves[i], ml.moves[j] = ml.moves[j], ml.moves[i]
+}
+
+// Moves returns the generated moves. The slice aliases the list's storage
+// and is only valid until the list is next cleared or written to.
+func (ml *MoveList) Moves() []Move {
+	return ml.moves[:ml.count]
+}
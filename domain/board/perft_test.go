@@ -46,3 +46,90 @@ func TestPerft(t *testing.T) {
 	pprof.StopCPUProfile()
 	cpuProfile.Close()
 }
+
+func TestPerftPositions(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		fen   string
+		depth int
+		nodes int
+	}{
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, 97862},
+		{"endgame", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5, 674624},
+		{"promotions", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 4, 422333},
+		{"castling", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+		{"middlegame", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", 3, 89890},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			board := NewArrayChessBoard(logger)
+			if err := board.SetPosition(test.fen); err != nil {
+				t.Fatalf("failed to set position: %v", err)
+			}
+			nodes := board.Perft(test.depth)
+			if nodes != test.nodes {
+				t.Errorf("perft failed at depth %d: expected %d, got %d", test.depth, test.nodes, nodes)
+			}
+		})
+	}
+}
+
+func TestPerftDoesNotAllocatePerNode(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	board := NewArrayChessBoard(logger)
+	board.SetPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	board.Perft(3) // grow the move history to its working size
+
+	// Perft allocates its per-ply buffers once per call, never per node
+	allocs := testing.AllocsPerRun(5, func() {
+		board.Perft(3)
+	})
+	if allocs > 1 {
+		t.Errorf("expected at most 1 allocation per perft call, got %.1f", allocs)
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		b.Fatalf("failed to create logger: %v", err)
+	}
+
+	board := NewArrayChessBoard(logger)
+	board.SetPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	nodes := 0
+	for i := 0; i < b.N; i++ {
+		nodes += board.Perft(3)
+	}
+	b.ReportMetric(float64(nodes)/b.Elapsed().Seconds(), "nodes/s")
+}
+
+func BenchmarkGenerateMoves(b *testing.B) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		b.Fatalf("failed to create logger: %v", err)
+	}
+
+	board := NewArrayChessBoard(logger)
+	board.SetPosition("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	var buf MoveList
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		board.GenerateMoves(&buf)
+	}
+}
@@ -0,0 +1,161 @@
+package board
+
+import (
+	"testing"
+
+	logging "jesus_chess/domain/logging"
+)
+
+var stagedGenerationPositions = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+}
+
+// forEachNode calls visit on every position reachable from fen within depth
+// plies.
+func forEachNode(t *testing.T, fen string, depth int, visit func(cb *ArrayChessBoard)) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	cb := NewArrayChessBoard(logger)
+	if err := cb.SetPosition(fen); err != nil {
+		t.Fatalf("failed to set position %s: %v", fen, err)
+	}
+	var walk func(depth int)
+	walk = func(depth int) {
+		visit(cb)
+		if depth == 0 {
+			return
+		}
+		for _, move := range cb.GenerateLegalMoves() {
+			cb.MakeMove(move)
+			walk(depth - 1)
+			cb.UndoMove()
+		}
+	}
+	walk(depth)
+}
+
+func moveSet(moves []Move) map[Move]bool {
+	set := make(map[Move]bool)
+	for _, move := range moves {
+		set[Move{From: move.From, To: move.To, Promotion: move.Promotion}] = true
+	}
+	return set
+}
+
+func TestCapturesAndQuietsPartitionLegalMoves(t *testing.T) {
+	var all, captures, quiets MoveList
+	for _, fen := range stagedGenerationPositions {
+		forEachNode(t, fen, 2, func(cb *ArrayChessBoard) {
+			cb.GenerateMoves(&all)
+			cb.GenerateCaptures(&captures)
+			cb.GenerateQuiets(&quiets)
+
+			if captures.Len()+quiets.Len() != all.Len() {
+				t.Fatalf("%s: %d captures + %d quiets != %d legal moves", cb.Display(), captures.Len(), quiets.Len(), all.Len())
+			}
+			legal := moveSet(all.Moves())
+			for _, move := range captures.Moves() {
+				if move.CapturedPiece == nil && move.Promotion == nil {
+					t.Fatalf("quiet move %v returned as capture", move)
+				}
+				if !legal[Move{From: move.From, To: move.To, Promotion: move.Promotion}] {
+					t.Fatalf("capture %v is not legal", move)
+				}
+			}
+			for _, move := range quiets.Moves() {
+				if move.CapturedPiece != nil || move.Promotion != nil {
+					t.Fatalf("capture %v returned as quiet move", move)
+				}
+				if !legal[Move{From: move.From, To: move.To, Promotion: move.Promotion}] {
+					t.Fatalf("quiet move %v is not legal", move)
+				}
+			}
+		})
+	}
+}
+
+func TestEvasionsMatchLegalMovesInCheck(t *testing.T) {
+	var all, evasions MoveList
+	checks := 0
+	for _, fen := range stagedGenerationPositions {
+		forEachNode(t, fen, 3, func(cb *ArrayChessBoard) {
+			cb.GenerateEvasions(&evasions)
+			if !cb.InCheck(cb.SideToMove()) {
+				if evasions.Len() != 0 {
+					t.Fatalf("expected no evasions when not in check, got %d", evasions.Len())
+				}
+				return
+			}
+			checks++
+			cb.GenerateMoves(&all)
+			if evasions.Len() != all.Len() {
+				t.Fatalf("%s: expected %d evasions, got %d", cb.Display(), all.Len(), evasions.Len())
+			}
+			legal := moveSet(all.Moves())
+			for _, move := range evasions.Moves() {
+				if !legal[Move{From: move.From, To: move.To, Promotion: move.Promotion}] {
+					t.Fatalf("evasion %v is not legal", move)
+				}
+			}
+		})
+	}
+	if checks == 0 {
+		t.Fatalf("expected the test positions to reach checks")
+	}
+}
+
+func TestQuietChecks(t *testing.T) {
+	var quiets, quietChecks MoveList
+	for _, fen := range stagedGenerationPositions {
+		forEachNode(t, fen, 1, func(cb *ArrayChessBoard) {
+			cb.GenerateQuiets(&quiets)
+			cb.GenerateQuietChecks(&quietChecks)
+			checks := moveSet(quietChecks.Moves())
+			enemy := OppositeColor(cb.SideToMove())
+			for _, move := range quiets.Moves() {
+				cb.MakeMove(move)
+				givesCheck := cb.InCheck(enemy)
+				cb.UndoMove()
+				if givesCheck != checks[Move{From: move.From, To: move.To, Promotion: move.Promotion}] {
+					t.Fatalf("quiet move %v: gives check %v, but returned as quiet check %v", move, givesCheck, !givesCheck)
+				}
+			}
+		})
+	}
+}
+
+func TestLegalMoveMatchesGeneration(t *testing.T) {
+	var all MoveList
+	for _, fen := range stagedGenerationPositions {
+		forEachNode(t, fen, 1, func(cb *ArrayChessBoard) {
+			cb.GenerateMoves(&all)
+			generated := make(map[Move]Move)
+			for _, move := range all.Moves() {
+				generated[Move{From: move.From, To: move.To, Promotion: move.Promotion}] = move
+			}
+			for from := 0; from < 64; from++ {
+				if piece := cb.PieceAt(Square{Rank: from / 8, File: from % 8}); from%9 != 0 && (piece == nil || piece.Color != cb.SideToMove()) {
+					// Moves from an empty square or an enemy piece are all
+					// rejected alike, so a few of them are enough
+					continue
+				}
+				for to := 0; to < 64; to++ {
+					for _, promotion := range []*Piece{nil, staticPiece(Queen, cb.SideToMove()), staticPiece(Knight, cb.SideToMove())} {
+						move := Move{From: Square{Rank: from / 8, File: from % 8}, To: Square{Rank: to / 8, File: to % 8}, Promotion: promotion}
+						want, ok := generated[move]
+						got, legal := cb.LegalMove(move)
+						if legal != ok || legal && got != want {
+							t.Fatalf("%s: expected %v legal %v for %v, got %v legal %v", cb.FEN(), want, ok, move, got, legal)
+						}
+					}
+				}
+			}
+		})
+	}
+}
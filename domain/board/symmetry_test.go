@@ -0,0 +1,113 @@
+package board
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	logging "jesus_chess/domain/logging"
+)
+
+// loadPositionCorpus reads the FENs in testdata/positions.fen, one per line.
+func loadPositionCorpus(t *testing.T) []string {
+	file, err := os.Open("testdata/positions.fen")
+	if err != nil {
+		t.Fatalf("failed to open position corpus: %v", err)
+	}
+	defer file.Close()
+
+	fens := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			fens = append(fens, line)
+		}
+	}
+	return fens
+}
+
+// colorFlippedMirror returns fen with the board mirrored and the colours
+// swapped, which is the same position with white and black exchanged.
+func colorFlippedMirror(t *testing.T, fen string) string {
+	mirrored, err := MirrorFEN(fen)
+	if err != nil {
+		t.Fatalf("failed to mirror %s: %v", fen, err)
+	}
+	flipped, err := FlipColorsFEN(mirrored)
+	if err != nil {
+		t.Fatalf("failed to flip colours of %s: %v", mirrored, err)
+	}
+	return flipped
+}
+
+func TestMirrorAndFlipColorsFEN(t *testing.T) {
+	fen := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K1R1 b Qkq - 3 17"
+
+	mirrored, _ := MirrorFEN(fen)
+	if expected := "R3K1R1/PPPBBPPP/2N2Q1p/1p2P3/3PN3/bn2pnp1/p1ppqpb1/r3k2r b KQq - 3 17"; mirrored != expected {
+		t.Errorf("expected mirrored fen %s, got %s", expected, mirrored)
+	}
+	flipped, _ := FlipColorsFEN(fen)
+	if expected := "R3K2R/P1PPQPB1/BN2PNP1/3pn3/1P2p3/2n2q1P/pppbbppp/r3k1r1 w Qkq - 3 17"; flipped != expected {
+		t.Errorf("expected colour flipped fen %s, got %s", expected, flipped)
+	}
+
+	withEnPassant := "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3"
+	if expected := "rnbqkbnr/pppp1ppp/8/8/3PpP2/8/PPP1P1PP/RNBQKBNR b KQkq f3 0 3"; colorFlippedMirror(t, withEnPassant) != expected {
+		t.Errorf("expected %s, got %s", expected, colorFlippedMirror(t, withEnPassant))
+	}
+}
+
+func TestColorFlippedMirrorIsAnInvolution(t *testing.T) {
+	for _, fen := range loadPositionCorpus(t) {
+		if twice := colorFlippedMirror(t, colorFlippedMirror(t, fen)); twice != fen {
+			t.Errorf("expected %s back after flipping twice, got %s", fen, twice)
+		}
+	}
+}
+
+func TestPerftSymmetry(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	depth := 3
+	if testing.Short() {
+		depth = 2
+	}
+	cb := NewArrayChessBoard(logger)
+	for _, fen := range loadPositionCorpus(t) {
+		mirror := colorFlippedMirror(t, fen)
+		if err := cb.SetPosition(fen); err != nil {
+			t.Fatalf("failed to set position %s: %v", fen, err)
+		}
+		original := cb.Perft(depth)
+		if err := cb.SetPosition(mirror); err != nil {
+			t.Fatalf("failed to set mirrored position %s: %v", mirror, err)
+		}
+		if mirrored := cb.Perft(depth); mirrored != original {
+			t.Errorf("perft(%d) differs: %s has %d nodes, its mirror %s has %d", depth, fen, original, mirror, mirrored)
+		}
+	}
+}
+
+func TestBoardMirrorMatchesFEN(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := NewArrayChessBoard(logger)
+	for _, fen := range loadPositionCorpus(t)[:20] {
+		cb.SetPosition(fen)
+		cb.Mirror()
+		cb.FlipColors()
+		if expected := colorFlippedMirror(t, fen); cb.FEN() != expected {
+			t.Errorf("expected %s, got %s", expected, cb.FEN())
+		}
+		if err := cb.Validate(); err != nil {
+			t.Errorf("expected the colour flipped mirror of %s to be legal, got %v", fen, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package board
+
+// Mirror flips the board vertically: rank 1 swaps with rank 8, rank 2 with
+// rank 7 and so on. Piece colours and the side to move are unchanged, and
+// castling rights and the en passant square move with their squares.
+// Mirroring and then flipping colours gives the same position with the
+// roles of white and black exchanged. The move history is cleared.
+func (cb *ArrayChessBoard) Mirror() {
+	for rank := 0; rank < BoardHeight/2; rank++ {
+		cb.board[rank], cb.board[BoardHeight-1-rank] = cb.board[BoardHeight-1-rank], cb.board[rank]
+	}
+	rights := cb.castlingRights
+	cb.castlingRights = CastlingRights{
+		WhiteKingSide:  rights.BlackKingSide,
+		WhiteQueenSide: rights.BlackQueenSide,
+		BlackKingSide:  rights.WhiteKingSide,
+		BlackQueenSide: rights.WhiteQueenSide,
+	}
+	cb.enPassantSquare.Rank = BoardHeight - 1 - cb.enPassantSquare.Rank
+	cb.resetAfterTransform()
+}
+
+// FlipColors swaps the colour of every piece and the side to move, leaving
+// the pieces on their squares. The move history is cleared.
+func (cb *ArrayChessBoard) FlipColors() {
+	for rank := 0; rank < BoardHeight; rank++ {
+		for file := 0; file < BoardWidth; file++ {
+			if piece := cb.board[rank][file]; piece != nil {
+				cb.board[rank][file] = staticPiece(piece.Name, OppositeColor(piece.Color))
+			}
+		}
+	}
+	cb.sideToMove = OppositeColor(cb.sideToMove)
+	cb.resetAfterTransform()
+}
+
+func (cb *ArrayChessBoard) resetAfterTransform() {
+	cb.moveHistory = cb.moveHistory[:0]
+	cb.stateHistory = cb.stateHistory[:0]
+	cb.kingSquares = make(map[Color]Square)
+	for rank := 0; rank < BoardHeight; rank++ {
+		for file := 0; file < BoardWidth; file++ {
+			piece := cb.board[rank][file]
+			if piece != nil && piece.Name == King {
+				cb.kingSquares[piece.Color] = Square{Rank: rank, File: file}
+			}
+		}
+	}
+	cb.hash = cb.computeHash()
+}
+
+// MirrorFEN returns the FEN of the vertically mirrored position.
+func MirrorFEN(fen string) (string, error) {
+	cb, err := parseFEN(fen, nil)
+	if err != nil {
+		return "", err
+	}
+	cb.Mirror()
+	return cb.FEN(), nil
+}
+
+// FlipColorsFEN returns the FEN of the position with colours swapped.
+func FlipColorsFEN(fen string) (string, error) {
+	cb, err := parseFEN(fen, nil)
+	if err != nil {
+		return "", err
+	}
+	cb.FlipColors()
+	return cb.FEN(), nil
+}
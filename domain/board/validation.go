@@ -0,0 +1,176 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationMode controls how SetPosition treats positions that parse but
+// could not arise in a game.
+type ValidationMode int
+
+const (
+	// StrictValidation rejects illegal positions.
+	StrictValidation ValidationMode = iota
+	// LenientValidation accepts illegal positions and logs the reasons.
+	LenientValidation
+)
+
+// InvalidPositionError lists every reason a position was found illegal.
+type InvalidPositionError struct {
+	Reasons []string
+}
+
+func (e *InvalidPositionError) Error() string {
+	return "invalid position: " + strings.Join(e.Reasons, "; ")
+}
+
+func (cb *ArrayChessBoard) SetValidationMode(mode ValidationMode) {
+	cb.validationMode = mode
+}
+
+// Validate checks the current position against the rules of chess and
+// returns an *InvalidPositionError describing every problem found, or nil.
+func (cb *ArrayChessBoard) Validate() error {
+	reasons := []string{}
+	reasons = append(reasons, cb.validatePieceCounts(White)...)
+	reasons = append(reasons, cb.validatePieceCounts(Black)...)
+	reasons = append(reasons, cb.validatePawnRanks()...)
+	reasons = append(reasons, cb.validateChecks()...)
+	reasons = append(reasons, cb.validateCastlingRights()...)
+	reasons = append(reasons, cb.validateEnPassant()...)
+
+	if len(reasons) > 0 {
+		return &InvalidPositionError{Reasons: reasons}
+	}
+	return nil
+}
+
+func (cb *ArrayChessBoard) validatePieceCounts(color Color) []string {
+	counts := make(map[PieceName]int)
+	total := 0
+	for rank := 0; rank < BoardHeight; rank++ {
+		for file := 0; file < BoardWidth; file++ {
+			piece := cb.board[rank][file]
+			if piece != nil && piece.Color == color {
+				counts[piece.Name]++
+				total++
+			}
+		}
+	}
+
+	name := colorName(color)
+	reasons := []string{}
+	if counts[King] != 1 {
+		reasons = append(reasons, fmt.Sprintf("%s has %d kings", name, counts[King]))
+	}
+	if counts[Pawn] > 8 {
+		reasons = append(reasons, fmt.Sprintf("%s has %d pawns", name, counts[Pawn]))
+	}
+	if total > 16 {
+		reasons = append(reasons, fmt.Sprintf("%s has %d pieces", name, total))
+	}
+
+	// Every piece beyond the starting set must have come from a promoted pawn
+	promoted := 0
+	for pieceName, initial := range map[PieceName]int{Queen: 1, Rook: 2, Bishop: 2, Knight: 2} {
+		if counts[pieceName] > initial {
+			promoted += counts[pieceName] - initial
+		}
+	}
+	if promoted > 8-counts[Pawn] {
+		reasons = append(reasons, fmt.Sprintf("%s has %d promoted pieces but only %d missing pawns", name, promoted, 8-counts[Pawn]))
+	}
+	return reasons
+}
+
+func (cb *ArrayChessBoard) validatePawnRanks() []string {
+	reasons := []string{}
+	for _, rank := range []int{0, BoardHeight - 1} {
+		for file := 0; file < BoardWidth; file++ {
+			piece := cb.board[rank][file]
+			if piece != nil && piece.Name == Pawn {
+				reasons = append(reasons, fmt.Sprintf("%s pawn on %s", colorName(piece.Color), squareName(Square{Rank: rank, File: file})))
+			}
+		}
+	}
+	return reasons
+}
+
+func (cb *ArrayChessBoard) validateChecks() []string {
+	reasons := []string{}
+	waiting := OppositeColor(cb.sideToMove)
+	if cb.kingAttacked(waiting) {
+		reasons = append(reasons, fmt.Sprintf("%s is in check but it is %s to move", colorName(waiting), colorName(cb.sideToMove)))
+	}
+	if kingSquare, ok := cb.kingSquares[cb.sideToMove]; ok {
+		if checkers, _ := cb.findCheckers(kingSquare, waiting); checkers > 2 {
+			reasons = append(reasons, fmt.Sprintf("%s is checked by %d pieces", colorName(cb.sideToMove), checkers))
+		}
+	}
+	return reasons
+}
+
+func (cb *ArrayChessBoard) validateCastlingRights() []string {
+	rights := []struct {
+		allowed bool
+		name    string
+		color   Color
+		rook    Square
+	}{
+		{cb.castlingRights.WhiteKingSide, "K", White, Square{Rank: 0, File: 7}},
+		{cb.castlingRights.WhiteQueenSide, "Q", White, Square{Rank: 0, File: 0}},
+		{cb.castlingRights.BlackKingSide, "k", Black, Square{Rank: 7, File: 7}},
+		{cb.castlingRights.BlackQueenSide, "q", Black, Square{Rank: 7, File: 0}},
+	}
+
+	reasons := []string{}
+	for _, right := range rights {
+		if !right.allowed {
+			continue
+		}
+		king := Square{Rank: right.rook.Rank, File: 4}
+		if !cb.hasPiece(king, King, right.color) {
+			reasons = append(reasons, fmt.Sprintf("castling right %s without the king on %s", right.name, squareName(king)))
+		}
+		if !cb.hasPiece(right.rook, Rook, right.color) {
+			reasons = append(reasons, fmt.Sprintf("castling right %s without the rook on %s", right.name, squareName(right.rook)))
+		}
+	}
+	return reasons
+}
+
+// validateEnPassant checks that the en passant square sits behind a pawn of
+// the side that just moved, with the squares it crossed empty.
+func (cb *ArrayChessBoard) validateEnPassant() []string {
+	if !cb.hasEnPassant {
+		return nil
+	}
+	ep := cb.enPassantSquare
+	expectedRank, direction := 5, -1
+	if cb.sideToMove == Black {
+		expectedRank, direction = 2, 1
+	}
+	if ep.Rank != expectedRank {
+		return []string{fmt.Sprintf("en passant square %s on the wrong rank", squareName(ep))}
+	}
+	mover := OppositeColor(cb.sideToMove)
+	if !cb.hasPiece(Square{Rank: ep.Rank + direction, File: ep.File}, Pawn, mover) {
+		return []string{fmt.Sprintf("en passant square %s without a %s pawn in front of it", squareName(ep), colorName(mover))}
+	}
+	if cb.IsOccupied(ep) || cb.IsOccupied(Square{Rank: ep.Rank - direction, File: ep.File}) {
+		return []string{fmt.Sprintf("en passant square %s with the pawn's path occupied", squareName(ep))}
+	}
+	return nil
+}
+
+func colorName(color Color) string {
+	if color == White {
+		return "white"
+	}
+	return "black"
+}
+
+func squareName(sq Square) string {
+	return fmt.Sprintf("%c%c", 'a'+sq.File, '1'+sq.Rank)
+}
@@ -0,0 +1,121 @@
+package board
+
+import (
+	"strings"
+	"testing"
+
+	logging "jesus_chess/domain/logging"
+)
+
+func TestSetPositionRejectsIllegalPositions(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	tests := []struct {
+		fen    string
+		reason string
+	}{
+		{"8/8/8/8/8/8/8/8 w - - 0 1", "white has 0 kings"},
+		{"4k3/8/8/8/8/8/8/4K1K1 w - - 0 1", "white has 2 kings"},
+		{"1QQQQQQ1/8/8/8/8/8/QQQQ4/K6k w - - 0 1", "white has 9 promoted pieces but only 8 missing pawns"},
+		{"4k3/8/8/8/8/8/PPPPPPPP/QQ2K3 w - - 0 1", "white has 1 promoted pieces but only 0 missing pawns"},
+		{"4k3/8/8/8/8/8/8/P3K3 w - - 0 1", "white pawn on a1"},
+		{"4k2p/8/8/8/8/8/8/4K3 w - - 0 1", "black pawn on h8"},
+		{"4r1k1/8/8/8/8/8/8/4K3 b - - 0 1", "white is in check but it is black to move"},
+		{"4k3/8/8/8/8/8/8/R3K3 w K - 0 1", "castling right K without the rook on h1"},
+		{"4k3/8/8/8/8/8/8/R4K1R w Q - 0 1", "castling right Q without the king on e1"},
+		{"4k3/8/8/8/4P3/8/8/4K3 b - e4 0 1", "en passant square e4 on the wrong rank"},
+		{"4k3/8/8/8/8/8/8/4K3 b - e3 0 1", "en passant square e3 without a white pawn in front of it"},
+	}
+
+	for _, test := range tests {
+		cb := NewArrayChessBoard(logger)
+		err := cb.SetPosition(test.fen)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.fen)
+			continue
+		}
+		positionErr, ok := err.(*InvalidPositionError)
+		if !ok {
+			t.Errorf("%s: expected an InvalidPositionError, got %v", test.fen, err)
+			continue
+		}
+		if !strings.Contains(positionErr.Error(), test.reason) {
+			t.Errorf("%s: expected reason %q, got %v", test.fen, test.reason, positionErr.Reasons)
+		}
+	}
+}
+
+func TestSetPositionRejectsMalformedFEN(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNRR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KX - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq z9 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0",
+	}
+	for _, fen := range fens {
+		cb := NewArrayChessBoard(logger)
+		if err := cb.SetPosition(fen); err == nil {
+			t.Errorf("%s: expected an error", fen)
+		}
+	}
+}
+
+func TestSetPositionLeavesBoardUntouchedOnError(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := NewArrayChessBoard(logger)
+	cb.MakeMove(Move{From: Square{Rank: 1, File: 4}, To: Square{Rank: 3, File: 4}})
+	before := cb.Display()
+
+	bad := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 x",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1",
+		"8/8/8/8/8/8/8/8 w - - 0 1",
+	}
+	for _, fen := range bad {
+		if err := cb.SetPosition(fen); err == nil {
+			t.Fatalf("%s: expected an error", fen)
+		}
+		if cb.Display() != before {
+			t.Fatalf("%s: board changed after failed SetPosition:\n%s", fen, cb.Display())
+		}
+		if err := cb.UndoMove(); err != nil {
+			t.Fatalf("%s: move history lost after failed SetPosition: %v", fen, err)
+		}
+		cb.MakeMove(Move{From: Square{Rank: 1, File: 4}, To: Square{Rank: 3, File: 4}})
+	}
+}
+
+func TestLenientValidationAcceptsIllegalPositions(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := NewArrayChessBoard(logger)
+	cb.SetValidationMode(LenientValidation)
+	if err := cb.SetPosition("4k3/8/8/8/8/8/8/P3K2R b K - 0 1"); err != nil {
+		t.Fatalf("expected lenient mode to accept the position, got %v", err)
+	}
+	if cb.Validate() == nil {
+		t.Fatalf("expected Validate to still report the problems")
+	}
+	if err := cb.SetPosition("4k3/8/8/8/8/8/8/4K3 w - - 0 x"); err == nil {
+		t.Fatalf("expected lenient mode to still reject malformed fen")
+	}
+}
@@ -0,0 +1,112 @@
+package board
+
+// Zobrist keys for hashing positions. They are generated from a fixed seed
+// so that hashes are stable across runs.
+var (
+	zobristPieces    [12][BoardHeight * BoardWidth]uint64
+	zobristCastling  [16]uint64
+	zobristEnPassant [BoardWidth]uint64
+	zobristSide      uint64
+)
+
+func init() {
+	state := uint64(0x9E3779B97F4A7C15)
+	next := func() uint64 {
+		// xorshift64*
+		state ^= state >> 12
+		state ^= state << 25
+		state ^= state >> 27
+		return state * 0x2545F4914F6CDD1D
+	}
+	for piece := range zobristPieces {
+		for sq := range zobristPieces[piece] {
+			zobristPieces[piece][sq] = next()
+		}
+	}
+	for i := range zobristCastling {
+		zobristCastling[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	zobristSide = next()
+}
+
+// Index numbers the squares from 0 (a1) to 63 (h8).
+func (sq Square) Index() int {
+	return sq.Rank*BoardWidth + sq.File
+}
+
+// SquareAt returns the square numbered index by Index.
+func SquareAt(index int) Square {
+	return Square{Rank: index / BoardWidth, File: index % BoardWidth}
+}
+
+// PieceIndex numbers the twelve pieces from 0 to 11, white pieces first in
+// the order pawn, knight, bishop, rook, queen, king.
+func PieceIndex(piece Piece) int {
+	index := 0
+	switch piece.Name {
+	case Knight:
+		index = 1
+	case Bishop:
+		index = 2
+	case Rook:
+		index = 3
+	case Queen:
+		index = 4
+	case King:
+		index = 5
+	}
+	if piece.Color == Black {
+		index += 6
+	}
+	return index
+}
+
+func pieceKey(piece *Piece, sq Square) uint64 {
+	return zobristPieces[PieceIndex(*piece)][sq.Index()]
+}
+
+func castlingKey(rights CastlingRights) uint64 {
+	index := 0
+	if rights.WhiteKingSide {
+		index |= 1
+	}
+	if rights.WhiteQueenSide {
+		index |= 2
+	}
+	if rights.BlackKingSide {
+		index |= 4
+	}
+	if rights.BlackQueenSide {
+		index |= 8
+	}
+	return zobristCastling[index]
+}
+
+// computeHash hashes the position from scratch.
+func (cb *ArrayChessBoard) computeHash() uint64 {
+	var hash uint64
+	for rank := 0; rank < BoardHeight; rank++ {
+		for file := 0; file < BoardWidth; file++ {
+			if piece := cb.board[rank][file]; piece != nil {
+				hash ^= pieceKey(piece, Square{Rank: rank, File: file})
+			}
+		}
+	}
+	hash ^= castlingKey(cb.castlingRights)
+	if cb.hasEnPassant {
+		hash ^= zobristEnPassant[cb.enPassantSquare.File]
+	}
+	if cb.sideToMove == Black {
+		hash ^= zobristSide
+	}
+	return hash
+}
+
+// Hash returns the Zobrist hash of the position. Positions that differ only
+// in their move counters hash the same.
+func (cb *ArrayChessBoard) Hash() uint64 {
+	return cb.hash
+}
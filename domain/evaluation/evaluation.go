@@ -0,0 +1,164 @@
+package evaluation
+
+import (
+	board "jesus_chess/domain/board"
+)
+
+const (
+	PawnValue   = 100
+	KnightValue = 320
+	BishopValue = 330
+	RookValue   = 500
+	QueenValue  = 900
+	KingValue   = 20000
+)
+
+// PieceValue returns the material value of a piece in centipawns.
+func PieceValue(name board.PieceName) int {
+	switch name {
+	case board.Pawn:
+		return PawnValue
+	case board.Knight:
+		return KnightValue
+	case board.Bishop:
+		return BishopValue
+	case board.Rook:
+		return RookValue
+	case board.Queen:
+		return QueenValue
+	default:
+		return KingValue
+	}
+}
+
+// Piece-square tables from white's side, written with rank 8 first so they
+// read like a diagram. Black pieces use the vertically mirrored square.
+var (
+	pawnTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	knightTable = [64]int{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	}
+	bishopTable = [64]int{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	}
+	rookTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0,
+	}
+	queenTable = [64]int{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	}
+	kingMiddlegameTable = [64]int{
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
+	}
+	kingEndgameTable = [64]int{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50,
+	}
+)
+
+// maxPhase is the game phase with all minor and major pieces on the board.
+const maxPhase = 24
+
+// Evaluate returns the static evaluation of the position in centipawns from
+// the side to move's point of view: material plus piece-square bonuses, with
+// the king table blended from middlegame to endgame as pieces come off.
+func Evaluate(chessBoard board.ChessBoard) int {
+	score := 0
+	phase := 0
+	kingMiddlegame, kingEndgame := 0, 0
+
+	for rank := 0; rank < board.BoardHeight; rank++ {
+		for file := 0; file < board.BoardWidth; file++ {
+			piece := chessBoard.PieceAt(board.Square{Rank: rank, File: file})
+			if piece == nil {
+				continue
+			}
+			sign := 1
+			index := (board.BoardHeight-1-rank)*board.BoardWidth + file
+			if piece.Color == board.Black {
+				sign = -1
+				index = rank*board.BoardWidth + file
+			}
+
+			switch piece.Name {
+			case board.Pawn:
+				score += sign * (PawnValue + pawnTable[index])
+			case board.Knight:
+				score += sign * (KnightValue + knightTable[index])
+				phase++
+			case board.Bishop:
+				score += sign * (BishopValue + bishopTable[index])
+				phase++
+			case board.Rook:
+				score += sign * (RookValue + rookTable[index])
+				phase += 2
+			case board.Queen:
+				score += sign * (QueenValue + queenTable[index])
+				phase += 4
+			case board.King:
+				kingMiddlegame += sign * kingMiddlegameTable[index]
+				kingEndgame += sign * kingEndgameTable[index]
+			}
+		}
+	}
+
+	if phase > maxPhase {
+		phase = maxPhase
+	}
+	score += (kingMiddlegame*phase + kingEndgame*(maxPhase-phase)) / maxPhase
+
+	if chessBoard.SideToMove() == board.Black {
+		return -score
+	}
+	return score
+}
@@ -0,0 +1,78 @@
+package evaluation
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+)
+
+func TestEvaluateStartPositionIsBalanced(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := board.NewArrayChessBoard(logger)
+	if score := Evaluate(cb); score != 0 {
+		t.Errorf("expected the start position to evaluate to 0, got %d", score)
+	}
+}
+
+func TestEvaluateCountsMaterialForSideToMove(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	cb := board.NewArrayChessBoard(logger)
+	cb.SetPosition("4k3/8/8/8/8/8/8/3QK3 w - - 0 1")
+	white := Evaluate(cb)
+	cb.SetPosition("4k3/8/8/8/8/8/8/3QK3 b - - 0 1")
+	black := Evaluate(cb)
+
+	if white < QueenValue-100 {
+		t.Errorf("expected white to be about a queen up, got %d", white)
+	}
+	if black != -white {
+		t.Errorf("expected the score to flip with the side to move, got %d and %d", white, black)
+	}
+}
+
+// TestEvaluationSymmetry checks that every position in the board package's
+// corpus evaluates the same as its colour-flipped mirror.
+func TestEvaluationSymmetry(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	file, err := os.Open("../board/testdata/positions.fen")
+	if err != nil {
+		t.Fatalf("failed to open position corpus: %v", err)
+	}
+	defer file.Close()
+
+	cb := board.NewArrayChessBoard(logger)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fen := scanner.Text()
+		mirrored, err := board.MirrorFEN(fen)
+		if err != nil {
+			t.Fatalf("failed to mirror %s: %v", fen, err)
+		}
+		mirror, err := board.FlipColorsFEN(mirrored)
+		if err != nil {
+			t.Fatalf("failed to flip colours of %s: %v", mirrored, err)
+		}
+
+		cb.SetPosition(fen)
+		original := Evaluate(cb)
+		cb.SetPosition(mirror)
+		if flipped := Evaluate(cb); flipped != original {
+			t.Errorf("evaluation differs: %s scores %d, its mirror %s scores %d", fen, original, mirror, flipped)
+		}
+	}
+}
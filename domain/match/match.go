@@ -0,0 +1,160 @@
+// Package match plays games between move finders, to measure how strong
+// they are against each other.
+package match
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+	search "jesus_chess/domain/search"
+)
+
+// MaxGamePlies is the length after which a game still being played is
+// adjudicated a draw.
+const MaxGamePlies = 300
+
+type Outcome int
+
+const (
+	Draw Outcome = iota
+	WhiteWins
+	BlackWins
+)
+
+// Openings are balanced positions a few moves into common openings, from
+// which the games of a match start so that deterministic players do not
+// repeat the same game.
+var Openings = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3",
+	"rnbqkb1r/pp2pppp/3p1n2/8/3NP3/8/PPP2PPP/RNBQKB1R w KQkq - 1 5",
+	"rnbqkb1r/ppp1pppp/5n2/3p4/2PP4/8/PP2PPPP/RNBQKBNR w KQkq - 1 3",
+	"rnbqkbnr/pp3ppp/4p3/2ppP3/3P4/8/PPP2PPP/RNBQKBNR w KQkq - 0 4",
+	"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4",
+	"rnbqk2r/pppp1ppp/4pn2/8/1bPP4/2N5/PP2PPPP/R1BQKBNR w KQkq - 2 4",
+	"rnbqkbnr/pp1ppppp/8/2p5/2P5/8/PP1PPPPP/RNBQKBNR w KQkq - 0 2",
+}
+
+// PlayGame plays a game from fen between white and black, each choosing
+// its moves under limits, and returns its outcome. A game ends in mate or
+// stalemate, or is drawn by the fifty-move rule, threefold repetition,
+// insufficient material or after MaxGamePlies plies.
+func PlayGame(ctx context.Context, logger *logging.Logger, fen string, white, black search.MoveFinder, limits search.SearchLimits) (Outcome, error) {
+	chessBoard := board.NewArrayChessBoard(logger)
+	if err := chessBoard.SetPosition(fen); err != nil {
+		return Draw, fmt.Errorf("failed to set position: %w", err)
+	}
+	for _, finder := range []search.MoveFinder{white, black} {
+		if resetter, ok := finder.(search.GameResetter); ok {
+			resetter.NewGame()
+		}
+	}
+
+	for ply := 0; ply < MaxGamePlies; ply++ {
+		if outcome, over := gameOver(chessBoard); over {
+			return outcome, nil
+		}
+		finder := white
+		if chessBoard.SideToMove() == board.Black {
+			finder = black
+		}
+		result, err := finder.FindBestMove(ctx, chessBoard, limits)
+		if err != nil {
+			return Draw, fmt.Errorf("failed to find a move in %s: %w", chessBoard.FEN(), err)
+		}
+		if err := chessBoard.MakeMove(result.BestMove); err != nil {
+			return Draw, fmt.Errorf("failed to make a move in %s: %w", chessBoard.FEN(), err)
+		}
+	}
+	return Draw, nil
+}
+
+// gameOver returns the outcome of a game that has ended.
+func gameOver(chessBoard board.ChessBoard) (Outcome, bool) {
+	if len(chessBoard.GenerateLegalMoves()) == 0 {
+		switch {
+		case !chessBoard.InCheck(chessBoard.SideToMove()):
+			return Draw, true
+		case chessBoard.SideToMove() == board.White:
+			return BlackWins, true
+		default:
+			return WhiteWins, true
+		}
+	}
+	if chessBoard.HalfmoveClock() >= 100 || chessBoard.RepetitionCount() >= 2 || chessBoard.HasInsufficientMaterial() {
+		return Draw, true
+	}
+	return Draw, false
+}
+
+// Score counts a player's results in a match.
+type Score struct {
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+func (s Score) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// Points returns the share of the points scored, a draw counting half.
+func (s Score) Points() float64 {
+	if s.Games() == 0 {
+		return 0.5
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// EloDifference estimates how many Elo points stronger the player is than
+// its opponent from the points scored. A clean sweep counts half a point
+// short of one, as the difference it implies is unbounded.
+func (s Score) EloDifference() float64 {
+	points := s.Points()
+	if games := float64(s.Games()); games > 0 {
+		points = min(max(points, 0.5/games), 1-0.5/games)
+	}
+	return -400 * math.Log10(1/points-1)
+}
+
+// Performance returns the rating the score shows the player playing at
+// against an opponent rated opponentElo.
+func (s Score) Performance(opponentElo float64) float64 {
+	return opponentElo + s.EloDifference()
+}
+
+// Swept reports whether one side won every game, which only bounds the
+// difference between the players.
+func (s Score) Swept() bool {
+	return s.Games() > 0 && (s.Wins == s.Games() || s.Losses == s.Games())
+}
+
+// Match plays player against opponent from each of openings, once with
+// each color, and returns player's score.
+func Match(ctx context.Context, logger *logging.Logger, player, opponent search.MoveFinder, openings []string, limits search.SearchLimits) (Score, error) {
+	var score Score
+	for _, fen := range openings {
+		for _, playerIsWhite := range []bool{true, false} {
+			white, black, playerWins := player, opponent, WhiteWins
+			if !playerIsWhite {
+				white, black, playerWins = opponent, player, BlackWins
+			}
+			outcome, err := PlayGame(ctx, logger, fen, white, black, limits)
+			if err != nil {
+				return score, err
+			}
+			switch outcome {
+			case Draw:
+				score.Draws++
+			case playerWins:
+				score.Wins++
+			default:
+				score.Losses++
+			}
+		}
+	}
+	return score, nil
+}
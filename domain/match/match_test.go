@@ -0,0 +1,199 @@
+package match
+
+import (
+	"context"
+	"flag"
+	"math"
+	"strconv"
+	"testing"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+	search "jesus_chess/domain/search"
+)
+
+var calibrate = flag.Bool("calibrate", false, "play the matches checking the spacing of the UCI_Elo scale")
+
+func newTestLogger(t testing.TB) *logging.Logger {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	return logger
+}
+
+func TestOpeningsAreLegal(t *testing.T) {
+	logger := newTestLogger(t)
+	for _, fen := range Openings {
+		if err := board.NewArrayChessBoard(logger).SetPosition(fen); err != nil {
+			t.Errorf("%s: %v", fen, err)
+		}
+	}
+}
+
+func TestPlayGame(t *testing.T) {
+	logger := newTestLogger(t)
+	tests := []struct {
+		name    string
+		fen     string
+		outcome Outcome
+	}{
+		{"white mates", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", WhiteWins},
+		{"black mates", "r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1", BlackWins},
+		{"bare kings", "8/8/3k4/8/8/3K4/8/8 w - - 0 1", Draw},
+		{"stalemate", "k7/8/1Q6/8/8/8/8/K7 b - - 0 1", Draw},
+	}
+	for _, test := range tests {
+		finder := search.NewAlphaBetaMoveFinder(logger, 2)
+		outcome, err := PlayGame(context.Background(), logger, test.fen, finder, finder, search.SearchLimits{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if outcome != test.outcome {
+			t.Errorf("%s: expected outcome %d, got %d", test.name, test.outcome, outcome)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		score  Score
+		points float64
+		elo    float64
+	}{
+		{Score{Wins: 5, Draws: 2, Losses: 5}, 0.5, 0},
+		{Score{Wins: 3, Losses: 1}, 0.75, 190.8},
+		{Score{Losses: 4}, 0, -338.0},
+	}
+	for _, test := range tests {
+		if points := test.score.Points(); points != test.points {
+			t.Errorf("%+v: expected %.2f points, got %.2f", test.score, test.points, points)
+		}
+		if elo := test.score.EloDifference(); math.Abs(elo-test.elo) > 0.1 {
+			t.Errorf("%+v: expected %.1f Elo, got %.1f", test.score, test.elo, elo)
+		}
+	}
+}
+
+func TestPerformance(t *testing.T) {
+	tests := []struct {
+		score       Score
+		performance float64
+		swept       bool
+	}{
+		{Score{Wins: 5, Draws: 2, Losses: 5}, 1500, false},
+		{Score{Wins: 3, Losses: 1}, 1690.8, false},
+		{Score{Losses: 4}, 1162.0, true},
+		{Score{Wins: 2}, 1690.8, true},
+	}
+	for _, test := range tests {
+		if performance := test.score.Performance(1500); math.Abs(performance-test.performance) > 0.1 {
+			t.Errorf("%+v: expected a performance of %.1f, got %.1f", test.score, test.performance, performance)
+		}
+		if swept := test.score.Swept(); swept != test.swept {
+			t.Errorf("%+v: expected swept %v, got %v", test.score, test.swept, swept)
+		}
+	}
+}
+
+func TestMatchAlternatesColors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("plays whole games")
+	}
+	logger := newTestLogger(t)
+	player := search.NewAlphaBetaMoveFinder(logger, 2)
+	score, err := Match(context.Background(), logger, player, search.NewRandomMoveFinder(logger, 1), Openings[:1], search.SearchLimits{})
+	if err != nil {
+		t.Fatalf("match failed: %v", err)
+	}
+	if score.Games() != 2 || score.Losses > 0 {
+		t.Errorf("expected two games without a loss against random moves, got %+v", score)
+	}
+}
+
+// newLimitedPlayer returns a player whose strength is limited to elo.
+func newLimitedPlayer(t *testing.T, logger *logging.Logger, elo int) *search.AlphaBetaMoveFinder {
+	player := search.NewAlphaBetaMoveFinder(logger, 4)
+	if err := player.SetOption("UCI_LimitStrength", "true"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if err := player.SetOption("UCI_Elo", strconv.Itoa(elo)); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	return player
+}
+
+func TestLowerEloScoresLess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("plays whole games")
+	}
+	logger := newTestLogger(t)
+	score, err := Match(context.Background(), logger, newLimitedPlayer(t, logger, search.MinElo), newLimitedPlayer(t, logger, 2000), Openings[:2], search.SearchLimits{})
+	if err != nil {
+		t.Fatalf("match failed: %v", err)
+	}
+	if score.Points() >= 0.5 {
+		t.Errorf("expected UCI_Elo %d to score less than 2000, got %+v", search.MinElo, score)
+	}
+}
+
+// scaleSettings are the UCI_Elo settings the strength scale is checked at,
+// and scaleOpponents the fixed-depth searches they are all matched
+// against.
+var (
+	scaleSettings  = []int{search.MinElo, 1200, 1600, 2000}
+	scaleOpponents = []int{1, 2, 3}
+)
+
+// scaleTolerance is how far, in Elo, the gap measured between two
+// settings may stray from the gap between them, about the error of a
+// match of the openings' length.
+const scaleTolerance = 200
+
+// TestStrengthScale is a relative check of the UCI_Elo scale, not a
+// calibration: the opponents are unrated, so it cannot tell the Elo a
+// setting plays at, only whether the settings are as far apart as their
+// values say. Each setting is matched against the same fixed-depth
+// searches, and the gap between consecutive settings is the average
+// difference of their results against each opponent, leaving out the
+// opponents either swept, which only bound it. It plays for minutes, so
+// it only runs with -calibrate.
+func TestStrengthScale(t *testing.T) {
+	if !*calibrate {
+		t.Skip("run with -calibrate")
+	}
+	logger := newTestLogger(t)
+	scores := make([][]Score, len(scaleSettings))
+	for i, elo := range scaleSettings {
+		for _, depth := range scaleOpponents {
+			score, err := Match(context.Background(), logger, newLimitedPlayer(t, logger, elo), search.NewAlphaBetaMoveFinder(logger, depth), Openings, search.SearchLimits{})
+			if err != nil {
+				t.Fatalf("match failed: %v", err)
+			}
+			t.Logf("UCI_Elo %d against depth %d: %+v, %.0f%%, %+.0f Elo", elo, depth, score, 100*score.Points(), score.EloDifference())
+			scores[i] = append(scores[i], score)
+		}
+	}
+
+	for i := 1; i < len(scaleSettings); i++ {
+		gaps := 0.0
+		measured := 0
+		for j := range scaleOpponents {
+			lower, higher := scores[i-1][j], scores[i][j]
+			if !lower.Swept() && !higher.Swept() {
+				gaps += higher.EloDifference() - lower.EloDifference()
+				measured++
+			}
+		}
+		expected := scaleSettings[i] - scaleSettings[i-1]
+		if measured == 0 {
+			t.Errorf("UCI_Elo %d to %d: every opponent was swept, so no gap was measured", scaleSettings[i-1], scaleSettings[i])
+			continue
+		}
+		gap := gaps / float64(measured)
+		t.Logf("UCI_Elo %d to %d: %.0f Elo apart", scaleSettings[i-1], scaleSettings[i], gap)
+		if math.Abs(gap-float64(expected)) > scaleTolerance {
+			t.Errorf("UCI_Elo %d to %d: expected a gap within %d Elo of %d, measured %.0f", scaleSettings[i-1], scaleSettings[i], scaleTolerance, expected, gap)
+		}
+	}
+}
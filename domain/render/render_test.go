@@ -0,0 +1,95 @@
+package render
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+)
+
+func newTestBoard(t *testing.T, fen string) *board.ArrayChessBoard {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	cb := board.NewArrayChessBoard(logger)
+	if err := cb.SetPosition(fen); err != nil {
+		t.Fatalf("failed to set position %s: %v", fen, err)
+	}
+	return cb
+}
+
+func TestTextASCIIWithCoordinates(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	move := board.Move{From: board.Square{Rank: 1, File: 4}, To: board.Square{Rank: 3, File: 4}}
+
+	got := Text(cb, Options{Coordinates: true, LastMove: &move, FEN: true})
+	expected := "" +
+		"8  r  n  b  q  k  b  n  r \n" +
+		"7  p  p  p  p  p  p  p  p \n" +
+		"6  .  .  .  .  .  .  .  . \n" +
+		"5  .  .  .  .  .  .  .  . \n" +
+		"4  .  .  .  . (P) .  .  . \n" +
+		"3  .  .  .  .  .  .  .  . \n" +
+		"2  P  P  P  P (.) P  P  P \n" +
+		"1  R  N  B  Q  K  B  N  R \n" +
+		"   a  b  c  d  e  f  g  h \n" +
+		"\n" +
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1\n"
+	if got != expected {
+		t.Errorf("unexpected diagram:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestTextUnicodeFlippedWithCheck(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/4r3/4K3 w - - 0 1")
+
+	got := Text(cb, Options{Charset: Unicode, HighlightCheck: true, Flipped: true})
+	lines := strings.Split(got, "\n")
+	if !strings.Contains(lines[0], "[♔]") {
+		t.Errorf("expected the checked white king highlighted on the first line when flipped, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "♜") {
+		t.Errorf("expected the black rook on the second line, got %q", lines[1])
+	}
+}
+
+func TestTextColor(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/4r3/4K3 w - - 0 1")
+
+	got := Text(cb, Options{Color: true, HighlightCheck: true})
+	if !strings.Contains(got, ansiCheck+ansiWhitePiece+" K ") {
+		t.Errorf("expected the checked king drawn on the check background")
+	}
+	if !strings.Contains(got, ansiBlackPiece+" r ") {
+		t.Errorf("expected the black rook drawn in the black piece colour")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+
+	got := SVG(cb, SVGOptions{Coordinates: true})
+	var doc struct {
+		XMLName xml.Name `xml:"svg"`
+		Rects   []struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"rect"`
+		Texts []string `xml:"text"`
+	}
+	if err := xml.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatalf("expected well-formed svg, got %v", err)
+	}
+	if len(doc.Rects) != 64 {
+		t.Errorf("expected 64 squares, got %d", len(doc.Rects))
+	}
+	if len(doc.Texts) != 32+16 {
+		t.Errorf("expected 32 pieces and 16 coordinate labels, got %d texts", len(doc.Texts))
+	}
+	// a1 is dark and drawn in the bottom left corner
+	if doc.Rects[56].Fill != svgDarkSquare {
+		t.Errorf("expected a1 to be a dark square, got %s", doc.Rects[56].Fill)
+	}
+}
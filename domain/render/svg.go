@@ -0,0 +1,89 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	board "jesus_chess/domain/board"
+)
+
+// SVGOptions controls how a position is drawn as an SVG image.
+type SVGOptions struct {
+	// SquareSize is the width of one square in pixels. Defaults to 45.
+	SquareSize int
+	// Coordinates adds rank numbers and file letters in a margin.
+	Coordinates bool
+	// LastMove, when set, highlights the move's origin and destination.
+	LastMove *board.Move
+	// HighlightCheck marks the king of the side to move when it is in check.
+	HighlightCheck bool
+	// Flipped draws the board from black's side.
+	Flipped bool
+}
+
+const (
+	svgLightSquare = "#f0d9b5"
+	svgDarkSquare  = "#b58863"
+	svgLastMove    = "#cdd26a"
+	svgCheck       = "#e05050"
+)
+
+// SVG draws the position as a standalone SVG document. Pieces are drawn
+// with Unicode chess glyphs so the output needs no external assets.
+func SVG(chessBoard board.ChessBoard, opts SVGOptions) string {
+	size := opts.SquareSize
+	if size <= 0 {
+		size = 45
+	}
+	margin := 0
+	if opts.Coordinates {
+		margin = size / 2
+	}
+	width := board.BoardWidth*size + 2*margin
+	height := board.BoardHeight*size + 2*margin
+	checkedKing, inCheck := checkedKingSquare(chessBoard)
+
+	var svg strings.Builder
+	svg.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height))
+	svg.WriteString(fmt.Sprintf(`<title>%s</title>`+"\n", chessBoard.FEN()))
+
+	for row, rank := range rankOrder(opts.Flipped) {
+		for column, file := range fileOrder(opts.Flipped) {
+			sq := board.Square{Rank: rank, File: file}
+			x := margin + column*size
+			y := margin + row*size
+
+			fill := svgDarkSquare
+			if isLightSquare(sq) {
+				fill = svgLightSquare
+			}
+			if opts.LastMove != nil && (sq == opts.LastMove.From || sq == opts.LastMove.To) {
+				fill = svgLastMove
+			}
+			if opts.HighlightCheck && inCheck && sq == checkedKing {
+				fill = svgCheck
+			}
+			svg.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", x, y, size, size, fill))
+
+			if piece := chessBoard.PieceAt(sq); piece != nil {
+				svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n",
+					x+size/2, y+size/2, size*4/5, unicodePieces[*piece]))
+			}
+		}
+	}
+
+	if opts.Coordinates {
+		fontSize := size / 3
+		for row, rank := range rankOrder(opts.Flipped) {
+			svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%d</text>`+"\n",
+				margin/2, margin+row*size+size/2, fontSize, rank+1))
+		}
+		for column, file := range fileOrder(opts.Flipped) {
+			svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%c</text>`+"\n",
+				margin+column*size+size/2, height-margin/2, fontSize, 'a'+file))
+		}
+	}
+
+	svg.WriteString("</svg>\n")
+	return svg.String()
+}
@@ -0,0 +1,188 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	board "jesus_chess/domain/board"
+)
+
+type Charset int
+
+const (
+	ASCII Charset = iota
+	Unicode
+)
+
+// Options controls how a position is drawn as text.
+type Options struct {
+	Charset Charset
+	// Coordinates adds rank numbers and file letters around the board.
+	Coordinates bool
+	// Color draws the squares and highlights with ANSI escape codes.
+	// Without it, highlighted squares are bracketed instead.
+	Color bool
+	// LastMove, when set, highlights the move's origin and destination.
+	LastMove *board.Move
+	// HighlightCheck marks the king of the side to move when it is in check.
+	HighlightCheck bool
+	// FEN writes the position's FEN under the diagram.
+	FEN bool
+	// Flipped draws the board from black's side.
+	Flipped bool
+}
+
+const (
+	ansiReset       = "\x1b[0m"
+	ansiLightSquare = "\x1b[48;5;180m"
+	ansiDarkSquare  = "\x1b[48;5;137m"
+	ansiLastMove    = "\x1b[48;5;143m"
+	ansiCheck       = "\x1b[48;5;160m"
+	ansiWhitePiece  = "\x1b[1;97m"
+	ansiBlackPiece  = "\x1b[1;30m"
+)
+
+var unicodePieces = map[board.Piece]string{
+	{Name: board.King, Color: board.White}:   "♔",
+	{Name: board.Queen, Color: board.White}:  "♕",
+	{Name: board.Rook, Color: board.White}:   "♖",
+	{Name: board.Bishop, Color: board.White}: "♗",
+	{Name: board.Knight, Color: board.White}: "♘",
+	{Name: board.Pawn, Color: board.White}:   "♙",
+	{Name: board.King, Color: board.Black}:   "♚",
+	{Name: board.Queen, Color: board.Black}:  "♛",
+	{Name: board.Rook, Color: board.Black}:   "♜",
+	{Name: board.Bishop, Color: board.Black}: "♝",
+	{Name: board.Knight, Color: board.Black}: "♞",
+	{Name: board.Pawn, Color: board.Black}:   "♟",
+}
+
+type highlight int
+
+const (
+	highlightNone highlight = iota
+	highlightLastMove
+	highlightCheck
+)
+
+// Text draws the position as a text diagram.
+func Text(chessBoard board.ChessBoard, opts Options) string {
+	var result strings.Builder
+	checkedKing, inCheck := checkedKingSquare(chessBoard)
+
+	for _, rank := range rankOrder(opts.Flipped) {
+		if opts.Coordinates {
+			result.WriteString(fmt.Sprintf("%d ", rank+1))
+		}
+		for _, file := range fileOrder(opts.Flipped) {
+			sq := board.Square{Rank: rank, File: file}
+			mark := highlightNone
+			if opts.LastMove != nil && (sq == opts.LastMove.From || sq == opts.LastMove.To) {
+				mark = highlightLastMove
+			}
+			if opts.HighlightCheck && inCheck && sq == checkedKing {
+				mark = highlightCheck
+			}
+			result.WriteString(cell(chessBoard.PieceAt(sq), sq, mark, opts))
+		}
+		result.WriteString("\n")
+	}
+
+	if opts.Coordinates {
+		result.WriteString("  ")
+		for _, file := range fileOrder(opts.Flipped) {
+			result.WriteString(fmt.Sprintf(" %c ", 'a'+file))
+		}
+		result.WriteString("\n")
+	}
+	if opts.FEN {
+		result.WriteString("\n" + chessBoard.FEN() + "\n")
+	}
+	return result.String()
+}
+
+// cell draws one square three characters wide.
+func cell(piece *board.Piece, sq board.Square, mark highlight, opts Options) string {
+	symbol := "."
+	if piece != nil {
+		symbol = piece.Symbol()
+		if opts.Charset == Unicode {
+			symbol = unicodePieces[*piece]
+		}
+	} else if opts.Color {
+		symbol = " "
+	}
+
+	if !opts.Color {
+		switch mark {
+		case highlightLastMove:
+			return "(" + symbol + ")"
+		case highlightCheck:
+			return "[" + symbol + "]"
+		}
+		return " " + symbol + " "
+	}
+
+	background := ansiDarkSquare
+	if isLightSquare(sq) {
+		background = ansiLightSquare
+	}
+	switch mark {
+	case highlightLastMove:
+		background = ansiLastMove
+	case highlightCheck:
+		background = ansiCheck
+	}
+	foreground := ""
+	if piece != nil {
+		foreground = ansiWhitePiece
+		if piece.Color == board.Black {
+			foreground = ansiBlackPiece
+		}
+	}
+	return background + foreground + " " + symbol + " " + ansiReset
+}
+
+func checkedKingSquare(chessBoard board.ChessBoard) (board.Square, bool) {
+	side := chessBoard.SideToMove()
+	if !chessBoard.InCheck(side) {
+		return board.Square{}, false
+	}
+	for rank := 0; rank < board.BoardHeight; rank++ {
+		for file := 0; file < board.BoardWidth; file++ {
+			sq := board.Square{Rank: rank, File: file}
+			if piece := chessBoard.PieceAt(sq); piece != nil && piece.Name == board.King && piece.Color == side {
+				return sq, true
+			}
+		}
+	}
+	return board.Square{}, false
+}
+
+func isLightSquare(sq board.Square) bool {
+	return (sq.Rank+sq.File)%2 == 1
+}
+
+func rankOrder(flipped bool) []int {
+	ranks := make([]int, board.BoardHeight)
+	for i := range ranks {
+		if flipped {
+			ranks[i] = i
+		} else {
+			ranks[i] = board.BoardHeight - 1 - i
+		}
+	}
+	return ranks
+}
+
+func fileOrder(flipped bool) []int {
+	files := make([]int, board.BoardWidth)
+	for i := range files {
+		if flipped {
+			files[i] = board.BoardWidth - 1 - i
+		} else {
+			files[i] = i
+		}
+	}
+	return files
+}
@@ -0,0 +1,1248 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	board "jesus_chess/domain/board"
+	evaluation "jesus_chess/domain/evaluation"
+	logging "jesus_chess/domain/logging"
+)
+
+const (
+	// checkInterval is the number of nodes searched between checks of the
+	// clock and the context.
+	checkInterval = 1024
+	// deltaMargin is added to the value of a capture before deciding that
+	// it cannot raise alpha, to allow for positional gains.
+	deltaMargin = 200
+	// maxQuietsTried bounds the quiet moves per node whose history is
+	// lowered when a later quiet move causes a cutoff.
+	maxQuietsTried = 64
+	// MaxThreads bounds the Threads option.
+	MaxThreads = 64
+)
+
+// AlphaBetaMoveFinder searches the game tree with negamax and alpha-beta
+// pruning, deepening one ply at a time until a limit is reached and
+// reporting each completed iteration. Mates score by their distance from
+// the root, and stalemate, repetition, the fifty-move rule and insufficient
+// material score as draws.
+//
+// With more than one thread the search is Lazy SMP: helper threads search
+// clones of the board with their own history alongside the main thread,
+// sharing only the transposition table, and the threads vote on the move
+// to play. With a single thread, the default, no goroutines are started
+// and the search is deterministic, which makes it reproducible when
+// debugging.
+type AlphaBetaMoveFinder struct {
+	logger *logging.Logger
+	// depth is searched when the limits set neither a depth nor a way to
+	// stop the search.
+	depth       int
+	onInfo      InfoCallback
+	timeManager *TimeManager
+	tt          *TranspositionTable
+	history     *History
+	selectivity Selectivity
+	extensions  Extensions
+	// helpers are the threads beyond the main one
+	helpers []*AlphaBetaMoveFinder
+	// multiPV is the number of best lines searched and reported
+	multiPV int
+	// rootExcluded holds the root moves of the lines already searched in
+	// the current iteration, and lines those of the last completed one
+	rootExcluded []board.Move
+	lines        []SearchInfo
+	strength     Strength
+	// contempt and the opponent's rating set drawContempt, the contempt
+	// applied to draws in the current search, from the point of view of
+	// rootSide
+	contempt     int
+	opponent     Opponent
+	drawContempt int
+	rootSide     board.Color
+	// ttContempt is the contempt, from white's point of view, the scores
+	// of draws in the transposition table were searched with
+	ttContempt int
+	// rng chooses the move to play when the strength is limited
+	rng *rand.Rand
+
+	ctx    context.Context
+	limits SearchLimits
+	// pondering is set while the search waits for ponderhit to start the
+	// clock, and clockStart is when it started
+	pondering   bool
+	clockStart  time.Time
+	deadline    time.Time
+	hasDeadline bool
+	canStop     bool
+	stopped     bool
+
+	nodes    int
+	selDepth int
+	// reportedNodes publishes nodes to the main thread every checkInterval
+	// nodes
+	reportedNodes atomic.Int64
+	// result is a helper's last completed iteration
+	result  SearchInfo
+	pickers [MaxPly]MovePicker
+	// stats counts what the current iteration does, and iterations holds
+	// the statistics of the completed ones
+	stats      SearchStats
+	iterations []SearchStats
+	// tracer, if set, records the main thread's tree
+	tracer *Tracer
+
+	// stack[ply] is the move being searched at ply, or a null move if
+	// isNull[ply] is set; rootPrevious is the move that led to the root,
+	// if hasRootPrevious is set
+	stack           [MaxPly]board.Move
+	isNull          [MaxPly]bool
+	rootPrevious    board.Move
+	hasRootPrevious bool
+	quietsTried     [MaxPly][maxQuietsTried]board.Move
+	// verifying is set during the verification search of a null move
+	// cutoff, which must not prune with a null move itself
+	verifying bool
+	// rootDepth is the depth of the current iteration, which bounds the
+	// plies pathExtensions[ply] the path to ply has been extended by
+	rootDepth      int
+	pathExtensions [MaxPly]int
+	// excluded[ply] is skipped at ply if hasExcluded[ply] is set, while
+	// testing whether it is singular
+	excluded    [MaxPly]board.Move
+	hasExcluded [MaxPly]bool
+
+	// pv[ply] holds the principal variation found from ply onwards
+	pv       [MaxPly][MaxPly]board.Move
+	pvLength [MaxPly]int
+}
+
+func (f *AlphaBetaMoveFinder) SetInfoCallback(callback InfoCallback) {
+	f.onInfo = callback
+}
+
+// SetTracer records the tree of each search in tracer from then on, or
+// stops recording if tracer is nil.
+func (f *AlphaBetaMoveFinder) SetTracer(tracer *Tracer) {
+	f.tracer = tracer
+}
+
+func (f *AlphaBetaMoveFinder) FindBestMove(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) (*SearchResult, error) {
+	if limits.Mate > 0 {
+		// The mate search has no iterations to report
+		f.iterations = nil
+		return f.findMate(ctx, chessBoard, limits)
+	}
+	level, weakened := f.strength.level()
+	if weakened {
+		multiPV := f.multiPV
+		f.multiPV = max(multiPV, skillMultiPV)
+		defer func() {
+			f.multiPV = multiPV
+		}()
+		limits = weakenLimits(limits, level)
+	}
+	info, err := f.iterativeDeepening(ctx, chessBoard, limits)
+	if err != nil {
+		return nil, err
+	}
+	if weakened {
+		info = pickWeakLine(f.lines, level, f.rng)
+	}
+	move := info.PV[0]
+	f.logger.Debug(fmt.Sprintf("alpha-beta move selected: %s from file %d, rank %d to file %d, rank %d, score %d, depth %d, nodes %d", move.Piece.Name, move.From.File, move.From.Rank, move.To.File, move.To.Rank, info.Score, info.Depth, info.Nodes))
+
+	waitForGUI(ctx, limits)
+	result := resultFromPV(info.PV, info.Score)
+	if result.PonderMove == nil {
+		result.PonderMove = f.ponderMoveFromTT(chessBoard, result.BestMove)
+	}
+	return result, nil
+}
+
+// ponderMoveFromTT looks the expected reply to move up in the
+// transposition table, for when the principal variation ends with move.
+func (f *AlphaBetaMoveFinder) ponderMoveFromTT(chessBoard board.ChessBoard, move board.Move) *board.Move {
+	if err := chessBoard.MakeMove(move); err != nil {
+		return nil
+	}
+	defer chessBoard.UndoMove()
+	entry, ok := f.tt.Probe(chessBoard.Hash(), 1)
+	if !ok || !entry.HasMove {
+		return nil
+	}
+	for _, reply := range chessBoard.GenerateLegalMoves() {
+		if reply.Equal(entry.Move) {
+			return &reply
+		}
+	}
+	return nil
+}
+
+// maxDepth returns the deepest iteration the limits allow.
+func (f *AlphaBetaMoveFinder) maxDepth(limits SearchLimits) int {
+	switch {
+	case limits.Depth > 0:
+		return min(limits.Depth, MaxPly-1)
+	case limits.Infinite || limits.MoveTime > 0 || limits.Nodes > 0 || limits.WhiteTime > 0 || limits.BlackTime > 0:
+		return MaxPly - 1
+	default:
+		return f.depth
+	}
+}
+
+// iterativeDeepening searches to depth 1, 2, ... until a limit is reached,
+// trying the previous iteration's best move first, and returns the last
+// completed iteration. The first iteration always completes so that there
+// is a move to play.
+func (f *AlphaBetaMoveFinder) iterativeDeepening(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) (SearchInfo, error) {
+	start := time.Now()
+	f.reset(ctx, chessBoard, limits)
+	f.timeManager.Start(chessBoard, limits)
+	f.pondering = limits.Ponder
+	if !f.pondering {
+		f.startClock(start)
+	}
+	f.setDrawContempt()
+	f.tt.NewSearch()
+	if f.tracer != nil {
+		f.tracer.Reset()
+	}
+
+	stopHelpers := f.startHelpers(ctx, chessBoard, limits)
+	info, err := f.deepen(chessBoard, limits, start)
+	stopHelpers()
+	if err != nil {
+		return SearchInfo{}, err
+	}
+
+	if len(f.helpers) == 0 {
+		return info, nil
+	}
+	info.Nodes = f.totalNodes()
+	if f.multiPV > 1 {
+		// The lines reported are the main thread's, so its best line stands
+		return info, nil
+	}
+	results := []SearchInfo{info}
+	for _, helper := range f.helpers {
+		results = append(results, helper.result)
+	}
+	if voted := voteBestMove(results); !voted.PV[0].Equal(info.PV[0]) {
+		info.Depth, info.Score, info.PV = voted.Depth, voted.Score, voted.PV
+		if f.onInfo != nil {
+			f.onInfo(info)
+		}
+	}
+	return info, nil
+}
+
+// startClock starts timing the search at now.
+func (f *AlphaBetaMoveFinder) startClock(now time.Time) {
+	hardLimit, timed := f.timeManager.HardLimit()
+	f.clockStart = now
+	f.hasDeadline = timed
+	f.deadline = now.Add(hardLimit)
+}
+
+// checkPonderHit starts the clock of a pondering search once the opponent
+// has played the expected reply. The search carries on where it is, under
+// the time limits from then on.
+func (f *AlphaBetaMoveFinder) checkPonderHit() {
+	if !f.pondering {
+		return
+	}
+	select {
+	case <-f.limits.PonderHit:
+		f.pondering = false
+		f.startClock(time.Now())
+	default:
+	}
+}
+
+// reset prepares the thread for a new search.
+func (f *AlphaBetaMoveFinder) reset(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) {
+	f.ctx = ctx
+	f.limits = limits
+	f.hasDeadline = false
+	f.canStop = false
+	f.stopped = false
+	f.nodes = 0
+	f.reportedNodes.Store(0)
+	f.selDepth = 0
+	f.rootSide = chessBoard.SideToMove()
+	f.stats = SearchStats{}
+	f.iterations = nil
+	f.rootPrevious, f.hasRootPrevious = chessBoard.LastMove()
+}
+
+// deepen runs the main thread's iterations and returns the best line of
+// the last completed one. Each iteration searches the multiPV best lines
+// one after the other, every line excluding the root moves of the lines
+// before it, and reports them best first once all are complete.
+func (f *AlphaBetaMoveFinder) deepen(chessBoard board.ChessBoard, limits SearchLimits, start time.Time) (SearchInfo, error) {
+	lines := make([]SearchInfo, max(min(f.multiPV, f.rootMoveCount(chessBoard)), 1))
+	defer func() {
+		f.rootExcluded = f.rootExcluded[:0]
+	}()
+	for depth := 1; depth <= f.maxDepth(limits); depth++ {
+		f.rootExcluded = f.rootExcluded[:0]
+		f.stats = SearchStats{Depth: depth}
+		iterationStart := f.nodes
+		iteration := make([]SearchInfo, 0, len(lines))
+		for _, line := range lines {
+			var previousBest *board.Move
+			if len(line.PV) > 0 {
+				previousBest = &line.PV[0]
+			}
+			score, err := f.aspirationSearch(chessBoard, depth, previousBest, line.Score)
+			if err != nil {
+				return SearchInfo{}, err
+			}
+			if f.stopped {
+				break
+			}
+			pv := f.principalVariation()
+			iteration = append(iteration, SearchInfo{Depth: depth, Score: score, PV: pv})
+			f.rootExcluded = append(f.rootExcluded, pv[0])
+		}
+		if f.stopped {
+			break
+		}
+
+		slices.SortStableFunc(iteration, func(a, b SearchInfo) int {
+			return b.Score - a.Score
+		})
+		for i := range iteration {
+			line := &iteration[i]
+			line.SelDepth = f.selDepth
+			line.MultiPV = i + 1
+			line.Nodes = f.totalNodes()
+			line.Time = time.Since(start)
+			line.HashFull = f.tt.HashFull()
+			if f.onInfo != nil {
+				f.onInfo(*line)
+			}
+		}
+		lines = iteration
+		f.canStop = true
+		f.recordIteration(f.nodes - iterationStart)
+
+		best := lines[0]
+		f.timeManager.Update(best.PV[0], best.Score)
+		f.checkPonderHit()
+		if !f.pondering && f.timeManager.ShouldStop(time.Since(f.clockStart)) {
+			break
+		}
+		if IsMateScore(best.Score) && MateDistance(best.Score) > 0 {
+			if 2*MateDistance(best.Score)-1 <= depth {
+				// A shorter mate cannot exist
+				break
+			}
+		}
+	}
+	f.lines = lines
+	return lines[0], nil
+}
+
+// recordIteration completes the statistics of an iteration that searched
+// nodes and adds them to those of the search.
+func (f *AlphaBetaMoveFinder) recordIteration(nodes int) {
+	f.stats.Nodes = nodes
+	if len(f.iterations) > 0 {
+		f.stats.BranchingFactor = ratio(nodes, f.iterations[len(f.iterations)-1].Nodes)
+	}
+	f.iterations = append(f.iterations, f.stats)
+}
+
+// Stats returns the main thread's statistics for each iteration the last
+// search completed.
+func (f *AlphaBetaMoveFinder) Stats() []SearchStats {
+	return slices.Clone(f.iterations)
+}
+
+// rootMoveCount counts the legal root moves the limits allow.
+func (f *AlphaBetaMoveFinder) rootMoveCount(chessBoard board.ChessBoard) int {
+	count := 0
+	for _, move := range chessBoard.GenerateLegalMoves() {
+		if f.allowedAtRoot(move) {
+			count++
+		}
+	}
+	return count
+}
+
+// startHelpers starts the helper threads on clones of chessBoard and
+// returns a function that stops them and waits for them to finish. Every
+// other helper starts one ply deeper than the main thread, so that the
+// threads spread over two depths rather than all searching the same
+// iteration.
+func (f *AlphaBetaMoveFinder) startHelpers(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) func() {
+	if len(f.helpers) == 0 {
+		return func() {}
+	}
+	helperCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for i, helper := range f.helpers {
+		helper.selectivity = f.selectivity
+		helper.extensions = f.extensions
+		helper.drawContempt = f.drawContempt
+		helperBoard := chessBoard.Clone()
+		startDepth := 1 + (i+1)%2
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			helper.helperSearch(helperCtx, helperBoard, limits, startDepth)
+		}()
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// helperSearch deepens on a helper thread until the main thread stops it
+// or the limits' depth is completed, leaving the last completed iteration
+// in f.result. What a helper finds reaches the main thread through the
+// transposition table, and its result takes part in the vote.
+func (f *AlphaBetaMoveFinder) helperSearch(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits, startDepth int) {
+	f.reset(ctx, chessBoard, limits)
+	f.canStop = true
+	f.result = SearchInfo{}
+	defer func() {
+		f.reportedNodes.Store(int64(f.nodes))
+	}()
+	var bestMove *board.Move
+	for depth := startDepth; depth <= f.maxDepth(limits); depth++ {
+		score, err := f.aspirationSearch(chessBoard, depth, bestMove, f.result.Score)
+		if err != nil || f.stopped {
+			return
+		}
+		pv := f.principalVariation()
+		bestMove = &pv[0]
+		f.result = SearchInfo{Depth: depth, Score: score, PV: pv}
+	}
+}
+
+// voteBestMove chooses the result to play among the threads' last
+// completed iterations, the main thread's first. Each thread votes for its
+// best move with a weight growing with the depth it completed and with its
+// score above the worst thread's. The move with the most votes wins, with
+// the deepest result that found it; ties go to the main thread.
+func voteBestMove(results []SearchInfo) SearchInfo {
+	minScore := Infinity
+	for _, result := range results {
+		if len(result.PV) > 0 {
+			minScore = min(minScore, result.Score)
+		}
+	}
+	votes := make(map[uint16]int)
+	for _, result := range results {
+		if len(result.PV) > 0 {
+			votes[packMove(&result.PV[0])] += (result.Score - minScore + 14) * result.Depth
+		}
+	}
+
+	best := results[0]
+	for _, result := range results[1:] {
+		if len(result.PV) == 0 {
+			continue
+		}
+		move, bestMove := packMove(&result.PV[0]), packMove(&best.PV[0])
+		if votes[move] > votes[bestMove] || move == bestMove && result.Depth > best.Depth {
+			best = result
+		}
+	}
+	return best
+}
+
+// principalVariation copies the root's principal variation.
+func (f *AlphaBetaMoveFinder) principalVariation() []board.Move {
+	pv := make([]board.Move, f.pvLength[0])
+	copy(pv, f.pv[0][:f.pvLength[0]])
+	return pv
+}
+
+// totalNodes counts the nodes searched by every thread, those of the
+// helpers as last reported.
+func (f *AlphaBetaMoveFinder) totalNodes() int {
+	nodes := f.nodes
+	for _, helper := range f.helpers {
+		nodes += int(helper.reportedNodes.Load())
+	}
+	return nodes
+}
+
+// aspirationSearch runs one iteration to depth. From aspirationMinDepth
+// on, it first searches a narrow window around the previous iteration's
+// score, widening the side that fails until the score falls inside.
+func (f *AlphaBetaMoveFinder) aspirationSearch(chessBoard board.ChessBoard, depth int, previousBest *board.Move, previousScore int) (int, error) {
+	if !f.selectivity.AspirationWindows || depth < aspirationMinDepth || IsMateScore(previousScore) {
+		return f.search(chessBoard, depth, previousBest, -Infinity, Infinity)
+	}
+
+	delta := aspirationDelta
+	alpha, beta := previousScore-delta, previousScore+delta
+	for {
+		score, err := f.search(chessBoard, depth, previousBest, alpha, beta)
+		if err != nil || f.stopped {
+			return score, err
+		}
+		switch {
+		case score <= alpha:
+			alpha = max(score-delta, -Infinity)
+		case score >= beta:
+			beta = min(score+delta, Infinity)
+		default:
+			return score, nil
+		}
+		if f.pvLength[0] > 0 {
+			previousBest = &f.pv[0][0]
+		}
+		delta *= 2
+		if delta > aspirationMaxDelta {
+			alpha, beta = -Infinity, Infinity
+		}
+	}
+}
+
+// search runs one iteration to depth with the window (alpha, beta) and
+// returns the root score, leaving the principal variation in f.pv[0].
+func (f *AlphaBetaMoveFinder) search(chessBoard board.ChessBoard, depth int, previousBest *board.Move, alpha, beta int) (int, error) {
+	originalAlpha := alpha
+	bestScore := -Infinity
+	searched := 0
+	f.pvLength[0] = 0
+	f.rootDepth = depth
+	f.pathExtensions[0] = 0
+	if f.tracer != nil {
+		f.tracer.enter("", 0, depth, alpha, beta)
+		defer func() {
+			f.traceExit(bestScore)
+		}()
+	}
+
+	key := chessBoard.Hash()
+	if previousBest == nil {
+		if entry, ok := f.tt.Probe(key, 0); ok && entry.HasMove {
+			previousBest = &entry.Move
+		}
+	}
+
+	picker := &f.pickers[0]
+	ordering := f.ordering(0)
+	picker.Init(chessBoard, previousBest, ordering)
+	for move, ok := picker.Next(); ok; move, ok = picker.Next() {
+		if !f.allowedAtRoot(move) {
+			continue
+		}
+		searched++
+		f.stack[0] = move
+		f.isNull[0] = false
+		if err := chessBoard.MakeMove(move); err != nil {
+			return 0, fmt.Errorf("failed to make move: %w", err)
+		}
+		givesCheck := chessBoard.InCheck(chessBoard.SideToMove())
+		f.pathExtensions[1] = f.extension(chessBoard, move, 0, ordering.Previous[0], givesCheck, false)
+		newDepth := depth - 1 + f.pathExtensions[1]
+		var score int
+		if searched == 1 || !f.selectivity.PVS {
+			score = -f.negamax(chessBoard, newDepth, 1, -beta, -alpha)
+		} else {
+			score = -f.negamax(chessBoard, newDepth, 1, -alpha-1, -alpha)
+			if score > alpha && score < beta {
+				score = -f.negamax(chessBoard, newDepth, 1, -beta, -alpha)
+			}
+		}
+		chessBoard.UndoMove()
+		if f.stopped {
+			break
+		}
+
+		if score > bestScore {
+			bestScore = score
+			f.updatePV(0, move)
+		}
+		alpha = max(alpha, score)
+		if alpha >= beta {
+			break
+		}
+	}
+
+	if searched == 0 && !f.stopped {
+		return 0, fmt.Errorf("no legal moves available")
+	}
+	if !f.stopped && len(f.rootExcluded) == 0 && len(f.limits.SearchMoves) == 0 {
+		// Later lines of a MultiPV search and searchmoves leave out moves, so
+		// only an unrestricted first line's result holds for the position
+		f.tt.Store(key, &f.pv[0][0], bestScore, depth, boundFor(bestScore, originalAlpha, beta), 0)
+	}
+	return bestScore, nil
+}
+
+func (f *AlphaBetaMoveFinder) Options() []Option {
+	options := []Option{
+		{Name: "Hash", Type: SpinOption, Default: strconv.Itoa(DefaultHashSize), Min: 1, Max: MaxHashSize},
+		{Name: "Move Overhead", Type: SpinOption, Default: strconv.Itoa(int(DefaultMoveOverhead.Milliseconds())), Min: 0, Max: int(MaxMoveOverhead.Milliseconds())},
+		{Name: "Threads", Type: SpinOption, Default: "1", Min: 1, Max: MaxThreads},
+		{Name: "MultiPV", Type: SpinOption, Default: "1", Min: 1, Max: board.MaxMoves},
+		{Name: "Skill Level", Type: SpinOption, Default: strconv.Itoa(MaxSkillLevel), Min: 0, Max: MaxSkillLevel},
+		{Name: "UCI_LimitStrength", Type: CheckOption, Default: "false"},
+		{Name: "UCI_Elo", Type: SpinOption, Default: strconv.Itoa(DefaultElo), Min: MinElo, Max: MaxElo},
+		{Name: "Contempt", Type: SpinOption, Default: "0", Min: -MaxContempt, Max: MaxContempt},
+		{Name: "UCI_Opponent", Type: StringOption},
+	}
+	defaults := &AlphaBetaMoveFinder{selectivity: DefaultSelectivity(), extensions: DefaultExtensions()}
+	for _, toggle := range defaults.toggles() {
+		options = append(options, Option{Name: toggle.name, Type: CheckOption, Default: strconv.FormatBool(*toggle.enabled)})
+	}
+	return options
+}
+
+// toggles lists the techniques that can be switched on and off.
+func (f *AlphaBetaMoveFinder) toggles() []selectivityToggle {
+	return append(f.selectivity.toggles(), f.extensions.toggles()...)
+}
+
+func (f *AlphaBetaMoveFinder) SetOption(name, value string) error {
+	option, err := findOption(f.Options(), name)
+	if err != nil {
+		return err
+	}
+	switch option.Name {
+	case "Hash":
+		size, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.tt.Resize(size)
+	case "Move Overhead":
+		milliseconds, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.timeManager.SetMoveOverhead(time.Duration(milliseconds) * time.Millisecond)
+	case "Threads":
+		threads, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.setThreads(threads)
+	case "MultiPV":
+		lines, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.multiPV = lines
+	case "Skill Level":
+		level, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.strength.SkillLevel = level
+	case "UCI_LimitStrength":
+		enabled, err := parseCheck(option, value)
+		if err != nil {
+			return err
+		}
+		f.strength.LimitStrength = enabled
+	case "UCI_Elo":
+		elo, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.strength.Elo = elo
+	case "Contempt":
+		contempt, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.contempt = contempt
+	case "UCI_Opponent":
+		opponent, err := parseOpponent(value)
+		if err != nil {
+			return err
+		}
+		f.opponent = opponent
+	default:
+		for _, toggle := range f.toggles() {
+			if toggle.name == option.Name {
+				enabled, err := parseCheck(option, value)
+				if err != nil {
+					return err
+				}
+				*toggle.enabled = enabled
+			}
+		}
+	}
+	return nil
+}
+
+// setThreads keeps threads-1 helpers, each with its own history and
+// sharing the transposition table.
+func (f *AlphaBetaMoveFinder) setThreads(threads int) {
+	for len(f.helpers) < threads-1 {
+		f.helpers = append(f.helpers, &AlphaBetaMoveFinder{
+			logger:  f.logger,
+			depth:   f.depth,
+			tt:      f.tt,
+			history: NewHistory(),
+		})
+	}
+	f.helpers = f.helpers[:threads-1]
+}
+
+// NewGame clears the transposition table and the move ordering history.
+func (f *AlphaBetaMoveFinder) NewGame() {
+	f.tt.Clear()
+	f.history.Clear()
+	for _, helper := range f.helpers {
+		helper.history.Clear()
+	}
+}
+
+// allowedAtRoot applies the searchmoves restriction and leaves out the root
+// moves of earlier MultiPV lines.
+func (f *AlphaBetaMoveFinder) allowedAtRoot(move board.Move) bool {
+	for _, excluded := range f.rootExcluded {
+		if excluded.Equal(move) {
+			return false
+		}
+	}
+	return isSearchMove(move, f.limits)
+}
+
+// isSearchMove reports whether limits allow move at the root.
+func isSearchMove(move board.Move, limits SearchLimits) bool {
+	if len(limits.SearchMoves) == 0 {
+		return true
+	}
+	for _, allowed := range limits.SearchMoves {
+		if allowed.Equal(move) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLimits sets f.stopped once the node limit is reached, the deadline
+// passes or the context is cancelled. The node limit counts the nodes of
+// every thread.
+func (f *AlphaBetaMoveFinder) checkLimits() {
+	if f.nodes%checkInterval == 0 {
+		f.reportedNodes.Store(int64(f.nodes))
+	}
+	if !f.canStop {
+		return
+	}
+	if f.limits.Nodes > 0 && f.totalNodes() >= f.limits.Nodes {
+		f.stopped = true
+	}
+	if f.nodes%checkInterval == 0 {
+		f.checkPonderHit()
+		if f.ctx.Err() != nil || f.hasDeadline && time.Now().After(f.deadline) {
+			f.stopped = true
+		}
+	}
+}
+
+// updatePV makes move followed by the child's variation the principal
+// variation at ply.
+func (f *AlphaBetaMoveFinder) updatePV(ply int, move board.Move) {
+	f.pv[ply][0] = move
+	childLength := f.pvLength[ply+1]
+	copy(f.pv[ply][1:1+childLength], f.pv[ply+1][:childLength])
+	f.pvLength[ply] = childLength + 1
+}
+
+// negamax searches the node at ply to depth with the window (alpha, beta)
+// and returns its score from the side to move's point of view, recording
+// the node if a tracer is set.
+func (f *AlphaBetaMoveFinder) negamax(chessBoard board.ChessBoard, depth, ply, alpha, beta int) int {
+	if f.tracer == nil {
+		return f.searchNode(chessBoard, depth, ply, alpha, beta)
+	}
+	move := "null"
+	if !f.isNull[ply-1] {
+		move = traceMove(f.stack[ply-1])
+	}
+	f.tracer.enter(move, ply, depth, alpha, beta)
+	score := f.searchNode(chessBoard, depth, ply, alpha, beta)
+	f.traceExit(score)
+	return score
+}
+
+// traceExit ends the traced node with score.
+func (f *AlphaBetaMoveFinder) traceExit(score int) {
+	if f.stopped {
+		f.tracer.prune(Stopped)
+	}
+	f.tracer.exit(score)
+}
+
+// tracePrune gives the reason the node being searched was settled early.
+func (f *AlphaBetaMoveFinder) tracePrune(reason PruneReason) {
+	if f.tracer != nil {
+		f.tracer.prune(reason)
+	}
+}
+
+// traceSkip records that move was pruned at ply without being searched,
+// from the window (alpha, beta) of the node.
+func (f *AlphaBetaMoveFinder) traceSkip(move board.Move, ply, depth, alpha, beta int, reason PruneReason) {
+	if f.tracer != nil {
+		f.tracer.skip(traceMove(move), ply+1, depth-1, -beta, -alpha, reason)
+	}
+}
+
+// searchNode is negamax untraced. Nodes with a null window are expected
+// not to be on the principal variation and are pruned more aggressively.
+func (f *AlphaBetaMoveFinder) searchNode(chessBoard board.ChessBoard, depth, ply, alpha, beta int) int {
+	if depth <= 0 {
+		f.tracePrune(QuiescenceLeaf)
+		return f.quiescence(chessBoard, ply, alpha, beta)
+	}
+
+	f.nodes++
+	if beta-alpha > 1 {
+		f.stats.PVNodes++
+	} else {
+		f.stats.NonPVNodes++
+	}
+	f.pvLength[ply] = 0
+	f.checkLimits()
+	if f.stopped {
+		return 0
+	}
+	if ply > f.selDepth {
+		f.selDepth = ply
+	}
+
+	if isDraw(chessBoard) {
+		f.tracePrune(DrawnPosition)
+		return f.drawScore(chessBoard)
+	}
+	if ply >= MaxPly-1 {
+		return evaluation.Evaluate(chessBoard)
+	}
+
+	// A search excluding a move is not a search of the position, so it
+	// neither uses nor stores transposition table scores
+	key := chessBoard.Hash()
+	excluding := f.hasExcluded[ply]
+	var hashMove *board.Move
+	entry, hit := f.tt.Probe(key, ply)
+	f.stats.TTProbes++
+	if hit {
+		f.stats.TTHits++
+	}
+	if hit && !excluding {
+		if entry.Depth >= depth && ttCutoff(entry, alpha, beta) {
+			f.stats.TTCutoffs++
+			f.tracePrune(TTCutoff)
+			return entry.Score
+		}
+		if entry.HasMove {
+			hashMove = &entry.Move
+		}
+	}
+
+	pvNode := beta-alpha > 1
+	inCheck := chessBoard.InCheck(chessBoard.SideToMove())
+	staticEval := -Infinity
+	if !inCheck {
+		staticEval = evaluation.Evaluate(chessBoard)
+	}
+
+	if !pvNode && !inCheck && !excluding {
+		if score, ok := f.prune(chessBoard, depth, ply, alpha, beta, staticEval); ok {
+			return score
+		}
+	}
+
+	singular := false
+	if f.extensions.Singular && hashMove != nil && depth >= singularMinDepth && entry.Bound != UpperBound &&
+		entry.Depth >= depth-singularDepthMargin && !IsMateScore(entry.Score) {
+		singularBeta := entry.Score - singularMarginPerPly*depth
+		f.excluded[ply], f.hasExcluded[ply] = *hashMove, true
+		score := f.negamax(chessBoard, (depth-1)/2, ply, singularBeta-1, singularBeta)
+		f.hasExcluded[ply] = false
+		if f.stopped {
+			return 0
+		}
+		if score < singularBeta {
+			singular = true
+		} else if singularBeta >= beta {
+			// Multi-cut: the hash move and another move both beat beta
+			f.tracePrune(MultiCut)
+			return singularBeta
+		}
+	}
+
+	originalAlpha := alpha
+	legalMoves := 0
+	bestScore := -Infinity
+	var bestMove board.Move
+	picker := &f.pickers[ply]
+	ordering := f.ordering(ply)
+	quietsTried := 0
+	picker.Init(chessBoard, hashMove, ordering)
+	for move, ok := picker.Next(); ok; move, ok = picker.Next() {
+		if excluding && move.Equal(f.excluded[ply]) {
+			continue
+		}
+		legalMoves++
+		quiet := isQuiet(move)
+
+		if !pvNode && !inCheck && quiet && legalMoves > 1 && !IsMateScore(alpha) {
+			if f.selectivity.LateMovePruning && depth <= lateMovePruningMaxDepth && quietsTried >= lateMovePruningThreshold(depth) {
+				f.traceSkip(move, ply, depth, alpha, beta, LateMovePruned)
+				continue
+			}
+		}
+
+		f.stack[ply] = move
+		f.isNull[ply] = false
+		chessBoard.MakeMove(move)
+		givesCheck := chessBoard.InCheck(chessBoard.SideToMove())
+
+		// Futility pruning: a quiet move near the leaves cannot raise a
+		// static evaluation this far below alpha
+		if f.selectivity.Futility && !pvNode && !inCheck && quiet && !givesCheck && legalMoves > 1 &&
+			depth <= futilityMaxDepth && staticEval+futilityMargin(depth) <= alpha && !IsMateScore(alpha) {
+			chessBoard.UndoMove()
+			f.traceSkip(move, ply, depth, alpha, beta, FutilityPruned)
+			continue
+		}
+
+		extension := f.extension(chessBoard, move, ply, ordering.Previous[0], givesCheck, singular && move.Equal(*hashMove))
+		f.pathExtensions[ply+1] = f.pathExtensions[ply] + extension
+		newDepth := depth - 1 + extension
+
+		reduction := 0
+		if f.selectivity.LateMoveReductions && depth >= lmrMinDepth && legalMoves >= lmrMinMoves && quiet && !inCheck && !givesCheck && extension == 0 {
+			reduction = lmrReductions[depth][min(legalMoves, board.MaxMoves-1)]
+			reduction -= f.history.QuietScore(move, ordering) / lmrHistoryDivisor
+			if pvNode {
+				reduction--
+			}
+			reduction = max(min(reduction, newDepth-1), 0)
+		}
+		if reduction > 0 {
+			f.stats.Reductions++
+		}
+
+		var score int
+		if legalMoves == 1 {
+			score = -f.negamax(chessBoard, newDepth, ply+1, -beta, -alpha)
+		} else {
+			// Later moves are expected to fail low: search them with a null
+			// window, and reduced if late, and again in full if they do not
+			window := -beta
+			if f.selectivity.PVS {
+				window = -alpha - 1
+			}
+			score = -f.negamax(chessBoard, newDepth-reduction, ply+1, window, -alpha)
+			if reduction > 0 && score > alpha {
+				f.stats.ReSearches++
+				score = -f.negamax(chessBoard, newDepth, ply+1, window, -alpha)
+			}
+			if f.selectivity.PVS && score > alpha && score < beta {
+				score = -f.negamax(chessBoard, newDepth, ply+1, -beta, -alpha)
+			}
+		}
+		chessBoard.UndoMove()
+		if f.stopped {
+			return 0
+		}
+
+		if score > bestScore {
+			bestScore = score
+			bestMove = move
+		}
+		if score > alpha {
+			alpha = score
+			f.updatePV(ply, move)
+		}
+		if alpha >= beta {
+			f.stats.Cutoffs++
+			f.tracePrune(BetaCutoff)
+			if legalMoves == 1 {
+				f.stats.FirstMoveCutoffs++
+			}
+			if quiet {
+				f.history.UpdateQuiets(move, f.quietsTried[ply][:quietsTried], depth, ordering)
+			}
+			break
+		}
+		if quiet && quietsTried < maxQuietsTried {
+			f.quietsTried[ply][quietsTried] = move
+			quietsTried++
+		}
+	}
+
+	if legalMoves == 0 {
+		switch {
+		case excluding:
+			return alpha
+		case inCheck:
+			return matedIn(ply)
+		}
+		return f.drawScore(chessBoard)
+	}
+	if excluding {
+		return bestScore
+	}
+
+	bound := boundFor(bestScore, originalAlpha, beta)
+	if bound == UpperBound {
+		// Every move failed low, so none of them is known to be best
+		f.tt.Store(key, nil, bestScore, depth, bound, ply)
+	} else {
+		f.tt.Store(key, &bestMove, bestScore, depth, bound, ply)
+	}
+	return bestScore
+}
+
+// prune tries to settle a node off the principal variation without
+// searching its moves: by reverse futility when the static evaluation is
+// far above beta, by razoring when it is far below alpha and quiescence
+// confirms it, and by null move when passing still fails high.
+func (f *AlphaBetaMoveFinder) prune(chessBoard board.ChessBoard, depth, ply, alpha, beta, staticEval int) (int, bool) {
+	if IsMateScore(alpha) || IsMateScore(beta) {
+		return 0, false
+	}
+
+	if f.selectivity.ReverseFutility && depth <= reverseFutilityMaxDepth && staticEval-reverseFutilityMargin(depth) >= beta {
+		f.tracePrune(ReverseFutility)
+		return staticEval, true
+	}
+
+	if f.selectivity.Razoring && depth <= razoringMaxDepth && staticEval+razoringMargin(depth) < alpha {
+		score := f.quiescence(chessBoard, ply, alpha-1, alpha)
+		if f.stopped {
+			return 0, true
+		}
+		if score < alpha {
+			f.tracePrune(Razoring)
+			return score, true
+		}
+	}
+
+	if f.selectivity.NullMove && !f.verifying && depth >= nullMoveMinDepth && staticEval >= beta && ply > 0 && !f.isNull[ply-1] {
+		material := nonPawnMaterial(chessBoard, chessBoard.SideToMove())
+		if material == 0 {
+			return 0, false
+		}
+
+		reduction := nullMoveReduction(depth)
+		f.stats.NullMoveTries++
+		f.isNull[ply] = true
+		f.pathExtensions[ply+1] = f.pathExtensions[ply]
+		chessBoard.MakeNullMove()
+		score := -f.negamax(chessBoard, depth-1-reduction, ply+1, -beta, -beta+1)
+		chessBoard.UndoNullMove()
+		f.isNull[ply] = false
+		if f.stopped {
+			return 0, true
+		}
+		if score < beta {
+			return 0, false
+		}
+		if IsMateScore(score) {
+			// A mate found after passing is not a proven mate
+			score = beta
+		}
+		if material > evaluation.RookValue {
+			f.stats.NullMoveCutoffs++
+			f.tracePrune(NullMoveCutoff)
+			return score, true
+		}
+
+		// With little material zugzwang is likely, so confirm the cutoff
+		// with a reduced search that does not pass
+		f.verifying = true
+		verified := f.negamax(chessBoard, depth-1-reduction, ply, beta-1, beta)
+		f.verifying = false
+		if f.stopped {
+			return 0, true
+		}
+		if verified >= beta {
+			f.stats.NullMoveCutoffs++
+			f.tracePrune(NullMoveCutoff)
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+// extension returns the plies to extend move by, which has just been made
+// at ply after previous, if any. Moves are extended by one ply at most, and
+// only while the path has been extended by fewer plies than the iteration's
+// depth.
+func (f *AlphaBetaMoveFinder) extension(chessBoard board.ChessBoard, move board.Move, ply int, previous *board.Move, givesCheck, singular bool) int {
+	if f.pathExtensions[ply] >= f.rootDepth {
+		return 0
+	}
+	switch {
+	case f.extensions.Check && givesCheck,
+		singular,
+		f.extensions.PassedPawn && isPassedPawnPush(chessBoard, move),
+		f.extensions.Recapture && isRecapture(move, previous):
+		return 1
+	}
+	return 0
+}
+
+// quiescence searches captures and promotions until the position is quiet,
+// so that the static evaluation is never taken in the middle of an
+// exchange. The side to move may stand pat on the evaluation instead of
+// capturing, except in check, where every evasion is searched. Captures
+// that cannot raise alpha even with deltaMargin to spare, and captures
+// that lose material by static exchange, are skipped.
+func (f *AlphaBetaMoveFinder) quiescence(chessBoard board.ChessBoard, ply, alpha, beta int) int {
+	f.nodes++
+	f.stats.QuiescenceNodes++
+	f.pvLength[ply] = 0
+	f.checkLimits()
+	if f.stopped {
+		return 0
+	}
+	if ply > f.selDepth {
+		f.selDepth = ply
+	}
+
+	if isDraw(chessBoard) {
+		return f.drawScore(chessBoard)
+	}
+	if ply >= MaxPly-1 {
+		return evaluation.Evaluate(chessBoard)
+	}
+
+	inCheck := chessBoard.InCheck(chessBoard.SideToMove())
+	standPat := -Infinity
+	if !inCheck {
+		standPat = evaluation.Evaluate(chessBoard)
+		if standPat >= beta {
+			return standPat
+		}
+		alpha = max(alpha, standPat)
+	}
+
+	legalMoves := 0
+	bestScore := standPat
+	picker := &f.pickers[ply]
+	picker.InitQuiescence(chessBoard)
+	for move, ok := picker.Next(); ok; move, ok = picker.Next() {
+		legalMoves++
+		if !inCheck {
+			if move.Promotion == nil && move.CapturedPiece != nil &&
+				standPat+evaluation.PieceValue(move.CapturedPiece.Name)+deltaMargin <= alpha {
+				continue
+			}
+			if SEE(chessBoard, move) < 0 {
+				continue
+			}
+		}
+
+		chessBoard.MakeMove(move)
+		score := -f.quiescence(chessBoard, ply+1, -beta, -alpha)
+		chessBoard.UndoMove()
+		if f.stopped {
+			return 0
+		}
+
+		if score > bestScore {
+			bestScore = score
+		}
+		if score > alpha {
+			alpha = score
+			f.updatePV(ply, move)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+
+	if inCheck && legalMoves == 0 {
+		return matedIn(ply)
+	}
+	return bestScore
+}
+
+// ordering describes the node at ply to its move picker.
+func (f *AlphaBetaMoveFinder) ordering(ply int) Ordering {
+	ordering := Ordering{History: f.history, Ply: ply}
+	for i := range ordering.Previous {
+		switch back := ply - 1 - i; {
+		case back >= 0:
+			if !f.isNull[back] {
+				ordering.Previous[i] = &f.stack[back]
+			}
+		case back == -1 && f.hasRootPrevious:
+			ordering.Previous[i] = &f.rootPrevious
+		}
+	}
+	return ordering
+}
+
+func isQuiet(move board.Move) bool {
+	return move.CapturedPiece == nil && move.Promotion == nil
+}
+
+// boundFor classifies a score searched with the window (alpha, beta).
+func boundFor(score, alpha, beta int) Bound {
+	switch {
+	case score >= beta:
+		return LowerBound
+	case score > alpha:
+		return ExactBound
+	}
+	return UpperBound
+}
+
+// ttCutoff reports whether a stored score settles the node for the window
+// (alpha, beta).
+func ttCutoff(entry TTEntry, alpha, beta int) bool {
+	switch entry.Bound {
+	case ExactBound:
+		return true
+	case LowerBound:
+		return entry.Score >= beta
+	case UpperBound:
+		return entry.Score <= alpha
+	}
+	return false
+}
+
+// isDraw reports draws by the fifty-move rule, repetition and insufficient
+// material. A single repetition is scored as a draw since whichever side
+// steered into it could repeat again.
+func isDraw(chessBoard board.ChessBoard) bool {
+	return chessBoard.HalfmoveClock() >= 100 ||
+		chessBoard.RepetitionCount() > 0 ||
+		chessBoard.HasInsufficientMaterial()
+}
+
+func NewAlphaBetaMoveFinder(logger *logging.Logger, depth int) *AlphaBetaMoveFinder {
+	return &AlphaBetaMoveFinder{
+		logger:      logger,
+		depth:       depth,
+		timeManager: NewTimeManager(DefaultMoveOverhead),
+		tt:          NewTranspositionTable(DefaultHashSize),
+		history:     NewHistory(),
+		selectivity: DefaultSelectivity(),
+		extensions:  DefaultExtensions(),
+		multiPV:     1,
+		strength:    DefaultStrength(),
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
@@ -0,0 +1,524 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	board "jesus_chess/domain/board"
+	evaluation "jesus_chess/domain/evaluation"
+	logging "jesus_chess/domain/logging"
+)
+
+func newTestAlphaBetaMoveFinder(t testing.TB, depth int) *AlphaBetaMoveFinder {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	return NewAlphaBetaMoveFinder(logger, depth)
+}
+
+func TestAlphaBetaFindsMates(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		depth  int
+		move   string // empty when several moves mate equally fast
+		mateIn int
+	}{
+		{"back rank mate in 1", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 2, "a1a8", 1},
+		{"rook ladder mate in 2", "6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1", 4, "", 2},
+		{"black mates in 1", "r5k1/8/8/8/8/8/5PPP/6K1 b - - 0 1", 2, "a8a1", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finder := newTestAlphaBetaMoveFinder(t, test.depth)
+			info, err := finder.iterativeDeepening(context.Background(), newTestBoard(t, test.fen), SearchLimits{})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			move, score := info.PV[0], info.Score
+			if got := squareString(move.From) + squareString(move.To); test.move != "" && got != test.move {
+				t.Errorf("expected %s, got %s", test.move, got)
+			}
+			if !IsMateScore(score) || MateDistance(score) != test.mateIn {
+				t.Errorf("expected mate in %d, got score %d", test.mateIn, score)
+			}
+		})
+	}
+}
+
+func TestAlphaBetaScoresBeingMated(t *testing.T) {
+	// White's only move is Kg1, after which Rb8-b1 mates
+	finder := newTestAlphaBetaMoveFinder(t, 3)
+	info, err := finder.iterativeDeepening(context.Background(), newTestBoard(t, "1r5k/8/8/8/8/8/r7/7K w - - 0 1"), SearchLimits{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !IsMateScore(info.Score) || MateDistance(info.Score) != -1 {
+		t.Errorf("expected to be mated in 1, got score %d", info.Score)
+	}
+}
+
+func TestAlphaBetaReportsEachIteration(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	infos := []SearchInfo{}
+	finder.SetInfoCallback(func(info SearchInfo) {
+		infos = append(infos, info)
+	})
+
+	cb := newTestBoard(t, "6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1")
+	if _, err := finder.FindBestMove(context.Background(), cb, SearchLimits{}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	// Quiescence sees the mate in two at depth 3, after which no shorter
+	// mate can exist and the search ends before its nominal depth of 4
+	if len(infos) != 3 {
+		t.Fatalf("expected an info per depth up to the mate, got %d", len(infos))
+	}
+	for i, info := range infos {
+		if info.Depth != i+1 {
+			t.Errorf("expected depth %d, got %d", i+1, info.Depth)
+		}
+		if info.SelDepth < info.Depth || info.Nodes == 0 || len(info.PV) == 0 {
+			t.Errorf("incomplete info at depth %d: %+v", info.Depth, info)
+		}
+	}
+
+	// The final PV is the full mating line and must be playable
+	last := infos[len(infos)-1]
+	if len(last.PV) != 3 {
+		t.Fatalf("expected a three ply mating line, got %d moves", len(last.PV))
+	}
+	for _, move := range last.PV {
+		if err := cb.MakeMove(move); err != nil {
+			t.Fatalf("pv move %v is not playable: %v", move, err)
+		}
+	}
+	if len(cb.GenerateLegalMoves()) != 0 || !cb.InCheck(cb.SideToMove()) {
+		t.Errorf("expected the pv to end in mate, got %s", cb.FEN())
+	}
+}
+
+func TestAlphaBetaWinsHangingQueen(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 2)
+	result, err := finder.FindBestMove(context.Background(), newTestBoard(t, "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1"), SearchLimits{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if result.BestMove.To != (board.Square{Rank: 4, File: 3}) {
+		t.Errorf("expected Rxd5, got %v", result.BestMove)
+	}
+}
+
+// TestAlphaBetaAvoidsHorizonBlunders searches positions where the last move
+// of a fixed-depth search would grab material that is lost straight back.
+func TestAlphaBetaAvoidsHorizonBlunders(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		depth int
+		// avoid is a move the search must not play
+		avoid string
+		// from is the square the best move must start on, if set
+		from string
+	}{
+		{"queen takes a defended pawn", "4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1", 1, "d1d5", ""},
+		{"queen takes a defended pawn a ply deeper", "4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1", 2, "d1d5", ""},
+		{"rook takes a defended knight", "3rk3/8/8/3n4/8/8/8/3RK3 w - - 0 1", 1, "d1d5", ""},
+		{"pawn grab leaving the queen en prise", "4k3/p7/8/4p3/3Q4/8/8/R3K3 w - - 0 1", 1, "a1a7", "d4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finder := newTestAlphaBetaMoveFinder(t, test.depth)
+			cb := newTestBoard(t, test.fen)
+			result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: test.depth})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			move := squareString(result.BestMove.From) + squareString(result.BestMove.To)
+			if move == test.avoid {
+				t.Errorf("played %s with score %d", move, result.Score)
+			}
+			if test.from != "" && squareString(result.BestMove.From) != test.from {
+				t.Errorf("expected a move from %s, got %s", test.from, move)
+			}
+		})
+	}
+}
+
+func TestQuiescenceResolvesExchanges(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	finder.ctx = context.Background()
+
+	// White wins a knight for nothing; black cannot recapture
+	winning := newTestBoard(t, "4k3/8/8/3n4/8/8/8/3RK3 w - - 0 1")
+	if score := finder.quiescence(winning, 0, -Infinity, Infinity); score < 200 {
+		t.Errorf("expected the free knight to be counted, got %d", score)
+	}
+
+	// Standing pat beats the losing exchange Rxd5 Rxd5
+	losing := newTestBoard(t, "3rk3/8/8/3n4/8/8/8/3RK3 w - - 0 1")
+	if score, static := finder.quiescence(losing, 0, -Infinity, Infinity), evaluation.Evaluate(losing); score != static {
+		t.Errorf("expected the static evaluation %d, got %d", static, score)
+	}
+
+	// In check quiescence searches evasions and sees mate
+	mated := newTestBoard(t, "R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1")
+	if score := finder.quiescence(mated, 1, -Infinity, Infinity); score != matedIn(1) {
+		t.Errorf("expected mate to score %d, got %d", matedIn(1), score)
+	}
+}
+
+func TestAlphaBetaScoresDraws(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 2)
+
+	stalemate := newTestBoard(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+	if score := finder.negamax(stalemate, 2, 1, -Infinity, Infinity); score != DrawScore {
+		t.Errorf("expected stalemate to score %d, got %d", DrawScore, score)
+	}
+
+	bareKings := newTestBoard(t, "4k3/8/8/8/8/8/8/4KB2 w - - 0 1")
+	if score := finder.negamax(bareKings, 2, 1, -Infinity, Infinity); score != DrawScore {
+		t.Errorf("expected insufficient material to score %d, got %d", DrawScore, score)
+	}
+
+	fiftyMoves := newTestBoard(t, "4k3/8/8/8/8/8/8/3QK3 w - - 100 80")
+	if score := finder.negamax(fiftyMoves, 2, 1, -Infinity, Infinity); score != DrawScore {
+		t.Errorf("expected the fifty-move rule to score %d, got %d", DrawScore, score)
+	}
+}
+
+func TestAlphaBetaReturnsErrorWithoutLegalMoves(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 2)
+	if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"), SearchLimits{}); err == nil {
+		t.Errorf("expected an error in stalemate")
+	}
+}
+
+func TestAlphaBetaHonoursLimits(t *testing.T) {
+	const middlegame = "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10"
+
+	t.Run("depth", func(t *testing.T) {
+		finder := newTestAlphaBetaMoveFinder(t, 1)
+		info, _ := finder.iterativeDeepening(context.Background(), newTestBoard(t, middlegame), SearchLimits{Depth: 3})
+		if info.Depth != 3 {
+			t.Errorf("expected depth 3, got %d", info.Depth)
+		}
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		finder := newTestAlphaBetaMoveFinder(t, 1)
+		info, _ := finder.iterativeDeepening(context.Background(), newTestBoard(t, middlegame), SearchLimits{Nodes: 5000})
+		if finder.nodes > 5000 || info.Nodes > 5000 {
+			t.Errorf("expected at most 5000 nodes, searched %d", finder.nodes)
+		}
+	})
+
+	t.Run("movetime", func(t *testing.T) {
+		finder := newTestAlphaBetaMoveFinder(t, 1)
+		start := time.Now()
+		result, err := finder.FindBestMove(context.Background(), newTestBoard(t, middlegame), SearchLimits{MoveTime: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the search to stop after about 100ms, took %v", elapsed)
+		}
+		if len(result.PV) == 0 || !result.PV[0].Equal(result.BestMove) {
+			t.Errorf("expected the best move to start the pv, got %+v", result)
+		}
+	})
+
+	t.Run("infinite until cancelled", func(t *testing.T) {
+		finder := newTestAlphaBetaMoveFinder(t, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if _, err := finder.FindBestMove(ctx, newTestBoard(t, middlegame), SearchLimits{Infinite: true}); err != nil {
+				t.Errorf("search failed: %v", err)
+			}
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-done:
+			t.Fatalf("infinite search returned before being cancelled")
+		default:
+		}
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("search did not stop after being cancelled")
+		}
+	})
+
+	t.Run("search moves", func(t *testing.T) {
+		// Only the quiet king move may be searched, not the queen capture
+		finder := newTestAlphaBetaMoveFinder(t, 2)
+		kingMove := board.Move{From: board.Square{Rank: 0, File: 4}, To: board.Square{Rank: 0, File: 5}}
+		result, err := finder.FindBestMove(context.Background(), newTestBoard(t, "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1"), SearchLimits{SearchMoves: []board.Move{kingMove}})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if !result.BestMove.Equal(kingMove) {
+			t.Errorf("expected Kf1, got %v", result.BestMove)
+		}
+	})
+
+	t.Run("mate", func(t *testing.T) {
+		finder := newTestAlphaBetaMoveFinder(t, 1)
+		result, err := finder.FindBestMove(context.Background(), newTestBoard(t, "6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1"), SearchLimits{Mate: 2})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if MateDistance(result.Score) != 2 || result.PonderMove == nil {
+			t.Errorf("expected mate in 2 with a ponder move, got %+v", result)
+		}
+	})
+}
+
+func squareString(sq board.Square) string {
+	return string(rune('a'+sq.File)) + string(rune('1'+sq.Rank))
+}
+
+func TestLazySMPSearch(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 6)
+	if err := finder.SetOption("Threads", "4"); err != nil {
+		t.Fatalf("failed to set threads: %v", err)
+	}
+	if len(finder.helpers) != 3 {
+		t.Fatalf("expected 3 helper threads, got %d", len(finder.helpers))
+	}
+
+	cb := newTestBoard(t, "6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1")
+	before := cb.FEN()
+	info, err := finder.iterativeDeepening(context.Background(), cb, SearchLimits{Depth: 6})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !IsMateScore(info.Score) || MateDistance(info.Score) != 2 {
+		t.Errorf("expected mate in 2, got score %d", info.Score)
+	}
+	if cb.FEN() != before {
+		t.Errorf("expected the board to be left at %s, got %s", before, cb.FEN())
+	}
+	helperNodes := 0
+	for _, helper := range finder.helpers {
+		helperNodes += helper.nodes
+	}
+	if helperNodes == 0 || info.Nodes != finder.nodes+helperNodes {
+		t.Errorf("expected the helpers' %d nodes to be counted, got %d with %d of the main thread", helperNodes, info.Nodes, finder.nodes)
+	}
+
+	if err := finder.SetOption("Threads", "1"); err != nil {
+		t.Fatalf("failed to set threads: %v", err)
+	}
+	if len(finder.helpers) != 0 {
+		t.Errorf("expected no helper threads, got %d", len(finder.helpers))
+	}
+}
+
+func TestSingleThreadSearchIsDeterministic(t *testing.T) {
+	fen := "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"
+	var first SearchInfo
+	for i := 0; i < 3; i++ {
+		finder := newTestAlphaBetaMoveFinder(t, 5)
+		info, err := finder.iterativeDeepening(context.Background(), newTestBoard(t, fen), SearchLimits{Depth: 5})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if i == 0 {
+			first = info
+			continue
+		}
+		if info.Nodes != first.Nodes || info.Score != first.Score || !info.PV[0].Equal(first.PV[0]) {
+			t.Errorf("expected %d nodes, score %d and %v, got %d, %d and %v", first.Nodes, first.Score, first.PV[0], info.Nodes, info.Score, info.PV[0])
+		}
+	}
+}
+
+func TestVoteBestMove(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+	a, b := findMove(t, cb, "a1a8"), findMove(t, cb, "a1a7")
+
+	// Two helpers agreeing on b outvote the main thread
+	voted := voteBestMove([]SearchInfo{
+		{Depth: 8, Score: 50, PV: []board.Move{a}},
+		{Depth: 8, Score: 45, PV: []board.Move{b}},
+		{Depth: 9, Score: 48, PV: []board.Move{b}},
+		{},
+	})
+	if !voted.PV[0].Equal(b) || voted.Depth != 9 {
+		t.Errorf("expected the deepest result for %v, got %+v", b, voted)
+	}
+
+	// A single thread keeps its own result
+	if voted := voteBestMove([]SearchInfo{{Depth: 3, Score: 10, PV: []board.Move{a}}}); !voted.PV[0].Equal(a) {
+		t.Errorf("expected %v, got %v", a, voted.PV[0])
+	}
+}
+
+func TestMultiPVReportsTheBestLines(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	if err := finder.SetOption("MultiPV", "3"); err != nil {
+		t.Fatalf("failed to set MultiPV: %v", err)
+	}
+	var infos []SearchInfo
+	finder.SetInfoCallback(func(info SearchInfo) {
+		infos = append(infos, info)
+	})
+	info, err := finder.iterativeDeepening(context.Background(), cb, SearchLimits{Depth: 4})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	if len(infos) != 12 {
+		t.Fatalf("expected 3 lines for each of 4 iterations, got %d infos", len(infos))
+	}
+	for depth := 1; depth <= 4; depth++ {
+		lines := infos[3*(depth-1) : 3*depth]
+		seen := make(map[string]bool)
+		for i, line := range lines {
+			if line.Depth != depth || line.MultiPV != i+1 {
+				t.Errorf("expected line %d of depth %d, got line %d of depth %d", i+1, depth, line.MultiPV, line.Depth)
+			}
+			if i > 0 && line.Score > lines[i-1].Score {
+				t.Errorf("depth %d: line %d scores %d, above line %d's %d", depth, i+1, line.Score, i, lines[i-1].Score)
+			}
+			move := squareString(line.PV[0].From) + squareString(line.PV[0].To)
+			if seen[move] {
+				t.Errorf("depth %d: root move %s reported twice", depth, move)
+			}
+			seen[move] = true
+		}
+	}
+
+	best := infos[9]
+	if move := squareString(best.PV[0].From) + squareString(best.PV[0].To); move != "d2d5" {
+		t.Errorf("expected Rxd5 as the best line, got %s", move)
+	}
+	if infos[10].Score > best.Score-evaluation.RookValue {
+		t.Errorf("expected the second line to score far below Rxd5's %d, got %d", best.Score, infos[10].Score)
+	}
+	if !info.PV[0].Equal(best.PV[0]) || info.Score != best.Score {
+		t.Errorf("expected the best line to be returned, got %v with %d", info.PV[0], info.Score)
+	}
+}
+
+func TestMultiPVIsBoundedByTheLegalMoves(t *testing.T) {
+	// The king in the corner has three legal moves
+	cb := newTestBoard(t, "7k/8/8/8/8/8/8/R3K3 b - - 0 1")
+	finder := newTestAlphaBetaMoveFinder(t, 3)
+	if err := finder.SetOption("MultiPV", "5"); err != nil {
+		t.Fatalf("failed to set MultiPV: %v", err)
+	}
+	lines := 0
+	finder.SetInfoCallback(func(info SearchInfo) {
+		if info.Depth == 3 {
+			lines++
+		}
+	})
+	if _, err := finder.iterativeDeepening(context.Background(), cb, SearchLimits{Depth: 3}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if expected := len(cb.GenerateLegalMoves()); lines != expected {
+		t.Errorf("expected %d lines, got %d", expected, lines)
+	}
+}
+
+func TestSearchMovesRestrictEveryLine(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	allowed := []board.Move{findMove(t, cb, "e1f1"), findMove(t, cb, "d2d4")}
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	for name, value := range map[string]string{"MultiPV": "3", "Threads": "2"} {
+		if err := finder.SetOption(name, value); err != nil {
+			t.Fatalf("failed to set option: %v", err)
+		}
+	}
+	var infos []SearchInfo
+	finder.SetInfoCallback(func(info SearchInfo) {
+		infos = append(infos, info)
+	})
+	result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 4, SearchMoves: allowed})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	if len(infos) != 8 {
+		t.Errorf("expected 2 lines for each of 4 iterations, got %d infos", len(infos))
+	}
+	for _, info := range infos {
+		if !isSearchMove(info.PV[0], SearchLimits{SearchMoves: allowed}) {
+			t.Errorf("expected only the search moves reported, got %v", info.PV[0])
+		}
+	}
+	if !isSearchMove(result.BestMove, SearchLimits{SearchMoves: allowed}) {
+		t.Errorf("expected one of the search moves played, got %v", result.BestMove)
+	}
+	if entry, ok := finder.tt.Probe(cb.Hash(), 0); ok {
+		t.Errorf("expected a restricted search not to store the root, got %+v", entry)
+	}
+
+	// An unrestricted search of the same position still finds Rxd5
+	finder.SetInfoCallback(nil)
+	result, err = finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 4})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if move := squareString(result.BestMove.From) + squareString(result.BestMove.To); move != "d2d5" {
+		t.Errorf("expected Rxd5 once unrestricted, got %s", move)
+	}
+}
+
+func TestPonderMoveFromTT(t *testing.T) {
+	fen := "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	cb := newTestBoard(t, fen)
+	info, err := finder.iterativeDeepening(context.Background(), cb, SearchLimits{Depth: 4})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	reply := finder.ponderMoveFromTT(cb, info.PV[0])
+	if reply == nil || !reply.Equal(info.PV[1]) {
+		t.Errorf("expected the reply of the principal variation %v, got %v", info.PV[1], reply)
+	}
+	if cb.FEN() != fen {
+		t.Errorf("expected the board restored, got %s", cb.FEN())
+	}
+}
+
+func TestPonderingSearchWaitsForPonderhit(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	ponderHit := make(chan struct{})
+	limits := SearchLimits{Ponder: true, PonderHit: ponderHit, MoveTime: 50 * time.Millisecond}
+	done := make(chan *SearchResult)
+	go func() {
+		result, err := finder.FindBestMove(context.Background(), newTestBoard(t, "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1"), limits)
+		if err != nil {
+			t.Errorf("search failed: %v", err)
+		}
+		done <- result
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("expected the move time to apply only after ponderhit")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(ponderHit)
+	select {
+	case result := <-done:
+		if squareString(result.BestMove.From)+squareString(result.BestMove.To) != "d2d5" {
+			t.Errorf("expected Rxd5, got %v", result.BestMove)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the search to stop after ponderhit")
+	}
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	board "jesus_chess/domain/board"
+)
+
+const (
+	// MaxContempt bounds the Contempt option, in centipawns.
+	MaxContempt = 100
+	// contemptEloScale is the Elo difference to the opponent worth a
+	// centipawn of contempt, and maxAutoContempt bounds what the opponent's
+	// rating adds to the Contempt option.
+	contemptEloScale = 10
+	maxAutoContempt  = 50
+)
+
+// Opponent is the player the GUI says the engine is playing, from the
+// UCI_Opponent option.
+type Opponent struct {
+	Title string
+	// Elo is zero when the opponent's rating is unknown
+	Elo      int
+	Computer bool
+	Name     string
+}
+
+// parseOpponent reads the value of UCI_Opponent, "<title> <elo> <computer
+// or human> <name>", where the title and the Elo may be "none" and the
+// name may contain spaces. An empty value forgets the opponent.
+func parseOpponent(value string) (Opponent, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return Opponent{}, nil
+	}
+	if len(fields) < 3 {
+		return Opponent{}, fmt.Errorf("invalid value for UCI_Opponent: %s", value)
+	}
+
+	var opponent Opponent
+	if fields[0] != "none" {
+		opponent.Title = fields[0]
+	}
+	if fields[1] != "none" {
+		elo, err := strconv.Atoi(fields[1])
+		if err != nil || elo <= 0 {
+			return Opponent{}, fmt.Errorf("invalid Elo for UCI_Opponent: %s", fields[1])
+		}
+		opponent.Elo = elo
+	}
+	switch fields[2] {
+	case "computer":
+		opponent.Computer = true
+	case "human":
+	default:
+		return Opponent{}, fmt.Errorf("invalid player type for UCI_Opponent: %s", fields[2])
+	}
+	opponent.Name = strings.Join(fields[3:], " ")
+	return opponent, nil
+}
+
+// autoContempt returns the contempt for a draw against opponent by an
+// engine rated engineElo: a draw is worth less the weaker the opponent,
+// and is welcome against a stronger one. An opponent of unknown strength
+// changes nothing.
+func autoContempt(engineElo int, opponent Opponent) int {
+	if opponent.Elo == 0 {
+		return 0
+	}
+	return max(min((engineElo-opponent.Elo)/contemptEloScale, maxAutoContempt), -maxAutoContempt)
+}
+
+// drawScore scores a draw in the position from the side to move's point
+// of view. The engine, the side to move at the root, values a draw at
+// minus the contempt, so a positive contempt makes it play on in positions
+// it judges equal and a negative one makes it steer for the draw.
+func (f *AlphaBetaMoveFinder) drawScore(chessBoard board.ChessBoard) int {
+	if chessBoard.SideToMove() == f.rootSide {
+		return DrawScore - f.drawContempt
+	}
+	return DrawScore + f.drawContempt
+}
+
+// setDrawContempt sets the contempt of the search about to start. The
+// transposition table is cleared when its draws were scored with another
+// contempt, as happens when the engine changes sides in analysis, since
+// the scores above them would be off by twice the contempt.
+func (f *AlphaBetaMoveFinder) setDrawContempt() {
+	f.drawContempt = f.contempt + autoContempt(f.strength.elo(), f.opponent)
+	whiteContempt := f.drawContempt
+	if f.rootSide == board.Black {
+		whiteContempt = -whiteContempt
+	}
+	if whiteContempt != f.ttContempt {
+		f.tt.Clear()
+		f.ttContempt = whiteContempt
+	}
+}
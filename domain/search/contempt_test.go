@@ -0,0 +1,148 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	board "jesus_chess/domain/board"
+)
+
+func TestParseOpponent(t *testing.T) {
+	tests := []struct {
+		value    string
+		opponent Opponent
+	}{
+		{"GM 2800 human Gary Kasparov", Opponent{Title: "GM", Elo: 2800, Name: "Gary Kasparov"}},
+		{"none none computer Shredder", Opponent{Computer: true, Name: "Shredder"}},
+		{"", Opponent{}},
+	}
+	for _, test := range tests {
+		opponent, err := parseOpponent(test.value)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", test.value, err)
+			continue
+		}
+		if opponent != test.opponent {
+			t.Errorf("expected %+v for %q, got %+v", test.opponent, test.value, opponent)
+		}
+	}
+
+	for _, value := range []string{"GM 2800", "GM strong human Gary", "IM 2400 robot Deep Thought"} {
+		if _, err := parseOpponent(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestAutoContempt(t *testing.T) {
+	tests := []struct {
+		engineElo int
+		opponent  Opponent
+		contempt  int
+	}{
+		{MaxElo, Opponent{}, 0},
+		{MaxElo, Opponent{Elo: 2000}, 40},
+		{MaxElo, Opponent{Elo: 2700}, -30},
+		{MaxElo, Opponent{Elo: 1000}, maxAutoContempt},
+		{MinElo, Opponent{Elo: 2800}, -maxAutoContempt},
+	}
+	for _, test := range tests {
+		if contempt := autoContempt(test.engineElo, test.opponent); contempt != test.contempt {
+			t.Errorf("expected %d at %d against %+v, got %d", test.contempt, test.engineElo, test.opponent, contempt)
+		}
+	}
+}
+
+// TestContemptAvoidsOrSeeksRepetition searches positions after the knights
+// went out and back, where playing Nf3 again repeats the position. Black
+// has the better centre in the first position, so without contempt white
+// takes the draw; in the opening position white has the better moves.
+func TestContemptAvoidsOrSeeksRepetition(t *testing.T) {
+	const (
+		worse = "rnbqkbnr/ppp2ppp/8/3pp3/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+		even  = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	)
+	tests := []struct {
+		name    string
+		fen     string
+		options map[string]string
+		repeats bool
+	}{
+		{"worse without contempt", worse, nil, true},
+		{"worse with contempt", worse, map[string]string{"Contempt": "100"}, false},
+		{"worse against a weaker opponent", worse, map[string]string{"Contempt": "50", "UCI_Opponent": "none 1400 human Club Player"}, false},
+		{"even without contempt", even, nil, false},
+		{"even with negative contempt", even, map[string]string{"Contempt": "-100"}, true},
+		{"even against a stronger opponent", even, map[string]string{"UCI_Opponent": "none 2900 computer Stronger"}, true},
+		{"even against an unrated opponent", even, map[string]string{"UCI_Opponent": "GM none human Unrated"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finder := newTestAlphaBetaMoveFinder(t, 5)
+			for name, value := range test.options {
+				if err := finder.SetOption(name, value); err != nil {
+					t.Fatalf("failed to set option: %v", err)
+				}
+			}
+			cb := newTestBoard(t, test.fen)
+			for _, move := range []string{"g1f3", "g8f6", "f3g1", "f6g8"} {
+				cb.MakeMove(findMove(t, cb, move))
+			}
+			result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 5})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			repeats := result.BestMove.Equal(findMove(t, cb, "g1f3"))
+			if repeats != test.repeats {
+				t.Errorf("expected repeating %v, got %s with score %d", test.repeats, squareString(result.BestMove.From)+squareString(result.BestMove.To), result.Score)
+			}
+		})
+	}
+}
+
+func TestDrawScoreIsFromTheEnginesPointOfView(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	if err := finder.SetOption("Contempt", "30"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/8/4K3 b - - 0 1")
+	if _, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 1}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if score := finder.drawScore(cb); score != -30 {
+		t.Errorf("expected a draw to cost the engine the contempt, got %d", score)
+	}
+	cb.MakeMove(findMove(t, cb, "e8d8"))
+	if score := finder.drawScore(cb); score != 30 {
+		t.Errorf("expected a draw to gain the opponent the contempt, got %d", score)
+	}
+}
+
+func TestContemptClearsTheTableWhenTheEngineChangesSides(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	cb := newTestBoard(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	// stored searches the position and reports whether the table still
+	// holds it once the engine is to play side
+	stored := func(side board.Color) bool {
+		if _, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 4}); err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		finder.rootSide = side
+		finder.setDrawContempt()
+		_, found := finder.tt.Probe(cb.Hash(), 0)
+		return found
+	}
+
+	if !stored(board.Black) {
+		t.Errorf("expected the table kept without contempt")
+	}
+	if err := finder.SetOption("Contempt", "30"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if !stored(board.White) {
+		t.Errorf("expected the table kept for the same side and contempt")
+	}
+	if stored(board.Black) || finder.ttContempt != -30 {
+		t.Errorf("expected the table cleared for the other side, got contempt %d", finder.ttContempt)
+	}
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	board "jesus_chess/domain/board"
+)
+
+const (
+	// singularMinDepth is the shallowest node where the hash move is tested
+	// for singularity, and singularDepthMargin how much shallower than the
+	// node its transposition table entry may be.
+	singularMinDepth    = 6
+	singularDepthMargin = 3
+	// singularMarginPerPly scales with depth the margin below the hash
+	// move's score that every other move must fail to reach for the hash
+	// move to be singular.
+	singularMarginPerPly = 2
+)
+
+// Extensions switches the search extensions on and off. An extension
+// searches a move one ply deeper than its siblings, so that forcing lines
+// are followed past the nominal depth. The plies a path may be extended by
+// are bounded by the depth of the iteration, so that a path is never more
+// than twice as long.
+type Extensions struct {
+	// Check extends moves that give check.
+	Check bool
+	// Singular extends the hash move when a reduced search shows every
+	// other move to be much worse. When instead several moves beat beta,
+	// the node is cut off (multi-cut).
+	Singular bool
+	// PassedPawn extends pushes of passed pawns to the sixth and seventh
+	// ranks.
+	PassedPawn bool
+	// Recapture extends captures back on the square of the previous
+	// capture.
+	Recapture bool
+}
+
+// DefaultExtensions enables every extension.
+func DefaultExtensions() Extensions {
+	return Extensions{
+		Check:      true,
+		Singular:   true,
+		PassedPawn: true,
+		Recapture:  true,
+	}
+}
+
+// toggles names the UCI option of each extension.
+func (e *Extensions) toggles() []selectivityToggle {
+	return []selectivityToggle{
+		{"Check Extensions", &e.Check},
+		{"Singular Extensions", &e.Singular},
+		{"Passed Pawn Extensions", &e.PassedPawn},
+		{"Recapture Extensions", &e.Recapture},
+	}
+}
+
+// isRecapture reports whether move captures back on the square where
+// previous captured.
+func isRecapture(move board.Move, previous *board.Move) bool {
+	return previous != nil && previous.CapturedPiece != nil && move.CapturedPiece != nil && move.To == previous.To
+}
+
+// isPassedPawnPush reports whether move pushes a pawn that no enemy pawn
+// can stop or capture to its sixth or seventh rank.
+func isPassedPawnPush(chessBoard board.ChessBoard, move board.Move) bool {
+	if move.Piece.Name != board.Pawn || move.Promotion != nil {
+		return false
+	}
+	forward, relativeRank := 1, move.To.Rank
+	if move.Piece.Color == board.Black {
+		forward, relativeRank = -1, board.BoardHeight-1-move.To.Rank
+	}
+	if relativeRank < 5 {
+		return false
+	}
+
+	for rank := move.To.Rank + forward; rank >= 0 && rank < board.BoardHeight; rank += forward {
+		for file := move.To.File - 1; file <= move.To.File+1; file++ {
+			if file < 0 || file >= board.BoardWidth {
+				continue
+			}
+			piece := chessBoard.PieceAt(board.Square{Rank: rank, File: file})
+			if piece != nil && piece.Name == board.Pawn && piece.Color != move.Piece.Color {
+				return false
+			}
+		}
+	}
+	return true
+}
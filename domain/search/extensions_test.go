@@ -0,0 +1,93 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	board "jesus_chess/domain/board"
+)
+
+// TestExtensionsFindDeeperTactics searches positions at a depth where the
+// search without extensions misses a tactic that the extension under test
+// finds, by following the forcing line further.
+func TestExtensionsFindDeeperTactics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("searches tactical positions deeply")
+	}
+	// Philidor's legacy: Nf7+ Kg8 Nh6+ Kh8 Qg8+ Rxg8 Nf7#
+	const smotheredMate = "3r3k/pp4pp/8/6N1/2Q5/8/PP4PP/6K1 w - - 0 1"
+	tests := []struct {
+		name       string
+		fen        string
+		depth      int
+		extensions Extensions
+		// minScore is the score the extension must reach and the search
+		// without extensions must not
+		minScore int
+	}{
+		{"check extension finds a smothered mate", smotheredMate, 6, Extensions{Check: true}, MateScore - MaxPly},
+		{"singular extension finds a smothered mate", smotheredMate, 9, Extensions{Singular: true}, MateScore - MaxPly},
+		{"passed pawn extension sees the pawn queen", "7k/8/8/2P5/8/8/6K1/7n w - - 0 1", 5, Extensions{PassedPawn: true}, 600},
+		{"recapture extension sees the knight win the exchange", "3knr1r/p2pN1Q1/b1p1Pp2/6p1/2q1P1P1/1pNB3p/PPPn1P1P/2RK2R1 w - - 4 16", 3, Extensions{Recapture: true}, 700},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := func(extensions Extensions) int {
+				finder := newTestAlphaBetaMoveFinder(t, test.depth)
+				finder.extensions = extensions
+				info, err := finder.iterativeDeepening(context.Background(), newTestBoard(t, test.fen), SearchLimits{Depth: test.depth})
+				if err != nil {
+					t.Fatalf("search failed: %v", err)
+				}
+				return info.Score
+			}
+			if baseline := score(Extensions{}); baseline >= test.minScore {
+				t.Errorf("expected the search without extensions to score below %d, got %d", test.minScore, baseline)
+			}
+			if extended := score(test.extensions); extended < test.minScore {
+				t.Errorf("expected the extended search to score at least %d, got %d", test.minScore, extended)
+			}
+		})
+	}
+}
+
+func TestIsPassedPawnPush(t *testing.T) {
+	tests := []struct {
+		fen    string
+		move   string
+		passed bool
+	}{
+		{"4k3/8/8/2P5/8/8/8/4K3 w - - 0 1", "c5c6", true},
+		{"4k3/8/2P5/8/8/8/8/4K3 w - - 0 1", "c6c7", true},
+		{"4k3/8/8/8/2P5/8/8/4K3 w - - 0 1", "c4c5", false},
+		{"4k3/1p6/8/2P5/8/8/8/4K3 w - - 0 1", "c5c6", false},
+		{"4k3/8/8/8/8/2p5/8/4K3 b - - 0 1", "c3c2", true},
+		{"4k3/8/8/8/2p5/8/3P4/4K3 b - - 0 1", "c4c3", false},
+		{"4k3/8/8/2R5/8/8/8/4K3 w - - 0 1", "c5c6", false},
+	}
+	for _, test := range tests {
+		cb := newTestBoard(t, test.fen)
+		if got := isPassedPawnPush(cb, findMove(t, cb, test.move)); got != test.passed {
+			t.Errorf("%s %s: expected %v, got %v", test.fen, test.move, test.passed, got)
+		}
+	}
+}
+
+func TestIsRecapture(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/3p4/4n3/8/5N2/8/4K3 w - - 0 1")
+	capture := findMove(t, cb, "f3e5")
+	cb.MakeMove(capture)
+	recapture := findMove(t, cb, "d6e5")
+	if !isRecapture(recapture, &capture) {
+		t.Errorf("expected dxe5 to recapture after Nxe5")
+	}
+	if isRecapture(recapture, nil) {
+		t.Errorf("expected no recapture without a previous move")
+	}
+
+	quiet := board.Move{From: board.Square{Rank: 2, File: 5}, To: board.Square{Rank: 4, File: 4}}
+	if isRecapture(recapture, &quiet) {
+		t.Errorf("expected no recapture after a quiet move")
+	}
+}
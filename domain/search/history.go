@@ -0,0 +1,125 @@
+package search
+
+import (
+	board "jesus_chess/domain/board"
+)
+
+const (
+	// maxHistory bounds history scores. Updates pull a score towards the
+	// bound in proportion to the distance left, so scores saturate instead
+	// of overflowing and old results fade as new ones arrive.
+	maxHistory = 16384
+	// maxHistoryBonus caps the bonus of a single update.
+	maxHistoryBonus = 1536
+)
+
+// History collects what the search learns about quiet moves as it runs,
+// for ordering them at later nodes:
+//
+//   - killers: two quiet moves per ply that recently caused a cutoff
+//   - butterfly history: a score per side and from/to square pair
+//   - countermoves: the quiet move that last refuted each previous move,
+//     keyed by that move's piece and destination
+//   - continuation history: a score per pair of consecutive moves, keyed
+//     by piece and destination, for the moves one and two plies back
+type History struct {
+	killers      [MaxPly][2]board.Move
+	butterfly    [2][64][64]int
+	counterMoves [12][64]board.Move
+	continuation [12][64][12][64]int
+}
+
+// Ordering locates a node for the history-based ordering of its quiet
+// moves. The zero value orders quiet moves by generation only.
+type Ordering struct {
+	History *History
+	Ply     int
+	// Previous holds the moves that led to the node, the most recent
+	// first; nil where there is none.
+	Previous [2]*board.Move
+}
+
+func NewHistory() *History {
+	return &History{}
+}
+
+// Clear forgets everything learned, for a new game.
+func (h *History) Clear() {
+	*h = History{}
+}
+
+// Killers returns the killer moves of ply.
+func (h *History) Killers(ply int) [2]board.Move {
+	return h.killers[ply]
+}
+
+// CounterMove returns the move that last refuted previous, if any.
+func (h *History) CounterMove(previous *board.Move) (board.Move, bool) {
+	if previous == nil {
+		return board.Move{}, false
+	}
+	move := h.counterMoves[board.PieceIndex(previous.Piece)][previous.To.Index()]
+	return move, move.From != move.To
+}
+
+// QuietScore returns the history score of a quiet move at the node
+// described by ordering.
+func (h *History) QuietScore(move board.Move, ordering Ordering) int {
+	score := h.butterfly[colorIndex(move.Piece.Color)][move.From.Index()][move.To.Index()]
+	for _, previous := range ordering.Previous {
+		if previous != nil {
+			score += *h.continuationEntry(previous, move)
+		}
+	}
+	return score
+}
+
+// UpdateQuiets rewards the quiet move that caused a cutoff at a node of
+// depth and penalises the quiet moves searched before it without one.
+func (h *History) UpdateQuiets(best board.Move, failed []board.Move, depth int, ordering Ordering) {
+	bonus := min(32*depth*depth, maxHistoryBonus)
+
+	killers := &h.killers[ordering.Ply]
+	if !killers[0].Equal(best) {
+		killers[1] = killers[0]
+		killers[0] = best
+	}
+	if previous := ordering.Previous[0]; previous != nil {
+		h.counterMoves[board.PieceIndex(previous.Piece)][previous.To.Index()] = best
+	}
+
+	h.updateQuiet(best, bonus, ordering)
+	for _, move := range failed {
+		h.updateQuiet(move, -bonus, ordering)
+	}
+}
+
+func (h *History) updateQuiet(move board.Move, bonus int, ordering Ordering) {
+	applyGravity(&h.butterfly[colorIndex(move.Piece.Color)][move.From.Index()][move.To.Index()], bonus)
+	for _, previous := range ordering.Previous {
+		if previous != nil {
+			applyGravity(h.continuationEntry(previous, move), bonus)
+		}
+	}
+}
+
+func (h *History) continuationEntry(previous *board.Move, move board.Move) *int {
+	return &h.continuation[board.PieceIndex(previous.Piece)][previous.To.Index()][board.PieceIndex(move.Piece)][move.To.Index()]
+}
+
+// applyGravity adds bonus to the score, scaled down as the score nears
+// maxHistory in the bonus's direction.
+func applyGravity(score *int, bonus int) {
+	magnitude := bonus
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	*score += bonus - *score*magnitude/maxHistory
+}
+
+func colorIndex(color board.Color) int {
+	if color == board.White {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,323 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	board "jesus_chess/domain/board"
+)
+
+const (
+	// maxMateMoves bounds the mates searched for, in moves of the attacker.
+	maxMateMoves = (MaxPly - 1) / 2
+	// mateEntrySize is the memory a position remembered by a mate search
+	// takes in bytes, with the overhead of the map, rounded up. The Hash
+	// option bounds the table to as much memory as the transposition
+	// table's; once it is full, the positions already known are still
+	// updated.
+	mateEntrySize = 256
+)
+
+var (
+	// ErrNoMateFound is returned by a mate search that proved there is no
+	// mate within the moves asked for.
+	ErrNoMateFound = errors.New("no mate found")
+	// ErrMateSearchStopped is returned by a mate search stopped by its
+	// limits or by the GUI before it found a mate or proved there is none.
+	ErrMateSearchStopped = errors.New("mate search stopped")
+)
+
+// mateEntry records what is known about mating from a position: the
+// fewest moves a mate has been proven in with the move starting it, and
+// the most moves every mate has been refuted in.
+type mateEntry struct {
+	proven  int
+	move    board.Move
+	refuted int
+}
+
+// mateCandidate is an attacker move with its place in the move order.
+type mateCandidate struct {
+	move  board.Move
+	order int
+}
+
+// mateSearch proves mates rather than estimating scores: the attacker
+// needs one move that mates whatever the defender replies, and the
+// defender one reply that escapes. Mates in 1, 2, ... are searched in
+// turn, so that the first mate proven is the shortest. The attacker tries
+// checks first, then captures, and only checks with one move left.
+// Positions already proven or refuted are remembered, which transposes
+// freely since draws by repetition and the fifty-move rule are ignored.
+type mateSearch struct {
+	chessBoard  board.ChessBoard
+	ctx         context.Context
+	limits      SearchLimits
+	timeManager *TimeManager
+	// pondering is set until the ponderhit, which starts the clock
+	pondering   bool
+	deadline    time.Time
+	hasDeadline bool
+	stopped     bool
+	nodes       int
+
+	table      map[uint64]mateEntry
+	maxEntries int
+	rootKey    uint64
+	lists      [MaxPly]board.MoveList
+	candidates [MaxPly][]mateCandidate
+}
+
+// findMate runs a mate search for a mate in at most limits.Mate moves,
+// reporting the mating line when one is found.
+func (f *AlphaBetaMoveFinder) findMate(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) (*SearchResult, error) {
+	start := time.Now()
+	if len(chessBoard.GenerateLegalMoves()) == 0 {
+		return nil, fmt.Errorf("no legal moves available")
+	}
+	s := f.newMateSearch(ctx, chessBoard, limits)
+	if !s.pondering {
+		s.startClock(start)
+	}
+
+	for moves := 1; moves <= min(limits.Mate, maxMateMoves); moves++ {
+		if !s.attack(moves, 0) {
+			if s.stopped {
+				break
+			}
+			continue
+		}
+		// A limit reached while the line is rebuilt shortens it, but the
+		// mate is proven all the same
+		pv := s.line(moves)
+		info := SearchInfo{
+			Depth:    2*moves - 1,
+			SelDepth: 2*moves - 1,
+			MultiPV:  1,
+			Score:    MateScore - (2*moves - 1),
+			Nodes:    s.nodes,
+			Time:     time.Since(start),
+			PV:       pv,
+		}
+		if f.onInfo != nil {
+			f.onInfo(info)
+		}
+		waitForGUI(ctx, limits)
+		return resultFromPV(pv, info.Score), nil
+	}
+	waitForGUI(ctx, limits)
+	if s.stopped {
+		return nil, ErrMateSearchStopped
+	}
+	return nil, ErrNoMateFound
+}
+
+// newMateSearch prepares a mate search of chessBoard under limits, with a
+// table as large as the transposition table.
+func (f *AlphaBetaMoveFinder) newMateSearch(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) *mateSearch {
+	f.timeManager.Start(chessBoard, limits)
+	return &mateSearch{
+		chessBoard:  chessBoard,
+		ctx:         ctx,
+		limits:      limits,
+		timeManager: f.timeManager,
+		pondering:   limits.Ponder,
+		table:       make(map[uint64]mateEntry),
+		maxEntries:  f.tt.Size() / mateEntrySize,
+		rootKey:     chessBoard.Hash(),
+	}
+}
+
+// attack reports whether the side to move mates within moves moves. ply
+// selects the move lists to use; at the root, ply zero, only the moves the
+// limits allow are tried.
+func (s *mateSearch) attack(moves, ply int) bool {
+	s.nodes++
+	s.checkLimits()
+	if s.stopped {
+		return false
+	}
+	key := s.chessBoard.Hash()
+	entry := s.table[key]
+	if entry.proven > 0 && entry.proven <= moves {
+		return true
+	}
+	if entry.refuted >= moves {
+		return false
+	}
+
+	for _, candidate := range s.attackerMoves(moves, ply) {
+		s.chessBoard.MakeMove(candidate.move)
+		mates := s.defend(moves, ply+1)
+		s.chessBoard.UndoMove()
+		if s.stopped {
+			return false
+		}
+		if mates {
+			entry.proven, entry.move = moves, candidate.move
+			s.store(key, entry)
+			return true
+		}
+	}
+	entry.refuted = moves
+	s.store(key, entry)
+	return false
+}
+
+// store remembers entry for the position of key, unless the table is full
+// and does not know the position yet. The root is always remembered, for
+// its mating move to be reported.
+func (s *mateSearch) store(key uint64, entry mateEntry) {
+	if _, known := s.table[key]; known || key == s.rootKey || len(s.table) < s.maxEntries {
+		s.table[key] = entry
+	}
+}
+
+// defend reports whether every reply of the side to move, after the
+// attacker's move, is mated within the moves left, that move included.
+func (s *mateSearch) defend(moves, ply int) bool {
+	s.nodes++
+	inCheck := s.chessBoard.InCheck(s.chessBoard.SideToMove())
+	if moves == 1 && !inCheck {
+		return false
+	}
+	replies := &s.lists[ply]
+	s.chessBoard.GenerateMoves(replies)
+	if replies.Len() == 0 {
+		return inCheck
+	}
+	if moves == 1 {
+		return false
+	}
+
+	for _, reply := range replies.Moves() {
+		s.chessBoard.MakeMove(reply)
+		mated := s.attack(moves-1, ply+1)
+		s.chessBoard.UndoMove()
+		if !mated {
+			return false
+		}
+	}
+	return true
+}
+
+// attackerMoves orders the attacker's moves at ply, checks first and then
+// captures. With one move left only checks are returned.
+func (s *mateSearch) attackerMoves(moves, ply int) []mateCandidate {
+	list := &s.lists[ply]
+	s.chessBoard.GenerateMoves(list)
+	candidates := s.candidates[ply][:0]
+	for _, move := range list.Moves() {
+		if ply == 0 && !isSearchMove(move, s.limits) {
+			continue
+		}
+		s.chessBoard.MakeMove(move)
+		givesCheck := s.chessBoard.InCheck(s.chessBoard.SideToMove())
+		s.chessBoard.UndoMove()
+
+		order := 0
+		switch {
+		case givesCheck:
+			order = 2
+		case moves == 1:
+			continue
+		case move.CapturedPiece != nil:
+			order = 1
+		}
+		candidates = append(candidates, mateCandidate{move: move, order: order})
+	}
+	slices.SortStableFunc(candidates, func(a, b mateCandidate) int {
+		return b.order - a.order
+	})
+	s.candidates[ply] = candidates
+	return candidates
+}
+
+// line returns the mating line of a position proven to mate in moves
+// moves, in which the defender puts the mate off as long as it can. A line
+// cut short by the limits still starts with the mating move. So does a
+// line through a position the full table left out, which ends there.
+func (s *mateSearch) line(moves int) []board.Move {
+	var pv []board.Move
+	for moves > 0 {
+		entry, known := s.table[s.chessBoard.Hash()]
+		if !known || entry.proven == 0 {
+			break
+		}
+		s.chessBoard.MakeMove(entry.move)
+		pv = append(pv, entry.move)
+		if s.stopped {
+			break
+		}
+
+		var longest board.Move
+		longestMoves := 0
+		for _, reply := range s.chessBoard.GenerateLegalMoves() {
+			s.chessBoard.MakeMove(reply)
+			replyMoves := s.shortestMate(entry.proven - 1)
+			s.chessBoard.UndoMove()
+			if replyMoves > longestMoves {
+				longest, longestMoves = reply, replyMoves
+			}
+		}
+		if longestMoves == 0 || s.stopped {
+			break
+		}
+		s.chessBoard.MakeMove(longest)
+		pv = append(pv, longest)
+		moves = longestMoves
+	}
+	for range pv {
+		s.chessBoard.UndoMove()
+	}
+	return pv
+}
+
+// shortestMate returns the fewest moves, up to moves, the side to move
+// mates in, or zero if it does not.
+func (s *mateSearch) shortestMate(moves int) int {
+	for shortest := 1; shortest <= moves; shortest++ {
+		if s.attack(shortest, 1) {
+			return shortest
+		}
+	}
+	return 0
+}
+
+// checkLimits sets s.stopped once the node limit is reached, the
+// deadline passes or the context is cancelled.
+func (s *mateSearch) checkLimits() {
+	if s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes {
+		s.stopped = true
+	}
+	if s.nodes%checkInterval == 0 {
+		s.checkPonderHit()
+		if s.ctx.Err() != nil || s.hasDeadline && time.Now().After(s.deadline) {
+			s.stopped = true
+		}
+	}
+}
+
+// startClock starts timing the search at now.
+func (s *mateSearch) startClock(now time.Time) {
+	hardLimit, timed := s.timeManager.HardLimit()
+	s.hasDeadline = timed
+	s.deadline = now.Add(hardLimit)
+}
+
+// checkPonderHit starts the clock of a pondering search once the opponent
+// has played the expected reply.
+func (s *mateSearch) checkPonderHit() {
+	if !s.pondering {
+		return
+	}
+	select {
+	case <-s.limits.PonderHit:
+		s.pondering = false
+		s.startClock(time.Now())
+	default:
+	}
+}
@@ -0,0 +1,195 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	board "jesus_chess/domain/board"
+)
+
+func TestMateSearchFindsShortestMates(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		mate   int
+		mateIn int
+	}{
+		{"back rank mate in 1", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 1, 1},
+		{"rook ladder mate in 2", "6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1", 3, 2},
+		{"smothered mate in 4", "3r3k/pp4pp/8/6N1/2Q5/8/PP4PP/6K1 w - - 0 1", 4, 4},
+		{"black mates in 2", "1r4k1/r7/8/8/8/8/8/6K1 b - - 0 1", 2, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finder := newTestAlphaBetaMoveFinder(t, 1)
+			var infos []SearchInfo
+			finder.SetInfoCallback(func(info SearchInfo) {
+				infos = append(infos, info)
+			})
+			cb := newTestBoard(t, test.fen)
+			result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Mate: test.mate})
+			if err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			if MateDistance(result.Score) != test.mateIn {
+				t.Errorf("expected mate in %d, got score %d", test.mateIn, result.Score)
+			}
+			if len(infos) != 1 || infos[0].Score != result.Score || len(infos[0].PV) != 2*test.mateIn-1 {
+				t.Fatalf("expected one report of the full mating line, got %+v", infos)
+			}
+
+			for _, move := range result.PV {
+				if err := cb.MakeMove(move); err != nil {
+					t.Fatalf("illegal move in the mating line: %v", err)
+				}
+			}
+			if len(cb.GenerateLegalMoves()) != 0 || !cb.InCheck(cb.SideToMove()) {
+				t.Errorf("expected the line to end in mate, got %s", cb.FEN())
+			}
+		})
+	}
+}
+
+func TestMateSearchReportsNoMate(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		mate int
+	}{
+		{"opening", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 3},
+		{"smothered mate needs four moves", "3r3k/pp4pp/8/6N1/2Q5/8/PP4PP/6K1 w - - 0 1", 3},
+		// The queen can stalemate the king but not mate it
+		{"stalemate is no mate", "k7/8/1K6/8/8/8/8/1Q6 w - - 0 1", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finder := newTestAlphaBetaMoveFinder(t, 1)
+			start := time.Now()
+			_, err := finder.FindBestMove(context.Background(), newTestBoard(t, test.fen), SearchLimits{Mate: test.mate})
+			if !errors.Is(err, ErrNoMateFound) {
+				t.Errorf("expected no mate found, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected a quick answer, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestMateSearchReportsBeingStopped(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	_, err := finder.FindBestMove(context.Background(), newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"), SearchLimits{Mate: 5, Nodes: 100})
+	if !errors.Is(err, ErrMateSearchStopped) {
+		t.Errorf("expected the mate search stopped, got %v", err)
+	}
+}
+
+func TestMateSearchHonoursSearchMoves(t *testing.T) {
+	cb := newTestBoard(t, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	limits := SearchLimits{Mate: 2, SearchMoves: []board.Move{findMove(t, cb, "a1a2")}}
+	if _, err := finder.FindBestMove(context.Background(), cb, limits); !errors.Is(err, ErrNoMateFound) {
+		t.Errorf("expected no mate without Ra8, got %v", err)
+	}
+}
+
+// TestMateSearchReportsAProvenMateWhenStopped stops the search at every
+// node count from the proof of the mate to the end of its line, which
+// must not lose the mate.
+func TestMateSearchReportsAProvenMateWhenStopped(t *testing.T) {
+	const fen = "3r3k/pp4pp/8/6N1/2Q5/8/PP4PP/6K1 w - - 0 1"
+	var fullNodes int
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	finder.SetInfoCallback(func(info SearchInfo) {
+		fullNodes = info.Nodes
+	})
+	full, err := finder.FindBestMove(context.Background(), newTestBoard(t, fen), SearchLimits{Mate: 4})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	stopAt := func(nodes int) (*SearchResult, error) {
+		return newTestAlphaBetaMoveFinder(t, 1).FindBestMove(context.Background(), newTestBoard(t, fen), SearchLimits{Mate: 4, Nodes: nodes})
+	}
+	proof := sort.Search(fullNodes, func(nodes int) bool {
+		_, err := stopAt(nodes)
+		return err == nil
+	})
+	if proof >= fullNodes {
+		t.Fatalf("expected the line to take nodes after the proof, proven at %d of %d", proof, fullNodes)
+	}
+	for _, nodes := range []int{proof, (proof + fullNodes) / 2, fullNodes - 1} {
+		result, err := stopAt(nodes)
+		if err != nil {
+			t.Fatalf("expected the mate proven within %d nodes, got %v", nodes, err)
+		}
+		if len(result.PV) == 0 || !result.PV[0].Equal(full.BestMove) || result.Score != full.Score {
+			t.Errorf("expected mate in 4 starting with the mating move at %d nodes, got %+v", nodes, result)
+		}
+	}
+}
+
+func TestMateSearchWithAFullTable(t *testing.T) {
+	cb := newTestBoard(t, "3r3k/pp4pp/8/6N1/2Q5/8/PP4PP/6K1 w - - 0 1")
+	full, err := newTestAlphaBetaMoveFinder(t, 1).FindBestMove(context.Background(), cb, SearchLimits{Mate: 4})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	s := newTestAlphaBetaMoveFinder(t, 1).newMateSearch(context.Background(), cb, SearchLimits{Mate: 4})
+	s.maxEntries = 10
+	for moves := 1; moves <= 4; moves++ {
+		if s.attack(moves, 0) {
+			if moves != 4 {
+				t.Fatalf("expected mate in 4, got mate in %d", moves)
+			}
+			if pv := s.line(moves); len(pv) == 0 || !pv[0].Equal(full.BestMove) {
+				t.Errorf("expected the line to start with the mating move, got %d moves", len(pv))
+			}
+			break
+		}
+	}
+	if len(s.table) > s.maxEntries+1 {
+		t.Errorf("expected at most %d positions and the root, got %d", s.maxEntries, len(s.table))
+	}
+}
+
+func TestMateSearchTableFollowsHash(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	if err := finder.SetOption("Hash", "1"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	s := finder.newMateSearch(context.Background(), newTestBoard(t, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1"), SearchLimits{Mate: 1})
+	if s.maxEntries != 1<<20/mateEntrySize {
+		t.Errorf("expected a megabyte of positions, got %d", s.maxEntries)
+	}
+}
+
+// TestPonderingMateSearchWaitsForPonderhit ponders on a mate that takes
+// longer to prove than the move time, which only counts from the
+// ponderhit.
+func TestPonderingMateSearchWaitsForPonderhit(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 1)
+	ponderHit := make(chan struct{})
+	limits := SearchLimits{Mate: 4, Ponder: true, PonderHit: ponderHit, MoveTime: 10 * time.Millisecond}
+	done := make(chan error)
+	go func() {
+		_, err := finder.FindBestMove(context.Background(), newTestBoard(t, "3r3k/pp4pp/8/6N1/2Q5/8/PP4PP/6K1 w - - 0 1"), limits)
+		done <- err
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+	close(ponderHit)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the mate found while pondering, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the search to end after ponderhit")
+	}
+}
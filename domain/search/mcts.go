@@ -0,0 +1,552 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"time"
+
+	board "jesus_chess/domain/board"
+	evaluation "jesus_chess/domain/evaluation"
+	logging "jesus_chess/domain/logging"
+)
+
+const (
+	// DefaultExploration is the exploration constant in hundredths, the
+	// unit of the Exploration option, and MaxExploration its bound.
+	DefaultExploration = 140
+	MaxExploration     = 1000
+
+	// defaultPlayouts is the number of playouts of a search that the
+	// limits do not stop otherwise.
+	defaultPlayouts = 20000
+	// mctsReportInterval is the number of playouts between two reports,
+	// each of which the time manager treats as an iteration.
+	mctsReportInterval = 1024
+	// DefaultMCTSHashSize is the default size of the tree in megabytes,
+	// larger than the transposition table's since the tree is the only
+	// memory of the search. Once it is reached, leaves are evaluated
+	// without being expanded.
+	DefaultMCTSHashSize = 384
+	// mctsNodeSize is the memory a node takes in bytes, with its slot in
+	// its parent's children, rounded up.
+	mctsNodeSize = 192
+	// randomPlayoutPlies is the length of a random playout, after which
+	// the position reached is evaluated.
+	randomPlayoutPlies = 8
+	// winRateScale is the score, in centipawns, at which the odds of
+	// winning are ten to one.
+	winRateScale = 400
+	// priorTemperature spreads the priors of PUCT: a move evaluated this
+	// many centipawns better than another is e times as likely to be tried.
+	priorTemperature = 100
+)
+
+// mctsNode is the position reached by move from its parent. wins sums the
+// results of the playouts through the node for the side that played move:
+// one for a win, a half for a draw.
+type mctsNode struct {
+	move     board.Move
+	key      uint64
+	parent   *mctsNode
+	children []*mctsNode
+	expanded bool
+	// terminal is set once the node is found to end the game, with result
+	// the outcome for the side that played move
+	terminal bool
+	result   float64
+	// prior is the share of its parent's visits PUCT expects the node to
+	// receive
+	prior  float64
+	visits int
+	wins   float64
+}
+
+func (n *mctsNode) winRate() float64 {
+	if n.visits == 0 {
+		return 0.5
+	}
+	return n.wins / float64(n.visits)
+}
+
+// mostVisited returns the child to play from n, the most visited one with
+// the best win rate among equals, or nil before any child is visited.
+func (n *mctsNode) mostVisited() *mctsNode {
+	var best *mctsNode
+	for _, child := range n.children {
+		if child.visits == 0 {
+			continue
+		}
+		if best == nil || child.visits > best.visits || child.visits == best.visits && child.winRate() > best.winRate() {
+			best = child
+		}
+	}
+	return best
+}
+
+// MCTSMoveFinder chooses moves by Monte Carlo tree search. Every playout
+// descends the tree from the root, choosing at each node the child with
+// the best balance of win rate and exploration (UCT, or PUCT, which
+// weighs exploration by a prior from the evaluation of each move). The
+// leaf reached is expanded and valued, by the evaluation directly or
+// after random moves, and its result is added to every node on the path.
+// The most visited move is played, and the subtree of the position that
+// follows is kept for the next search.
+//
+// Its reports map the search onto UCI info lines: nodes counts playouts,
+// depth is the length of the most visited line and the score is the win
+// rate of its first move converted to centipawns. Each report also lists
+// the visits and win rate of every root move.
+type MCTSMoveFinder struct {
+	logger      *logging.Logger
+	onInfo      InfoCallback
+	timeManager *TimeManager
+	// exploration is the exploration constant in hundredths
+	exploration int
+	puct        bool
+	// randomPlayouts values leaves after random moves rather than by
+	// their evaluation, drawn from rng, which is seeded with seed on every
+	// new game like the RandomMoveFinder's
+	randomPlayouts bool
+	seed           int64
+	rng            *rand.Rand
+
+	root     *mctsNode
+	treeSize int
+	// maxTreeNodes bounds treeSize, from the Hash option
+	maxTreeNodes int
+
+	ctx         context.Context
+	limits      SearchLimits
+	pondering   bool
+	clockStart  time.Time
+	deadline    time.Time
+	hasDeadline bool
+	playouts    int
+	selDepth    int
+}
+
+func (f *MCTSMoveFinder) SetInfoCallback(callback InfoCallback) {
+	f.onInfo = callback
+}
+
+func (f *MCTSMoveFinder) FindBestMove(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) (*SearchResult, error) {
+	start := time.Now()
+	if len(chessBoard.GenerateLegalMoves()) == 0 {
+		return nil, fmt.Errorf("no legal moves available")
+	}
+	f.ctx = ctx
+	f.limits = limits
+	f.playouts = 0
+	f.selDepth = 0
+	// A pondering search has no deadline until the ponderhit, whatever
+	// the previous search's was
+	f.hasDeadline = false
+	f.reuseTree(chessBoard)
+	if len(f.root.children) == 0 {
+		f.root = nil
+		return nil, fmt.Errorf("none of the search moves is legal")
+	}
+	f.timeManager.Start(chessBoard, limits)
+	f.pondering = limits.Ponder
+	if !f.pondering {
+		f.startClock(start)
+	}
+
+	var info SearchInfo
+	for {
+		f.playout(chessBoard)
+		if f.playouts%mctsReportInterval == 0 {
+			info = f.report(start)
+			f.timeManager.Update(info.PV[0], info.Score)
+			f.checkPonderHit()
+			if !f.pondering && f.timeManager.ShouldStop(time.Since(f.clockStart)) {
+				break
+			}
+		}
+		if f.shouldStop() {
+			break
+		}
+	}
+	if f.playouts%mctsReportInterval != 0 {
+		info = f.report(start)
+	}
+	move := info.PV[0]
+	f.logger.Debug(fmt.Sprintf("mcts move selected: %s from file %d, rank %d to file %d, rank %d, score %d, playouts %d", move.Piece.Name, move.From.File, move.From.Rank, move.To.File, move.To.Rank, info.Score, info.Nodes))
+
+	if len(limits.SearchMoves) > 0 {
+		// The root lacks the moves left out, so the tree is not kept
+		f.root = nil
+	}
+
+	waitForGUI(ctx, limits)
+	return resultFromPV(info.PV, info.Score), nil
+}
+
+// reuseTree makes the root the node of chessBoard's position when the tree
+// of the previous search reaches it within two plies, the move played and
+// the reply, and starts a new tree otherwise.
+func (f *MCTSMoveFinder) reuseTree(chessBoard board.ChessBoard) {
+	key := chessBoard.Hash()
+	root := findNode(f.root, key, 2)
+	if root == nil || root.terminal {
+		// A node ends the game only as a repetition of the path to it
+		root = &mctsNode{key: key}
+	}
+	root.parent = nil
+	root.move = board.Move{}
+	f.root = root
+	f.treeSize = countNodes(root)
+
+	if !root.expanded {
+		f.expand(chessBoard, root)
+	}
+	if len(f.limits.SearchMoves) > 0 {
+		root.children = slices.DeleteFunc(root.children, func(child *mctsNode) bool {
+			return !isSearchMove(child.move, f.limits)
+		})
+	}
+}
+
+// findNode returns the node of key within depth plies of node, if any.
+func findNode(node *mctsNode, key uint64, depth int) *mctsNode {
+	if node == nil {
+		return nil
+	}
+	if node.key == key {
+		return node
+	}
+	if depth == 0 {
+		return nil
+	}
+	for _, child := range node.children {
+		if found := findNode(child, key, depth-1); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func countNodes(node *mctsNode) int {
+	count := 1
+	for _, child := range node.children {
+		count += countNodes(child)
+	}
+	return count
+}
+
+// playout descends from the root to a leaf, values it and adds the result
+// to the nodes on the path.
+func (f *MCTSMoveFinder) playout(chessBoard board.ChessBoard) {
+	node := f.root
+	depth := 0
+	for node.expanded && !node.terminal {
+		node = f.selectChild(node)
+		chessBoard.MakeMove(node.move)
+		depth++
+	}
+	f.selDepth = max(f.selDepth, depth)
+
+	result := node.result
+	if !node.terminal {
+		if f.treeSize < f.maxTreeNodes {
+			f.expand(chessBoard, node)
+		}
+		if node.terminal {
+			result = node.result
+		} else {
+			result = 1 - f.value(chessBoard)
+		}
+	}
+	for ; node != nil; node = node.parent {
+		node.visits++
+		node.wins += result
+		result = 1 - result
+	}
+	for ; depth > 0; depth-- {
+		chessBoard.UndoMove()
+	}
+	f.playouts++
+}
+
+// selectChild returns the child of node that the playout descends to.
+func (f *MCTSMoveFinder) selectChild(node *mctsNode) *mctsNode {
+	c := float64(f.exploration) / 100
+	var best *mctsNode
+	bestValue := math.Inf(-1)
+	for _, child := range node.children {
+		var value float64
+		switch {
+		case f.puct:
+			value = child.winRate() + c*child.prior*math.Sqrt(float64(max(node.visits, 1)))/float64(1+child.visits)
+		case child.visits == 0:
+			value = math.Inf(1)
+		default:
+			value = child.winRate() + c*math.Sqrt(math.Log(float64(node.visits))/float64(child.visits))
+		}
+		if value > bestValue {
+			best, bestValue = child, value
+		}
+	}
+	return best
+}
+
+// expand adds the children of node, whose position is on chessBoard, or
+// marks it terminal when the game is over. The children's priors are a
+// softmax of the evaluations of their positions.
+func (f *MCTSMoveFinder) expand(chessBoard board.ChessBoard, node *mctsNode) {
+	node.expanded = true
+	moves := chessBoard.GenerateLegalMoves()
+	switch {
+	case len(moves) == 0:
+		node.terminal = true
+		node.result = 0.5
+		if chessBoard.InCheck(chessBoard.SideToMove()) {
+			node.result = 1
+		}
+		return
+	case node.parent != nil && isDraw(chessBoard):
+		node.terminal = true
+		node.result = 0.5
+		return
+	}
+
+	node.children = make([]*mctsNode, len(moves))
+	logits := make([]float64, len(moves))
+	maxLogit := math.Inf(-1)
+	for i, move := range moves {
+		chessBoard.MakeMove(move)
+		node.children[i] = &mctsNode{move: move, key: chessBoard.Hash(), parent: node}
+		logits[i] = -float64(evaluation.Evaluate(chessBoard)) / priorTemperature
+		chessBoard.UndoMove()
+		maxLogit = max(maxLogit, logits[i])
+	}
+	sum := 0.0
+	for i := range logits {
+		logits[i] = math.Exp(logits[i] - maxLogit)
+		sum += logits[i]
+	}
+	for i, child := range node.children {
+		child.prior = logits[i] / sum
+	}
+	f.treeSize += len(moves)
+}
+
+// value estimates the win rate of the side to move on chessBoard, from its
+// evaluation or, with random playouts, that of the position reached after
+// random moves.
+func (f *MCTSMoveFinder) value(chessBoard board.ChessBoard) float64 {
+	if !f.randomPlayouts {
+		return winRate(evaluation.Evaluate(chessBoard))
+	}
+
+	plies := 0
+	result := -1.0
+	for ; plies < randomPlayoutPlies; plies++ {
+		moves := chessBoard.GenerateLegalMoves()
+		if len(moves) == 0 {
+			result = 0.5
+			if chessBoard.InCheck(chessBoard.SideToMove()) {
+				result = 0
+			}
+			break
+		}
+		if isDraw(chessBoard) {
+			result = 0.5
+			break
+		}
+		chessBoard.MakeMove(moves[f.rng.Intn(len(moves))])
+	}
+	if result < 0 {
+		result = winRate(evaluation.Evaluate(chessBoard))
+	}
+	for i := 0; i < plies; i++ {
+		chessBoard.UndoMove()
+	}
+	if plies%2 == 1 {
+		result = 1 - result
+	}
+	return result
+}
+
+// winRate converts a score in centipawns into the expected result.
+func winRate(score int) float64 {
+	return 1 / (1 + math.Pow(10, -float64(score)/winRateScale))
+}
+
+// scoreFromWinRate converts an expected result into centipawns, the
+// inverse of winRate.
+func scoreFromWinRate(rate float64) int {
+	rate = min(max(rate, 0.001), 0.999)
+	return int(math.Round(winRateScale * math.Log10(rate/(1-rate))))
+}
+
+// report describes the search so far and passes it to the info callback.
+func (f *MCTSMoveFinder) report(start time.Time) SearchInfo {
+	var pv []board.Move
+	for node := f.root.mostVisited(); node != nil; node = node.mostVisited() {
+		pv = append(pv, node.move)
+	}
+	best := f.root.mostVisited()
+	score := scoreFromWinRate(best.winRate())
+	if best.terminal && best.result == 1 {
+		score = MateScore - 1
+	}
+	info := SearchInfo{
+		Depth:     len(pv),
+		SelDepth:  f.selDepth,
+		MultiPV:   1,
+		Score:     score,
+		Nodes:     f.playouts,
+		Time:      time.Since(start),
+		PV:        pv,
+		RootMoves: f.rootMoves(),
+	}
+	if f.onInfo != nil {
+		f.onInfo(info)
+	}
+	return info
+}
+
+// rootMoves describes the root's children, most visited first.
+func (f *MCTSMoveFinder) rootMoves() []RootMoveInfo {
+	moves := make([]RootMoveInfo, len(f.root.children))
+	for i, child := range f.root.children {
+		moves[i] = RootMoveInfo{Move: child.move, Visits: child.visits, WinRate: child.winRate()}
+	}
+	slices.SortStableFunc(moves, func(a, b RootMoveInfo) int {
+		return b.Visits - a.Visits
+	})
+	return moves
+}
+
+// shouldStop reports whether the limits end the search after a playout.
+func (f *MCTSMoveFinder) shouldStop() bool {
+	if f.ctx.Err() != nil || f.hasDeadline && time.Now().After(f.deadline) {
+		return true
+	}
+	switch {
+	case f.limits.Nodes > 0:
+		return f.playouts >= f.limits.Nodes
+	case f.limits.Infinite || f.pondering || f.timeManager.Active():
+		return false
+	default:
+		return f.playouts >= defaultPlayouts
+	}
+}
+
+// startClock starts timing the search at now.
+func (f *MCTSMoveFinder) startClock(now time.Time) {
+	hardLimit, timed := f.timeManager.HardLimit()
+	f.clockStart = now
+	f.hasDeadline = timed
+	f.deadline = now.Add(hardLimit)
+}
+
+// checkPonderHit starts the clock of a pondering search once the opponent
+// has played the expected reply.
+func (f *MCTSMoveFinder) checkPonderHit() {
+	if !f.pondering {
+		return
+	}
+	select {
+	case <-f.limits.PonderHit:
+		f.pondering = false
+		f.startClock(time.Now())
+	default:
+	}
+}
+
+func (f *MCTSMoveFinder) Options() []Option {
+	return []Option{
+		{Name: "Hash", Type: SpinOption, Default: strconv.Itoa(DefaultMCTSHashSize), Min: 1, Max: MaxHashSize},
+		{Name: "Move Overhead", Type: SpinOption, Default: strconv.Itoa(int(DefaultMoveOverhead.Milliseconds())), Min: 0, Max: int(MaxMoveOverhead.Milliseconds())},
+		{Name: "Exploration", Type: SpinOption, Default: strconv.Itoa(DefaultExploration), Min: 0, Max: MaxExploration},
+		{Name: "PUCT", Type: CheckOption, Default: "true"},
+		{Name: "Random Playouts", Type: CheckOption, Default: "false"},
+		{Name: "Seed", Type: SpinOption, Default: strconv.FormatInt(f.seed, 10), Min: 0, Max: math.MaxInt32},
+	}
+}
+
+func (f *MCTSMoveFinder) SetOption(name, value string) error {
+	option, err := findOption(f.Options(), name)
+	if err != nil {
+		return err
+	}
+	switch option.Name {
+	case "Hash":
+		sizeMB, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.setTreeSize(sizeMB)
+	case "Move Overhead":
+		milliseconds, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.timeManager.SetMoveOverhead(time.Duration(milliseconds) * time.Millisecond)
+	case "Exploration":
+		exploration, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.exploration = exploration
+	case "PUCT":
+		enabled, err := parseCheck(option, value)
+		if err != nil {
+			return err
+		}
+		f.puct = enabled
+	case "Random Playouts":
+		enabled, err := parseCheck(option, value)
+		if err != nil {
+			return err
+		}
+		f.randomPlayouts = enabled
+	case "Seed":
+		seed, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		f.SetSeed(int64(seed))
+	}
+	return nil
+}
+
+// setTreeSize bounds the tree to sizeMB megabytes. A tree already larger
+// keeps its nodes but grows no more.
+func (f *MCTSMoveFinder) setTreeSize(sizeMB int) {
+	f.maxTreeNodes = sizeMB << 20 / mctsNodeSize
+}
+
+// SetSeed restarts the random playouts from seed, or from a seed drawn
+// from the clock if seed is zero.
+func (f *MCTSMoveFinder) SetSeed(seed int64) {
+	f.seed = seed
+	f.rng = newSeededRand(f.logger, "mcts move finder", seed)
+}
+
+// NewGame discards the tree, which no position of the new game is likely
+// to be found in, and restarts the random playouts from the seed.
+func (f *MCTSMoveFinder) NewGame() {
+	f.root = nil
+	f.SetSeed(f.seed)
+}
+
+// NewMCTSMoveFinder returns a finder whose random playouts are drawn from
+// seed, as NewRandomMoveFinder's moves are.
+func NewMCTSMoveFinder(logger *logging.Logger, seed int64) *MCTSMoveFinder {
+	f := &MCTSMoveFinder{
+		logger:      logger,
+		timeManager: NewTimeManager(DefaultMoveOverhead),
+		exploration: DefaultExploration,
+		puct:        true,
+	}
+	f.setTreeSize(DefaultMCTSHashSize)
+	f.SetSeed(seed)
+	return f
+}
@@ -0,0 +1,214 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+)
+
+func newTestMCTSMoveFinder(t testing.TB) *MCTSMoveFinder {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	return NewMCTSMoveFinder(logger, 1)
+}
+
+func TestMCTSFindsTactics(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+	}{
+		{"back rank mate", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8"},
+		{"hanging queen", "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1", "d2d5"},
+	}
+	configurations := map[string]map[string]string{
+		"puct":            {},
+		"uct":             {"PUCT": "false"},
+		"random playouts": {"Random Playouts": "true"},
+	}
+
+	for configuration, options := range configurations {
+		for _, test := range tests {
+			t.Run(configuration+"/"+test.name, func(t *testing.T) {
+				finder := newTestMCTSMoveFinder(t)
+				for name, value := range options {
+					if err := finder.SetOption(name, value); err != nil {
+						t.Fatalf("failed to set option: %v", err)
+					}
+				}
+				result, err := finder.FindBestMove(context.Background(), newTestBoard(t, test.fen), SearchLimits{Nodes: 5000})
+				if err != nil {
+					t.Fatalf("search failed: %v", err)
+				}
+				if move := squareString(result.BestMove.From) + squareString(result.BestMove.To); move != test.move {
+					t.Errorf("expected %s, got %s with score %d", test.move, move, result.Score)
+				}
+			})
+		}
+	}
+}
+
+func TestMCTSReportsPlayouts(t *testing.T) {
+	finder := newTestMCTSMoveFinder(t)
+	var infos []SearchInfo
+	finder.SetInfoCallback(func(info SearchInfo) {
+		infos = append(infos, info)
+	})
+	result, err := finder.FindBestMove(context.Background(), newTestBoard(t, "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1"), SearchLimits{Nodes: 3000})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	// One report every mctsReportInterval playouts and one at the end
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 reports, got %d", len(infos))
+	}
+	last := infos[len(infos)-1]
+	if last.Nodes != 3000 || last.Depth != len(last.PV) || !last.PV[0].Equal(result.BestMove) {
+		t.Errorf("expected the last report to describe the search, got %+v", last)
+	}
+	if last.Score != MateScore-1 {
+		t.Errorf("expected a mate score for a mating move, got %d", last.Score)
+	}
+	if len(last.RootMoves) != len(finder.root.children) || !last.RootMoves[0].Move.Equal(result.BestMove) {
+		t.Fatalf("expected every root move, the best first, got %+v", last.RootMoves)
+	}
+	visits := 0
+	for i, rootMove := range last.RootMoves {
+		visits += rootMove.Visits
+		if i > 0 && rootMove.Visits > last.RootMoves[i-1].Visits {
+			t.Errorf("expected root moves by visits, got %+v", last.RootMoves)
+		}
+		if rootMove.WinRate < 0 || rootMove.WinRate > 1 {
+			t.Errorf("expected a win rate between 0 and 1, got %+v", rootMove)
+		}
+	}
+	if visits > last.Nodes {
+		t.Errorf("expected at most %d visits to the root moves, got %d", last.Nodes, visits)
+	}
+}
+
+func TestMCTSReusesTheTree(t *testing.T) {
+	finder := newTestMCTSMoveFinder(t)
+	cb := newTestBoard(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Nodes: 4000})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if result.PonderMove == nil {
+		t.Fatalf("expected a reply in the most visited line")
+	}
+	cb.MakeMove(result.BestMove)
+	cb.MakeMove(*result.PonderMove)
+
+	finder.reuseTree(cb)
+	if finder.root.visits == 0 || finder.root.key != cb.Hash() {
+		t.Errorf("expected the searched node of the position as the root, got %d visits", finder.root.visits)
+	}
+
+	finder.NewGame()
+	finder.reuseTree(cb)
+	if finder.root.visits != 0 {
+		t.Errorf("expected a new tree after a new game, got %d visits", finder.root.visits)
+	}
+}
+
+func TestMCTSRandomPlayoutsAreReproducible(t *testing.T) {
+	visits := func(finder *MCTSMoveFinder) []int {
+		if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"), SearchLimits{Nodes: 2000}); err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		var visits []int
+		for _, child := range finder.root.children {
+			visits = append(visits, child.visits)
+		}
+		finder.NewGame()
+		return visits
+	}
+	finder := newTestMCTSMoveFinder(t)
+	if err := finder.SetOption("Random Playouts", "true"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	first := visits(finder)
+	if again := visits(finder); !slices.Equal(first, again) {
+		t.Errorf("expected a new game to replay the search from the seed")
+	}
+	if err := finder.SetOption("Seed", "2"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if other := visits(finder); slices.Equal(first, other) {
+		t.Errorf("expected another seed to search differently")
+	}
+}
+
+func TestMCTSPonderIgnoresThePreviousDeadline(t *testing.T) {
+	finder := newTestMCTSMoveFinder(t)
+	const fen = "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"
+	if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, fen), SearchLimits{MoveTime: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	// The ponderhit never comes; the GUI stops the search instead
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	limits := SearchLimits{Ponder: true, PonderHit: make(chan struct{}), MoveTime: 20 * time.Millisecond}
+	if _, err := finder.FindBestMove(ctx, newTestBoard(t, fen), limits); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if finder.playouts < mctsReportInterval {
+		t.Errorf("expected the ponder to search until stopped, got %d playouts", finder.playouts)
+	}
+}
+
+func TestMCTSTreeSizeFollowsHash(t *testing.T) {
+	finder := newTestMCTSMoveFinder(t)
+	if err := finder.SetOption("Hash", "1"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if finder.maxTreeNodes != 1<<20/mctsNodeSize {
+		t.Fatalf("expected a megabyte of nodes, got %d", finder.maxTreeNodes)
+	}
+	if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"), SearchLimits{Nodes: 5000}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	// The last expansion may add a position's moves beyond the bound
+	if finder.treeSize < finder.maxTreeNodes || finder.treeSize > finder.maxTreeNodes+board.MaxMoves {
+		t.Errorf("expected the tree to stop growing at %d nodes, got %d", finder.maxTreeNodes, finder.treeSize)
+	}
+}
+
+func TestWinRate(t *testing.T) {
+	if rate := winRate(0); rate != 0.5 {
+		t.Errorf("expected an even score to be even odds, got %f", rate)
+	}
+	if rate := winRate(winRateScale); rate < 0.9 || rate > 0.91 {
+		t.Errorf("expected ten to one odds at %d, got %f", winRateScale, rate)
+	}
+	for _, score := range []int{-700, -150, 0, 35, 400} {
+		if got := scoreFromWinRate(winRate(score)); got != score {
+			t.Errorf("expected %d back, got %d", score, got)
+		}
+	}
+}
+
+func TestMCTSOptions(t *testing.T) {
+	finder := newTestMCTSMoveFinder(t)
+	if err := finder.SetOption("exploration", "250"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if err := finder.SetOption("PUCT", "false"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if finder.exploration != 250 || finder.puct {
+		t.Errorf("expected the options applied, got exploration %d and puct %v", finder.exploration, finder.puct)
+	}
+	if err := finder.SetOption("Exploration", "5000"); err == nil {
+		t.Errorf("expected an out of range value to be rejected")
+	}
+}
@@ -0,0 +1,314 @@
+package search
+
+import (
+	board "jesus_chess/domain/board"
+)
+
+type pickerStage int
+
+const (
+	stageHashMove pickerStage = iota
+	stageGenerateCaptures
+	stageCaptures
+	stageGenerateQuiets
+	stageRefutations
+	stageQuiets
+	stageBadCaptures
+	stageGenerateEvasions
+	stageEvasions
+	stageDone
+)
+
+// captureScoreOffset lifts captures above every history score among the
+// evasions.
+const captureScoreOffset = 1 << 20
+
+// MovePicker hands out the legal moves of a position one at a time in the
+// order search wants to try them: the hash move, captures that do not lose
+// material by MVV-LVA, the killer moves and the countermove, the remaining
+// quiet moves by history and finally the losing captures. Each group is
+// only generated once the previous one is exhausted, so a cutoff on an
+// early move skips the rest of the generation. In check, the hash move is
+// followed by the check evasions instead, captures first.
+//
+// A picker is meant to be reused: keep one per ply and call Init at each
+// node.
+type MovePicker struct {
+	board       board.ChessBoard
+	ordering    Ordering
+	quiescence  bool
+	hashMove    board.Move
+	hasHashMove bool
+	// refutations holds the killers and the countermove
+	refutations     [3]board.Move
+	refutationCount int
+	refutationIndex int
+	stage           pickerStage
+	moves           board.MoveList
+	scores          [board.MaxMoves]int
+	index           int
+	badCaptures     board.MoveList
+	badIndex        int
+}
+
+// Init prepares the picker for a new node. hashMove may be nil. Killers
+// and countermoves that are not legal quiet moves in the position are
+// ignored.
+func (mp *MovePicker) Init(chessBoard board.ChessBoard, hashMove *board.Move, ordering Ordering) {
+	mp.board = chessBoard
+	mp.ordering = ordering
+	mp.quiescence = false
+	mp.hasHashMove = hashMove != nil
+	if hashMove != nil {
+		mp.hashMove = *hashMove
+	}
+	mp.refutationCount = 0
+	mp.refutationIndex = 0
+	if ordering.History != nil {
+		for _, killer := range ordering.History.Killers(ordering.Ply) {
+			mp.addRefutation(killer)
+		}
+		if counter, ok := ordering.History.CounterMove(ordering.Previous[0]); ok {
+			mp.addRefutation(counter)
+		}
+	}
+	mp.stage = stageHashMove
+	mp.moves.Clear()
+	mp.badCaptures.Clear()
+	mp.index = 0
+	mp.badIndex = 0
+}
+
+// InitQuiescence prepares the picker for a quiescence node, where only
+// captures and promotions are searched, or every evasion when in check.
+// Losing captures are not deferred, as quiescence prunes them itself.
+func (mp *MovePicker) InitQuiescence(chessBoard board.ChessBoard) {
+	mp.Init(chessBoard, nil, Ordering{})
+	mp.quiescence = true
+}
+
+func (mp *MovePicker) addRefutation(move board.Move) {
+	if move.From == move.To {
+		return
+	}
+	for _, refutation := range mp.refutations[:mp.refutationCount] {
+		if refutation.Equal(move) {
+			return
+		}
+	}
+	mp.refutations[mp.refutationCount] = move
+	mp.refutationCount++
+}
+
+// Next returns the next move to search, or false once every legal move has
+// been returned.
+func (mp *MovePicker) Next() (board.Move, bool) {
+	for {
+		switch mp.stage {
+		case stageHashMove:
+			if mp.board.InCheck(mp.board.SideToMove()) {
+				mp.stage = stageGenerateEvasions
+			} else {
+				mp.stage = stageGenerateCaptures
+			}
+			if mp.hasHashMove {
+				// Hand out the move as the board completes it, which unlike
+				// a move from the transposition table knows its piece and
+				// capture, without generating the other moves
+				if move, ok := mp.board.LegalMove(mp.hashMove); ok {
+					mp.hashMove = move
+					return move, true
+				}
+				mp.hasHashMove = false
+			}
+
+		case stageGenerateCaptures:
+			mp.board.GenerateCaptures(&mp.moves)
+			mp.scoreCaptures()
+			mp.stage = stageCaptures
+
+		case stageCaptures:
+			if move, ok := mp.pickBest(); ok {
+				if mp.isHashMove(move) {
+					continue
+				}
+				if !mp.quiescence && move.Promotion == nil && SEE(mp.board, move) < 0 {
+					mp.badCaptures.Add(move)
+					continue
+				}
+				return move, true
+			}
+			if mp.quiescence {
+				mp.stage = stageDone
+			} else {
+				mp.stage = stageGenerateQuiets
+			}
+
+		case stageGenerateQuiets:
+			mp.board.GenerateQuiets(&mp.moves)
+			mp.stage = stageRefutations
+
+		case stageRefutations:
+			for mp.refutationIndex < mp.refutationCount {
+				refutation := mp.refutations[mp.refutationIndex]
+				mp.refutationIndex++
+				if mp.isHashMove(refutation) {
+					continue
+				}
+				if move, ok := mp.findGenerated(refutation); ok {
+					return move, true
+				}
+			}
+			mp.scoreQuiets()
+			mp.stage = stageQuiets
+
+		case stageQuiets:
+			if move, ok := mp.pickBest(); ok {
+				if !mp.isHashMove(move) && !mp.isRefutation(move) {
+					return move, true
+				}
+				continue
+			}
+			mp.stage = stageBadCaptures
+
+		case stageBadCaptures:
+			if mp.badIndex < mp.badCaptures.Len() {
+				move := mp.badCaptures.At(mp.badIndex)
+				mp.badIndex++
+				return move, true
+			}
+			mp.stage = stageDone
+
+		case stageGenerateEvasions:
+			mp.board.GenerateEvasions(&mp.moves)
+			mp.scoreEvasions()
+			mp.stage = stageEvasions
+
+		case stageEvasions:
+			if move, ok := mp.pickBest(); ok {
+				if !mp.isHashMove(move) {
+					return move, true
+				}
+				continue
+			}
+			mp.stage = stageDone
+
+		case stageDone:
+			return board.Move{}, false
+		}
+	}
+}
+
+// scoreCaptures gives every generated capture its MVV-LVA score and resets
+// the selection index.
+func (mp *MovePicker) scoreCaptures() {
+	for i := 0; i < mp.moves.Len(); i++ {
+		mp.scores[i] = mvvLva(mp.moves.At(i))
+	}
+	mp.index = 0
+}
+
+// scoreQuiets gives every generated quiet move its history score and
+// resets the selection index.
+func (mp *MovePicker) scoreQuiets() {
+	for i := 0; i < mp.moves.Len(); i++ {
+		mp.scores[i] = mp.quietScore(mp.moves.At(i))
+	}
+	mp.index = 0
+}
+
+// scoreEvasions orders captures of the checker first, then the other
+// evasions by history.
+func (mp *MovePicker) scoreEvasions() {
+	for i := 0; i < mp.moves.Len(); i++ {
+		move := mp.moves.At(i)
+		if move.CapturedPiece != nil || move.Promotion != nil {
+			mp.scores[i] = captureScoreOffset + mvvLva(move)
+		} else {
+			mp.scores[i] = mp.quietScore(move)
+		}
+	}
+	mp.index = 0
+}
+
+func (mp *MovePicker) quietScore(move board.Move) int {
+	if mp.ordering.History == nil {
+		return 0
+	}
+	return mp.ordering.History.QuietScore(move, mp.ordering)
+}
+
+// pickBest moves the best-scoring remaining move to the front of the
+// unpicked range and returns it. Selecting lazily is cheaper than sorting
+// when a cutoff comes early.
+func (mp *MovePicker) pickBest() (board.Move, bool) {
+	if mp.index >= mp.moves.Len() {
+		return board.Move{}, false
+	}
+	best := mp.index
+	for i := mp.index + 1; i < mp.moves.Len(); i++ {
+		if mp.scores[i] > mp.scores[best] {
+			best = i
+		}
+	}
+	mp.moves.Swap(mp.index, best)
+	mp.scores[mp.index], mp.scores[best] = mp.scores[best], mp.scores[mp.index]
+	move := mp.moves.At(mp.index)
+	mp.index++
+	return move, true
+}
+
+func (mp *MovePicker) isHashMove(move board.Move) bool {
+	return mp.hasHashMove && mp.hashMove.Equal(move)
+}
+
+func (mp *MovePicker) isRefutation(move board.Move) bool {
+	for _, refutation := range mp.refutations[:mp.refutationCount] {
+		if refutation.Equal(move) {
+			return true
+		}
+	}
+	return false
+}
+
+// findGenerated returns the generated move equal to move, if any.
+func (mp *MovePicker) findGenerated(move board.Move) (board.Move, bool) {
+	for _, generated := range mp.moves.Moves() {
+		if generated.Equal(move) {
+			return generated, true
+		}
+	}
+	return board.Move{}, false
+}
+
+// mvvLva scores captures by most valuable victim, then least valuable
+// attacker. Promotions count the promoted piece as an extra victim. Quiet
+// moves score zero.
+func mvvLva(move board.Move) int {
+	score := 0
+	if move.CapturedPiece != nil {
+		score += 10*pieceOrderValue(move.CapturedPiece.Name) - pieceOrderValue(move.Piece.Name) + 10
+	}
+	if move.Promotion != nil {
+		score += 10 * pieceOrderValue(move.Promotion.Name)
+	}
+	return score
+}
+
+// pieceOrderValue ranks the pieces for move ordering purposes.
+func pieceOrderValue(name board.PieceName) int {
+	switch name {
+	case board.Pawn:
+		return 1
+	case board.Knight:
+		return 2
+	case board.Bishop:
+		return 3
+	case board.Rook:
+		return 4
+	case board.Queen:
+		return 5
+	default:
+		return 6
+	}
+}
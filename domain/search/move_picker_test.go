@@ -0,0 +1,213 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"testing"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+)
+
+func newTestBoard(t testing.TB, fen string) *board.ArrayChessBoard {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	cb := board.NewArrayChessBoard(logger)
+	if err := cb.SetPosition(fen); err != nil {
+		t.Fatalf("failed to set position %s: %v", fen, err)
+	}
+	return cb
+}
+
+func TestMovePickerYieldsEveryLegalMoveOnce(t *testing.T) {
+	positions := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbqkbnr/ppp2ppp/8/1B1pp3/4P3/8/PPPP1PPP/RNBQK1NR b KQkq - 1 3",
+	}
+	for _, fen := range positions {
+		cb := newTestBoard(t, fen)
+		legal := cb.GenerateLegalMoves()
+		hashMove := legal[len(legal)-1]
+		history := NewHistory()
+		history.killers[0] = [2]board.Move{legal[0], {From: board.Square{Rank: 3, File: 3}, To: board.Square{Rank: 4, File: 4}}}
+
+		var picker MovePicker
+		picker.Init(cb, &hashMove, Ordering{History: history})
+		seen := make(map[board.Move]int)
+		first := true
+		inQuiets := false
+		for {
+			move, ok := picker.Next()
+			if !ok {
+				break
+			}
+			if first && !move.Equal(hashMove) {
+				t.Errorf("%s: expected hash move first, got %v", fen, move)
+			}
+			first = false
+			tactical := move.CapturedPiece != nil || move.Promotion != nil
+			if !move.Equal(hashMove) && tactical && inQuiets && (move.Promotion != nil || SEE(cb, move) >= 0) {
+				t.Errorf("%s: capture %v returned after quiet moves", fen, move)
+			}
+			if !tactical && !move.Equal(hashMove) {
+				inQuiets = true
+			}
+			seen[board.Move{From: move.From, To: move.To, Promotion: move.Promotion}]++
+		}
+
+		if len(seen) != len(legal) {
+			t.Errorf("%s: expected %d distinct moves, got %d", fen, len(legal), len(seen))
+		}
+		for _, move := range legal {
+			if count := seen[board.Move{From: move.From, To: move.To, Promotion: move.Promotion}]; count != 1 {
+				t.Errorf("%s: move %v returned %d times", fen, move, count)
+			}
+		}
+	}
+}
+
+func TestMovePickerOrdersCapturesByVictimValue(t *testing.T) {
+	// The knight on d5 can take the queen on c7 or the pawn on f6
+	cb := newTestBoard(t, "k7/2q5/5p2/3N4/8/8/8/4K3 w - - 0 1")
+	var picker MovePicker
+	picker.Init(cb, nil, Ordering{})
+
+	first, _ := picker.Next()
+	if first.CapturedPiece == nil || first.CapturedPiece.Name != board.Queen {
+		t.Fatalf("expected the queen capture first, got %v", first)
+	}
+	second, _ := picker.Next()
+	if second.CapturedPiece == nil || second.CapturedPiece.Name != board.Pawn {
+		t.Fatalf("expected the pawn capture second, got %v", second)
+	}
+}
+
+func TestMovePickerDefersLosingCaptures(t *testing.T) {
+	// Nxe7 loses the knight to the king, so it is tried after the quiets
+	cb := newTestBoard(t, "4k3/4p3/8/3N4/8/8/8/4K3 w - - 0 1")
+	var picker MovePicker
+	picker.Init(cb, nil, Ordering{})
+
+	var last board.Move
+	count := 0
+	for move, ok := picker.Next(); ok; move, ok = picker.Next() {
+		last = move
+		count++
+	}
+	if count != len(cb.GenerateLegalMoves()) {
+		t.Errorf("expected %d moves, got %d", len(cb.GenerateLegalMoves()), count)
+	}
+	if last.CapturedPiece == nil || last.CapturedPiece.Name != board.Pawn {
+		t.Errorf("expected the losing capture last, got %v", last)
+	}
+}
+
+func TestMovePickerOrdersQuietsByHistory(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+	rookLift := findMove(t, cb, "a1a5")
+	kingMove := findMove(t, cb, "e1d2")
+	counter := findMove(t, cb, "a1a8")
+	previous := board.Move{Piece: board.Piece{Name: board.King, Color: board.Black}, From: board.Square{Rank: 7, File: 3}, To: board.Square{Rank: 7, File: 4}}
+
+	history := NewHistory()
+	ordering := Ordering{History: history, Ply: 3, Previous: [2]*board.Move{&previous}}
+	history.UpdateQuiets(rookLift, nil, 8, Ordering{History: history, Ply: 3})
+	history.UpdateQuiets(kingMove, []board.Move{rookLift}, 2, Ordering{History: history, Ply: 5})
+	history.UpdateQuiets(counter, nil, 1, ordering)
+
+	var picker MovePicker
+	picker.Init(cb, nil, ordering)
+	var order []board.Move
+	for move, ok := picker.Next(); ok; move, ok = picker.Next() {
+		order = append(order, move)
+	}
+
+	// Killers of ply 3 come first, newest first, then the countermove,
+	// which is also a killer here, then the quiets by history
+	if !order[0].Equal(counter) || !order[1].Equal(rookLift) {
+		t.Fatalf("expected the killers first, got %v and %v", order[0], order[1])
+	}
+	if !order[2].Equal(kingMove) {
+		t.Errorf("expected the move with the best history next, got %v", order[2])
+	}
+}
+
+func TestHistoryGravityBoundsScores(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1")
+	move := findMove(t, cb, "a1a5")
+	history := NewHistory()
+	for i := 0; i < 1000; i++ {
+		history.UpdateQuiets(move, nil, 20, Ordering{History: history})
+	}
+	if score := history.QuietScore(move, Ordering{}); score <= 0 || score > maxHistory {
+		t.Errorf("expected a score within (0, %d], got %d", maxHistory, score)
+	}
+	for i := 0; i < 1000; i++ {
+		history.UpdateQuiets(findMove(t, cb, "a1a6"), []board.Move{move}, 20, Ordering{History: history})
+	}
+	if score := history.QuietScore(move, Ordering{}); score >= 0 || score < -maxHistory {
+		t.Errorf("expected a score within [-%d, 0), got %d", maxHistory, score)
+	}
+}
+
+func TestMovePickerInCheckOnlyYieldsEvasions(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/8/8/8/4q3/4K3 w - - 0 1")
+	var picker MovePicker
+	picker.Init(cb, nil, Ordering{})
+
+	count := 0
+	for {
+		move, ok := picker.Next()
+		if !ok {
+			break
+		}
+		if move.To != (board.Square{Rank: 1, File: 4}) {
+			t.Errorf("expected only the capture of the checking queen, got %v", move)
+		}
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected 1 evasion, got %d", count)
+	}
+}
+
+// TestMoveOrderingCutsOnTheFirstMove searches a sample of the position
+// corpus and checks that nearly every beta cutoff comes from the first move
+// tried, which is what good move ordering buys.
+func TestMoveOrderingCutsOnTheFirstMove(t *testing.T) {
+	if testing.Short() {
+		t.Skip("searches a benchmark set")
+	}
+	file, err := os.Open("../board/testdata/positions.fen")
+	if err != nil {
+		t.Fatalf("failed to open position corpus: %v", err)
+	}
+	defer file.Close()
+
+	finder := newTestAlphaBetaMoveFinder(t, 5)
+	cutoffs, firstMoveCutoffs := 0, 0
+	scanner := bufio.NewScanner(file)
+	for line := 0; scanner.Scan(); line++ {
+		if line%15 != 0 {
+			continue
+		}
+		finder.NewGame()
+		if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, scanner.Text()), SearchLimits{Depth: 5}); err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		for _, stats := range finder.Stats() {
+			cutoffs += stats.Cutoffs
+			firstMoveCutoffs += stats.FirstMoveCutoffs
+		}
+	}
+
+	rate := float64(firstMoveCutoffs) / float64(cutoffs)
+	t.Logf("%d of %d cutoffs on the first move (%.1f%%)", firstMoveCutoffs, cutoffs, 100*rate)
+	if rate < 0.9 {
+		t.Errorf("expected at least 90%% of cutoffs on the first move, got %.1f%%", 100*rate)
+	}
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type OptionType int
+
+const (
+	SpinOption OptionType = iota
+	CheckOption
+	ComboOption
+	StringOption
+)
+
+// Option describes an engine setting that the GUI can change.
+type Option struct {
+	Name    string
+	Type    OptionType
+	Default string
+	// Min and Max bound spin options.
+	Min int
+	Max int
+	// Vars are the values a combo option can take.
+	Vars []string
+}
+
+// Configurable is implemented by move finders that have options.
+type Configurable interface {
+	Options() []Option
+	SetOption(name, value string) error
+}
+
+// findOption looks name up in options, ignoring case as the UCI protocol
+// asks.
+func findOption(options []Option, name string) (Option, error) {
+	for _, option := range options {
+		if strings.EqualFold(option.Name, name) {
+			return option, nil
+		}
+	}
+	return Option{}, fmt.Errorf("unknown option: %s", name)
+}
+
+// parseSpin parses the value of a spin option and checks its bounds.
+func parseSpin(option Option, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %s", option.Name, value)
+	}
+	if n < option.Min || n > option.Max {
+		return 0, fmt.Errorf("value for %s out of range [%d, %d]: %d", option.Name, option.Min, option.Max, n)
+	}
+	return n, nil
+}
+
+// parseCheck parses the value of a check option.
+func parseCheck(option Option, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid value for %s: %s", option.Name, value)
+}
+
+// parseCombo matches the value of a combo option against its values,
+// ignoring case, and returns the value as the option spells it.
+func parseCombo(option Option, value string) (string, error) {
+	for _, v := range option.Vars {
+		if strings.EqualFold(v, value) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("invalid value for %s: %s", option.Name, value)
+}
@@ -1,36 +1,188 @@
 package search
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"math/rand"
+	"strconv"
+	"time"
 
 	board "jesus_chess/domain/board"
+	evaluation "jesus_chess/domain/evaluation"
 	logging "jesus_chess/domain/logging"
 )
 
+// RandomPolicy names how a RandomMoveFinder weighs the legal moves.
+type RandomPolicy string
+
+const (
+	// UniformPolicy plays every legal move with the same probability.
+	UniformPolicy RandomPolicy = "Uniform"
+	// CapturesPolicy prefers captures.
+	CapturesPolicy RandomPolicy = "Captures"
+	// ChecksPolicy prefers moves that give check.
+	ChecksPolicy RandomPolicy = "Checks"
+	// GreedyPolicy plays one of the moves that win the most material on
+	// the spot, ignoring the reply.
+	GreedyPolicy RandomPolicy = "Greedy"
+)
+
+var randomPolicies = []string{string(UniformPolicy), string(CapturesPolicy), string(ChecksPolicy), string(GreedyPolicy)}
+
+// preferredWeight is how much likelier the CapturesPolicy and ChecksPolicy
+// make the moves they prefer.
+const preferredWeight = 8
+
+// RandomMoveFinder plays random legal moves, weighted by its policy, as a
+// family of baseline opponents. Its moves are drawn from a generator
+// seeded with seed, which is reset on every new game, so that a game is
+// reproduced by replaying it with the seed logged.
 type RandomMoveFinder struct {
 	logger *logging.Logger
+	// seed is the Seed option, zero for a seed drawn from the clock for
+	// every game
+	seed   int64
+	rng    *rand.Rand
+	policy RandomPolicy
 }
 
-func (rmf *RandomMoveFinder) FindBestMove(chessBoard board.ChessBoard) (*board.Move, error) {
-	legalMoves := chessBoard.GenerateLegalMoves()
+func (rmf *RandomMoveFinder) FindBestMove(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) (*SearchResult, error) {
+	var legalMoves []board.Move
+	for _, move := range chessBoard.GenerateLegalMoves() {
+		if isSearchMove(move, limits) {
+			legalMoves = append(legalMoves, move)
+		}
+	}
 	if len(legalMoves) == 0 {
 		return nil, fmt.Errorf("no legal moves available")
 	}
 
-	// log all legal moves
-	for _, move := range legalMoves {
-		rmf.logger.Debug(fmt.Sprintf("legal move: %s from file %d, rank %d to file %d, rank %d", move.Piece.Name, move.From.File, move.From.Rank, move.To.File, move.To.Rank))
+	weights := rmf.weights(chessBoard, legalMoves)
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	pick := rmf.rng.Intn(total)
+	move := legalMoves[len(legalMoves)-1]
+	for i, weight := range weights {
+		if pick < weight {
+			move = legalMoves[i]
+			break
+		}
+		pick -= weight
 	}
+	rmf.logger.Debug(fmt.Sprintf("random move selected: %s from file %d, rank %d to file %d, rank %d, policy %s", move.Piece.Name, move.From.File, move.From.Rank, move.To.File, move.To.Rank, rmf.policy))
 
-	randomIndex := rand.Intn(len(legalMoves))
+	waitForGUI(ctx, limits)
+	return &SearchResult{BestMove: move, PV: []board.Move{move}}, nil
+}
 
-	move := legalMoves[randomIndex]
-	rmf.logger.Debug(fmt.Sprintf("random move selected: %s from file %d, rank %d to file %d, rank %d", move.Piece.Name, move.From.File, move.From.Rank, move.To.File, move.To.Rank))
+// weights returns the relative probability of playing each of moves.
+func (rmf *RandomMoveFinder) weights(chessBoard board.ChessBoard, moves []board.Move) []int {
+	weights := make([]int, len(moves))
+	bestGain := math.MinInt
+	for i, move := range moves {
+		weights[i] = 1
+		switch rmf.policy {
+		case CapturesPolicy:
+			if move.CapturedPiece != nil {
+				weights[i] = preferredWeight
+			}
+		case ChecksPolicy:
+			chessBoard.MakeMove(move)
+			if chessBoard.InCheck(chessBoard.SideToMove()) {
+				weights[i] = preferredWeight
+			}
+			chessBoard.UndoMove()
+		case GreedyPolicy:
+			bestGain = max(bestGain, materialGain(move))
+		}
+	}
+	if rmf.policy == GreedyPolicy {
+		for i, move := range moves {
+			if materialGain(move) < bestGain {
+				weights[i] = 0
+			}
+		}
+	}
+	return weights
+}
+
+// materialGain is the material move wins at once: the piece it captures
+// and what its promotion adds.
+func materialGain(move board.Move) int {
+	gain := 0
+	if move.CapturedPiece != nil {
+		gain += evaluation.PieceValue(move.CapturedPiece.Name)
+	}
+	if move.Promotion != nil {
+		gain += evaluation.PieceValue(move.Promotion.Name) - evaluation.PawnValue
+	}
+	return gain
+}
+
+// SetSeed restarts the generator from seed, or from a seed drawn from the
+// clock if seed is zero.
+func (rmf *RandomMoveFinder) SetSeed(seed int64) {
+	rmf.seed = seed
+	rmf.rng = newSeededRand(rmf.logger, "random move finder", seed)
+}
+
+// newSeededRand returns a generator seeded with seed, or with a seed drawn
+// from the clock if seed is zero, and logs the seed used so that the
+// finder's games can be replayed.
+func newSeededRand(logger *logging.Logger, finder string, seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()&math.MaxInt32 | 1
+	}
+	logger.Info(finder + " seed: " + strconv.FormatInt(seed, 10))
+	return rand.New(rand.NewSource(seed))
+}
+
+// Options offers the Seed option, where zero draws a new seed from the
+// clock for every game.
+func (rmf *RandomMoveFinder) Options() []Option {
+	return []Option{
+		{Name: "Seed", Type: SpinOption, Default: strconv.FormatInt(rmf.seed, 10), Min: 0, Max: math.MaxInt32},
+		{Name: "Policy", Type: ComboOption, Default: string(UniformPolicy), Vars: randomPolicies},
+	}
+}
+
+func (rmf *RandomMoveFinder) SetOption(name, value string) error {
+	option, err := findOption(rmf.Options(), name)
+	if err != nil {
+		return err
+	}
+	switch option.Name {
+	case "Seed":
+		seed, err := parseSpin(option, value)
+		if err != nil {
+			return err
+		}
+		rmf.SetSeed(int64(seed))
+	case "Policy":
+		policy, err := parseCombo(option, value)
+		if err != nil {
+			return err
+		}
+		rmf.policy = RandomPolicy(policy)
+	}
+	return nil
+}
 
-	return &move, nil
+// NewGame restarts the generator from the seed, so that every game can be
+// replayed from it, or from a new seed drawn from the clock if the seed is
+// zero.
+func (rmf *RandomMoveFinder) NewGame() {
+	rmf.SetSeed(rmf.seed)
 }
 
-func NewRandomMoveFinder(logger *logging.Logger) *RandomMoveFinder {
-	return &RandomMoveFinder{logger: logger}
+// NewRandomMoveFinder returns a finder playing uniformly random moves
+// drawn from seed, which must lie between zero and math.MaxInt32 to be
+// settable as an option. A seed of zero is drawn from the clock.
+func NewRandomMoveFinder(logger *logging.Logger, seed int64) *RandomMoveFinder {
+	rmf := &RandomMoveFinder{logger: logger, policy: UniformPolicy}
+	rmf.SetSeed(seed)
+	return rmf
 }
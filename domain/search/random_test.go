@@ -0,0 +1,162 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+)
+
+func newTestRandomMoveFinder(t testing.TB, seed int64) *RandomMoveFinder {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	return NewRandomMoveFinder(logger, seed)
+}
+
+// playRandomGame plays plies moves of a game from the start position and
+// returns them.
+func playRandomGame(t *testing.T, finder *RandomMoveFinder, plies int) []board.Move {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	var moves []board.Move
+	for i := 0; i < plies; i++ {
+		result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{})
+		if err != nil {
+			break
+		}
+		moves = append(moves, result.BestMove)
+		cb.MakeMove(result.BestMove)
+	}
+	return moves
+}
+
+func sameMoves(a, b []board.Move) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRandomMoveFinderIsReproducible(t *testing.T) {
+	first := playRandomGame(t, newTestRandomMoveFinder(t, 42), 40)
+	if again := playRandomGame(t, newTestRandomMoveFinder(t, 42), 40); !sameMoves(first, again) {
+		t.Errorf("expected the same seed to replay the same game")
+	}
+	if other := playRandomGame(t, newTestRandomMoveFinder(t, 43), 40); sameMoves(first, other) {
+		t.Errorf("expected another seed to play another game")
+	}
+
+	finder := newTestRandomMoveFinder(t, 42)
+	playRandomGame(t, finder, 10)
+	finder.NewGame()
+	if replay := playRandomGame(t, finder, 40); !sameMoves(first, replay) {
+		t.Errorf("expected a new game to restart from the seed")
+	}
+
+	if err := finder.SetOption("Seed", "43"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if other := playRandomGame(t, finder, 40); sameMoves(first, other) {
+		t.Errorf("expected the Seed option to change the game")
+	}
+}
+
+func TestRandomPolicies(t *testing.T) {
+	// The bishop can take the queen, the knight the pawn, and the rook can
+	// give check
+	fen := "4k3/8/8/8/3q4/2B4p/8/R3K1N1 w - - 0 1"
+	tests := []struct {
+		policy RandomPolicy
+		prefer func(cb board.ChessBoard, move board.Move) bool
+		// share is the least share of the picks the preferred moves get
+		share float64
+	}{
+		{CapturesPolicy, func(cb board.ChessBoard, move board.Move) bool { return move.CapturedPiece != nil }, 0.3},
+		{ChecksPolicy, func(cb board.ChessBoard, move board.Move) bool {
+			cb.MakeMove(move)
+			defer cb.UndoMove()
+			return cb.InCheck(cb.SideToMove())
+		}, 0.2},
+		{GreedyPolicy, func(cb board.ChessBoard, move board.Move) bool {
+			return move.CapturedPiece != nil && move.CapturedPiece.Name == board.Queen
+		}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			cb := newTestBoard(t, fen)
+			count := func(finder *RandomMoveFinder) int {
+				hits := 0
+				for i := 0; i < 200; i++ {
+					result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{})
+					if err != nil {
+						t.Fatalf("search failed: %v", err)
+					}
+					if test.prefer(cb, result.BestMove) {
+						hits++
+					}
+				}
+				return hits
+			}
+			finder := newTestRandomMoveFinder(t, 7)
+			uniform := count(finder)
+			if err := finder.SetOption("policy", string(test.policy)); err != nil {
+				t.Fatalf("failed to set option: %v", err)
+			}
+			preferred := count(finder)
+			if preferred <= uniform || float64(preferred) < test.share*200 {
+				t.Errorf("expected the policy to prefer its moves, got %d of 200 against %d uniformly", preferred, uniform)
+			}
+		})
+	}
+}
+
+func TestRandomMoveFinderHonoursSearchMoves(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	allowed := findMove(t, cb, "e2e4")
+	finder := newTestRandomMoveFinder(t, 1)
+	for i := 0; i < 20; i++ {
+		result, err := finder.FindBestMove(context.Background(), cb, SearchLimits{SearchMoves: []board.Move{allowed}})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if !result.BestMove.Equal(allowed) {
+			t.Fatalf("expected only e2e4, got %v", result.BestMove)
+		}
+	}
+}
+
+func TestRandomMoveFinderOptions(t *testing.T) {
+	finder := newTestRandomMoveFinder(t, 5)
+	if err := finder.SetOption("Policy", "sideways"); err == nil {
+		t.Errorf("expected an unknown policy to be rejected")
+	}
+	if err := finder.SetOption("Policy", "greedy"); err != nil || finder.policy != GreedyPolicy {
+		t.Errorf("expected the greedy policy, got %s and %v", finder.policy, err)
+	}
+	if options := finder.Options(); options[0].Default != "5" {
+		t.Errorf("expected the seed as the default, got %s", options[0].Default)
+	}
+}
+
+func TestRandomMoveFinderSeedZeroDrawsFromTheClock(t *testing.T) {
+	finder := newTestRandomMoveFinder(t, 42)
+	if err := finder.SetOption("Seed", "0"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	first := playRandomGame(t, finder, 40)
+	finder.NewGame()
+	if again := playRandomGame(t, finder, 40); sameMoves(first, again) {
+		t.Errorf("expected a new seed for every game with the seed zero")
+	}
+	if zero := playRandomGame(t, newTestRandomMoveFinder(t, 0), 40); sameMoves(first, zero) {
+		t.Errorf("expected the constructor to draw a seed for zero as well")
+	}
+}
@@ -0,0 +1,38 @@
+package search
+
+const (
+	// MaxPly bounds the distance from the root that search will reach.
+	MaxPly = 128
+
+	// Infinity is larger than any score search can return.
+	Infinity = 32000
+
+	// MateScore is the score of delivering mate at the root. Mate found n
+	// plies from the root scores MateScore-n so that shorter mates are
+	// preferred, and being mated scores the negation.
+	MateScore = 31000
+
+	DrawScore = 0
+)
+
+func mateIn(ply int) int {
+	return MateScore - ply
+}
+
+func matedIn(ply int) int {
+	return -MateScore + ply
+}
+
+// IsMateScore reports whether score announces a forced mate for either side.
+func IsMateScore(score int) bool {
+	return score > MateScore-MaxPly || score < -MateScore+MaxPly
+}
+
+// MateDistance converts a mate score into full moves until mate, positive
+// when the side to move mates and negative when it is mated.
+func MateDistance(score int) int {
+	if score > 0 {
+		return (MateScore - score + 1) / 2
+	}
+	return -(MateScore + score) / 2
+}
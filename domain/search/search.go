@@ -1,9 +1,125 @@
 package search
 
 import (
+	"context"
+	"time"
+
 	board "jesus_chess/domain/board"
 )
 
+// MoveFinder chooses a move for the side to move. Implementations must
+// return promptly once ctx is cancelled, with the best move found so far.
 type MoveFinder interface {
-	FindBestMove(board board.ChessBoard) (*board.Move, error)
+	FindBestMove(ctx context.Context, chessBoard board.ChessBoard, limits SearchLimits) (*SearchResult, error)
+}
+
+// SearchLimits mirrors the parameters of the UCI go command. Zero values
+// mean the limit is not set.
+type SearchLimits struct {
+	Depth          int
+	Nodes          int
+	MoveTime       time.Duration
+	WhiteTime      time.Duration
+	BlackTime      time.Duration
+	WhiteIncrement time.Duration
+	BlackIncrement time.Duration
+	MovesToGo      int
+	// Mate asks for a mate in at most this many moves, which a mate search
+	// proves in place of the usual search.
+	Mate int
+	// Infinite searches until cancelled, even after the search is complete.
+	Infinite bool
+	// Ponder searches the position after the expected reply during the
+	// opponent's time. The search is not timed until PonderHit is closed,
+	// when the opponent plays that reply and the clock starts.
+	Ponder    bool
+	PonderHit <-chan struct{}
+	// SearchMoves restricts the moves considered at the root.
+	SearchMoves []board.Move
+}
+
+// SearchResult is the outcome of a search.
+type SearchResult struct {
+	BestMove board.Move
+	// PonderMove is the expected reply to BestMove, or nil if unknown.
+	PonderMove *board.Move
+	// Score is in centipawns from the side to move's point of view, or a
+	// mate score; see IsMateScore and MateDistance.
+	Score int
+	PV    []board.Move
+}
+
+// SearchInfo describes the result of one completed iteration of an
+// iterative deepening search.
+type SearchInfo struct {
+	Depth    int
+	SelDepth int
+	// MultiPV ranks the line among the lines searched, from 1 for the best.
+	MultiPV int
+	// Score is in centipawns from the side to move's point of view, or a
+	// mate score; see IsMateScore and MateDistance.
+	Score int
+	Nodes int
+	Time  time.Duration
+	// HashFull is how full the transposition table is, in permille.
+	HashFull int
+	PV       []board.Move
+	// RootMoves describes every root move, most visited first, for the
+	// finders that count visits.
+	RootMoves []RootMoveInfo
+}
+
+// RootMoveInfo describes the search of a root move by its visits and the
+// share of their results it won, from the side to move's point of view.
+type RootMoveInfo struct {
+	Move    board.Move
+	Visits  int
+	WinRate float64
+}
+
+// NPS returns the search speed in nodes per second.
+func (info SearchInfo) NPS() int {
+	if info.Time <= 0 {
+		return 0
+	}
+	return int(float64(info.Nodes) / info.Time.Seconds())
+}
+
+type InfoCallback func(info SearchInfo)
+
+// InfoReporter is implemented by move finders that report their progress
+// while searching.
+type InfoReporter interface {
+	SetInfoCallback(callback InfoCallback)
+}
+
+// GameResetter is implemented by move finders that keep state from one
+// move of a game to the next, which a new game must clear.
+type GameResetter interface {
+	NewGame()
+}
+
+// waitForGUI holds a completed search back until the GUI expects its best
+// move: after stop when the search is infinite, and after stop or
+// ponderhit when it is pondering.
+func waitForGUI(ctx context.Context, limits SearchLimits) {
+	switch {
+	case limits.Infinite:
+		<-ctx.Done()
+	case limits.Ponder:
+		select {
+		case <-ctx.Done():
+		case <-limits.PonderHit:
+		}
+	}
+}
+
+// resultFromPV builds a search result whose best and ponder moves are the
+// first two moves of pv.
+func resultFromPV(pv []board.Move, score int) *SearchResult {
+	result := &SearchResult{BestMove: pv[0], Score: score, PV: pv}
+	if len(pv) > 1 {
+		result.PonderMove = &pv[1]
+	}
+	return result
 }
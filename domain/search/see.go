@@ -0,0 +1,79 @@
+package search
+
+import (
+	"math/bits"
+
+	board "jesus_chess/domain/board"
+	evaluation "jesus_chess/domain/evaluation"
+)
+
+// seeAttackerClasses orders the pieces from the least valuable attacker.
+var seeAttackerClasses = [...]board.PieceName{board.Pawn, board.Knight, board.Bishop, board.Rook, board.Queen, board.King}
+
+// SEE returns the static exchange evaluation of move: the material the
+// side to move wins, in centipawns, if both sides keep capturing on the
+// target square with their least valuable attacker for as long as it pays.
+// Pieces that move out of the way reveal the sliders behind them; pins are
+// ignored.
+func SEE(chessBoard board.ChessBoard, move board.Move) int {
+	var gain [32]int
+	// removed holds the squares vacated during the exchange
+	removed := uint64(1) << move.From.Index()
+	if move.IsEnPassant {
+		removed |= uint64(1) << board.Square{Rank: move.From.Rank, File: move.To.File}.Index()
+	}
+
+	if move.CapturedPiece != nil {
+		gain[0] = evaluation.PieceValue(move.CapturedPiece.Name)
+	}
+	onSquare := evaluation.PieceValue(move.Piece.Name)
+	if move.Promotion != nil {
+		onSquare = evaluation.PieceValue(move.Promotion.Name)
+		gain[0] += onSquare - evaluation.PawnValue
+	}
+
+	side := board.OppositeColor(move.Piece.Color)
+	depth := 0
+	for depth+1 < len(gain) {
+		from, name, ok := leastValuableAttacker(chessBoard, move.To, side, removed)
+		if !ok {
+			break
+		}
+		depth++
+		// Capturing gains the piece on the square but puts the attacker there
+		gain[depth] = onSquare - gain[depth-1]
+		if max(-gain[depth-1], gain[depth]) < 0 {
+			// This capture loses even if it ends the exchange, so it is
+			// never made
+			depth--
+			break
+		}
+		removed |= uint64(1) << from.Index()
+		onSquare = evaluation.PieceValue(name)
+		side = board.OppositeColor(side)
+	}
+
+	// Each side may stop capturing when that is better for it
+	for ; depth > 0; depth-- {
+		gain[depth-1] = -max(-gain[depth-1], gain[depth])
+	}
+	return gain[0]
+}
+
+// leastValuableAttacker finds the cheapest piece of color attacking target,
+// treating the removed squares as empty.
+func leastValuableAttacker(chessBoard board.ChessBoard, target board.Square, color board.Color, removed uint64) (board.Square, board.PieceName, bool) {
+	attackers := chessBoard.AttackersTo(target, color, removed)
+	if attackers == 0 {
+		return board.Square{}, "", false
+	}
+	for _, name := range seeAttackerClasses {
+		for remaining := attackers; remaining != 0; remaining &= remaining - 1 {
+			from := board.SquareAt(bits.TrailingZeros64(remaining))
+			if chessBoard.PieceAt(from).Name == name {
+				return from, name, true
+			}
+		}
+	}
+	return board.Square{}, "", false
+}
@@ -0,0 +1,33 @@
+package search
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		move     string
+		expected int
+	}{
+		{"undefended pawn", "1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		{"losing exchange sequence", "1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", 100 - 320},
+		{"undefended knight", "4k3/8/8/3n4/8/8/8/3RK3 w - - 0 1", "d1d5", 320},
+		{"defended knight", "3rk3/8/8/3n4/8/8/8/3RK3 w - - 0 1", "d1d5", 320 - 500},
+		{"x-ray support", "3rk3/8/8/3n4/8/8/3R4/3RK3 w - - 0 1", "d2d5", 320},
+		{"quiet move onto attacked square", "4k3/8/8/3p4/8/8/4P3/4K3 w - - 0 1", "e2e4", -100},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		{"promotion", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", 800},
+		{"capturing promotion", "r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7a8q", 500 + 800},
+		{"king recaptures", "3k4/8/8/8/8/3q4/8/3RK3 b - - 0 1", "d3d1", 500 - 900},
+		{"king cannot recapture a defended piece", "3rk3/3r4/8/8/8/8/8/3QK3 b - - 0 1", "d7d1", 900},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cb := newTestBoard(t, test.fen)
+			if got := SEE(cb, findMove(t, cb, test.move)); got != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"math"
+
+	board "jesus_chess/domain/board"
+	evaluation "jesus_chess/domain/evaluation"
+)
+
+const (
+	// nullMoveMinDepth is the shallowest node where null move pruning is
+	// tried.
+	nullMoveMinDepth = 3
+	// reverseFutilityMaxDepth, razoringMaxDepth, futilityMaxDepth and
+	// lateMovePruningMaxDepth bound the depths the pruning techniques apply
+	// to, close to the leaves where the static evaluation is reliable.
+	reverseFutilityMaxDepth = 6
+	razoringMaxDepth        = 3
+	futilityMaxDepth        = 3
+	lateMovePruningMaxDepth = 4
+	// lmrMinDepth and lmrMinMoves bound the nodes and moves that late move
+	// reductions apply to.
+	lmrMinDepth = 3
+	lmrMinMoves = 4
+	// lmrHistoryDivisor converts a history score into plies of reduction.
+	lmrHistoryDivisor = 8192
+	// aspirationMinDepth is the first iteration searched with an
+	// aspiration window, and aspirationDelta its initial half-width. Once
+	// the half-width has grown past aspirationMaxDelta the window is opened
+	// fully.
+	aspirationMinDepth = 5
+	aspirationDelta    = 25
+	aspirationMaxDelta = 500
+)
+
+// Selectivity switches the selective search techniques on and off, so that
+// the contribution of each can be measured by playing with it disabled.
+// Without PVS every node is searched with an open window, so the techniques
+// restricted to nodes off the principal variation no longer apply.
+type Selectivity struct {
+	NullMove           bool
+	LateMoveReductions bool
+	Futility           bool
+	ReverseFutility    bool
+	Razoring           bool
+	LateMovePruning    bool
+	PVS                bool
+	AspirationWindows  bool
+}
+
+// DefaultSelectivity enables every technique.
+func DefaultSelectivity() Selectivity {
+	return Selectivity{
+		NullMove:           true,
+		LateMoveReductions: true,
+		Futility:           true,
+		ReverseFutility:    true,
+		Razoring:           true,
+		LateMovePruning:    true,
+		PVS:                true,
+		AspirationWindows:  true,
+	}
+}
+
+type selectivityToggle struct {
+	name    string
+	enabled *bool
+}
+
+// toggles names the UCI option of each technique.
+func (s *Selectivity) toggles() []selectivityToggle {
+	return []selectivityToggle{
+		{"Null Move Pruning", &s.NullMove},
+		{"Late Move Reductions", &s.LateMoveReductions},
+		{"Futility Pruning", &s.Futility},
+		{"Reverse Futility Pruning", &s.ReverseFutility},
+		{"Razoring", &s.Razoring},
+		{"Late Move Pruning", &s.LateMovePruning},
+		{"Principal Variation Search", &s.PVS},
+		{"Aspiration Windows", &s.AspirationWindows},
+	}
+}
+
+// lmrReductions[depth][moveNumber] is the base late move reduction,
+// growing with the logarithms of both.
+var lmrReductions [MaxPly][board.MaxMoves]int
+
+func init() {
+	for depth := 1; depth < MaxPly; depth++ {
+		for moveNumber := 1; moveNumber < board.MaxMoves; moveNumber++ {
+			lmrReductions[depth][moveNumber] = int(0.75 + math.Log(float64(depth))*math.Log(float64(moveNumber))/2.25)
+		}
+	}
+}
+
+func reverseFutilityMargin(depth int) int {
+	return 80 * depth
+}
+
+func razoringMargin(depth int) int {
+	return 300 + 200*(depth-1)
+}
+
+func futilityMargin(depth int) int {
+	return 100 + 120*depth
+}
+
+// lateMovePruningThreshold is the number of quiet moves searched at depth
+// after which the remaining quiet moves are skipped.
+func lateMovePruningThreshold(depth int) int {
+	return 3 + depth*depth
+}
+
+func nullMoveReduction(depth int) int {
+	return 3 + depth/6
+}
+
+// nonPawnMaterial sums the values of color's knights, bishops, rooks and
+// queens. Without them, passing is often the best move and null move
+// pruning fails; with little of them zugzwang is common enough to verify.
+func nonPawnMaterial(chessBoard board.ChessBoard, color board.Color) int {
+	material := 0
+	for rank := 0; rank < board.BoardHeight; rank++ {
+		for file := 0; file < board.BoardWidth; file++ {
+			piece := chessBoard.PieceAt(board.Square{Rank: rank, File: file})
+			if piece != nil && piece.Color == color && piece.Name != board.Pawn && piece.Name != board.King {
+				material += evaluation.PieceValue(piece.Name)
+			}
+		}
+	}
+	return material
+}
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	board "jesus_chess/domain/board"
+)
+
+// TestSelectivityKeepsTactics searches tactical positions with every
+// technique enabled and with each one disabled in turn, which must not
+// change the moves found.
+func TestSelectivityKeepsTactics(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		depth  int
+		move   string // empty when several moves are equally good
+		mateIn int    // zero when the position is not a mate
+	}{
+		{"back rank mate in 1", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 4, "a1a8", 1},
+		{"rook ladder mate in 2", "6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1", 6, "", 2},
+		{"hanging queen", "4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1", 6, "d2d5", 0},
+		{"zugzwang", "1q1k4/2Rr4/8/2Q3K1/8/8/8/8 w - - 0 1", 8, "g5h6", 0},
+	}
+
+	defaults := DefaultSelectivity()
+	configurations := map[string]Selectivity{"all enabled": defaults}
+	for _, toggle := range defaults.toggles() {
+		selectivity := DefaultSelectivity()
+		for _, other := range selectivity.toggles() {
+			if other.name == toggle.name {
+				*other.enabled = false
+			}
+		}
+		configurations[toggle.name+" disabled"] = selectivity
+	}
+
+	for configuration, selectivity := range configurations {
+		for _, test := range tests {
+			t.Run(configuration+"/"+test.name, func(t *testing.T) {
+				finder := newTestAlphaBetaMoveFinder(t, test.depth)
+				finder.selectivity = selectivity
+				info, err := finder.iterativeDeepening(context.Background(), newTestBoard(t, test.fen), SearchLimits{Depth: test.depth})
+				if err != nil {
+					t.Fatalf("search failed: %v", err)
+				}
+				move := squareString(info.PV[0].From) + squareString(info.PV[0].To)
+				if test.move != "" && move != test.move {
+					t.Errorf("expected %s, got %s with score %d", test.move, move, info.Score)
+				}
+				if test.mateIn > 0 && (!IsMateScore(info.Score) || MateDistance(info.Score) != test.mateIn) {
+					t.Errorf("expected mate in %d, got score %d", test.mateIn, info.Score)
+				}
+			})
+		}
+	}
+}
+
+func TestSelectivityReducesNodes(t *testing.T) {
+	fen := "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"
+	nodes := func(selectivity Selectivity) int {
+		finder := newTestAlphaBetaMoveFinder(t, 5)
+		finder.selectivity = selectivity
+		info, err := finder.iterativeDeepening(context.Background(), newTestBoard(t, fen), SearchLimits{Depth: 5})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		return info.Nodes
+	}
+
+	selective, full := nodes(DefaultSelectivity()), nodes(Selectivity{})
+	t.Logf("%d nodes with selectivity, %d without", selective, full)
+	if selective >= full {
+		t.Errorf("expected fewer nodes with selectivity, got %d against %d", selective, full)
+	}
+}
+
+func TestNullMoveSkipsPawnEndings(t *testing.T) {
+	cb := newTestBoard(t, "8/8/8/2k5/2Pp4/3K4/8/8 w - - 0 1")
+	if material := nonPawnMaterial(cb, board.White); material != 0 {
+		t.Fatalf("expected no non-pawn material, got %d", material)
+	}
+
+	finder := newTestAlphaBetaMoveFinder(t, 6)
+	finder.ctx = context.Background()
+	// Beyond the reach of the other techniques, a window far below the
+	// static evaluation would allow a null move cutoff if one were tried
+	if _, ok := finder.prune(cb, reverseFutilityMaxDepth+1, 1, -1000, -999, 0); ok {
+		t.Errorf("expected no null move cutoff in a pawn ending")
+	}
+}
+
+func TestSelectivityOptions(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 4)
+	if err := finder.SetOption("null move pruning", "false"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if err := finder.SetOption("Aspiration Windows", "False"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if finder.selectivity.NullMove || finder.selectivity.AspirationWindows {
+		t.Errorf("expected null move and aspiration windows disabled, got %+v", finder.selectivity)
+	}
+	if !finder.selectivity.LateMoveReductions {
+		t.Errorf("expected the other techniques to stay enabled")
+	}
+	if err := finder.SetOption("Razoring", "on"); err == nil {
+		t.Errorf("expected a malformed value to be rejected")
+	}
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"fmt"
+)
+
+// SearchStats describes what one iteration of the search did, to see the
+// effect of a change to the search beyond its node count. The counts are
+// the main thread's.
+type SearchStats struct {
+	Depth int `json:"depth"`
+	// Nodes counts every node, of which PVNodes were searched with an
+	// open window, NonPVNodes with a null window and QuiescenceNodes by
+	// the quiescence search.
+	Nodes           int `json:"nodes"`
+	PVNodes         int `json:"pv_nodes"`
+	NonPVNodes      int `json:"non_pv_nodes"`
+	QuiescenceNodes int `json:"quiescence_nodes"`
+	// TTHits counts the transposition table probes that found the
+	// position, and TTCutoffs those whose score settled the node.
+	TTProbes  int `json:"tt_probes"`
+	TTHits    int `json:"tt_hits"`
+	TTCutoffs int `json:"tt_cutoffs"`
+	// FirstMoveCutoffs counts the beta cutoffs caused by the first move
+	// searched, which measures the move ordering.
+	Cutoffs          int `json:"cutoffs"`
+	FirstMoveCutoffs int `json:"first_move_cutoffs"`
+	NullMoveTries    int `json:"null_move_tries"`
+	NullMoveCutoffs  int `json:"null_move_cutoffs"`
+	// ReSearches counts the reduced searches of late moves that beat
+	// alpha and were searched again at full depth.
+	Reductions int `json:"reductions"`
+	ReSearches int `json:"re_searches"`
+	// BranchingFactor is the growth in nodes over the previous iteration.
+	BranchingFactor float64 `json:"branching_factor"`
+}
+
+// StatsReporter is implemented by move finders that collect statistics
+// about their last search.
+type StatsReporter interface {
+	Stats() []SearchStats
+}
+
+func ratio(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+func (s SearchStats) TTHitRate() float64 {
+	return ratio(s.TTHits, s.TTProbes)
+}
+
+func (s SearchStats) FirstMoveCutoffRate() float64 {
+	return ratio(s.FirstMoveCutoffs, s.Cutoffs)
+}
+
+func (s SearchStats) NullMoveSuccessRate() float64 {
+	return ratio(s.NullMoveCutoffs, s.NullMoveTries)
+}
+
+func (s SearchStats) ReSearchRate() float64 {
+	return ratio(s.ReSearches, s.Reductions)
+}
+
+func (s SearchStats) QuiescenceShare() float64 {
+	return ratio(s.QuiescenceNodes, s.Nodes)
+}
+
+// String summarises the iteration on one line, rates as percentages.
+func (s SearchStats) String() string {
+	return fmt.Sprintf("depth %d nodes %d pv %d nonpv %d qnodes %.1f%% tthits %.1f%% ttcuts %d firstcut %.1f%% nullmove %.1f%% of %d research %.1f%% of %d ebf %.2f",
+		s.Depth, s.Nodes, s.PVNodes, s.NonPVNodes, 100*s.QuiescenceShare(), 100*s.TTHitRate(), s.TTCutoffs,
+		100*s.FirstMoveCutoffRate(), 100*s.NullMoveSuccessRate(), s.NullMoveTries, 100*s.ReSearchRate(), s.Reductions, s.BranchingFactor)
+}
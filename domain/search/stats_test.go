@@ -0,0 +1,78 @@
+package search
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSearchCollectsStatsPerIteration(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 5)
+	var infos []SearchInfo
+	finder.SetInfoCallback(func(info SearchInfo) {
+		infos = append(infos, info)
+	})
+	cb := newTestBoard(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	if _, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 5}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	stats := finder.Stats()
+	if len(stats) != 5 {
+		t.Fatalf("expected statistics for 5 iterations, got %d", len(stats))
+	}
+	nodes := 0
+	for i, iteration := range stats {
+		if iteration.Depth != i+1 {
+			t.Errorf("expected iteration %d at depth %d, got %d", i, i+1, iteration.Depth)
+		}
+		if iteration.PVNodes+iteration.NonPVNodes+iteration.QuiescenceNodes != iteration.Nodes {
+			t.Errorf("expected the node types to add up to the nodes at depth %d, got %+v", iteration.Depth, iteration)
+		}
+		if iteration.TTHits > iteration.TTProbes || iteration.FirstMoveCutoffs > iteration.Cutoffs ||
+			iteration.NullMoveCutoffs > iteration.NullMoveTries || iteration.ReSearches > iteration.Reductions {
+			t.Errorf("expected every count within its total at depth %d, got %+v", iteration.Depth, iteration)
+		}
+		if i > 0 && iteration.BranchingFactor != float64(iteration.Nodes)/float64(stats[i-1].Nodes) {
+			t.Errorf("expected the growth over the previous iteration at depth %d, got %f", iteration.Depth, iteration.BranchingFactor)
+		}
+		nodes += iteration.Nodes
+	}
+	if last := infos[len(infos)-1]; nodes != last.Nodes {
+		t.Errorf("expected the iterations to add up to the %d nodes reported, got %d", last.Nodes, nodes)
+	}
+
+	deepest := stats[len(stats)-1]
+	if deepest.Cutoffs == 0 || deepest.TTHits == 0 || deepest.NullMoveTries == 0 || deepest.Reductions == 0 {
+		t.Errorf("expected the deepest iteration to cut off, hit the table, try null moves and reduce, got %+v", deepest)
+	}
+	if share := deepest.QuiescenceShare(); share <= 0 || share >= 1 {
+		t.Errorf("expected part of the nodes in quiescence, got %f", share)
+	}
+
+	if _, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Mate: 1}); err == nil {
+		t.Fatalf("expected no mate in one")
+	}
+	if stats := finder.Stats(); len(stats) != 0 {
+		t.Errorf("expected no statistics from a mate search, got %d iterations", len(stats))
+	}
+}
+
+func TestSearchStatsRates(t *testing.T) {
+	stats := SearchStats{Nodes: 200, QuiescenceNodes: 50, TTProbes: 80, TTHits: 20, Cutoffs: 10, FirstMoveCutoffs: 9}
+	tests := []struct {
+		name string
+		rate float64
+		want float64
+	}{
+		{"tt hit rate", stats.TTHitRate(), 0.25},
+		{"first move cutoff rate", stats.FirstMoveCutoffRate(), 0.9},
+		{"quiescence share", stats.QuiescenceShare(), 0.25},
+		{"null move success rate without tries", stats.NullMoveSuccessRate(), 0},
+		{"re-search rate without reductions", stats.ReSearchRate(), 0},
+	}
+	for _, test := range tests {
+		if test.rate != test.want {
+			t.Errorf("%s: expected %f, got %f", test.name, test.want, test.rate)
+		}
+	}
+}
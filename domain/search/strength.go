@@ -0,0 +1,99 @@
+package search
+
+import (
+	"math"
+	"math/rand"
+
+	evaluation "jesus_chess/domain/evaluation"
+)
+
+const (
+	// MaxSkillLevel is full strength, and the default of the Skill Level
+	// option.
+	MaxSkillLevel = 20
+	// MinElo and MaxElo bound the UCI_Elo option, and map to skill levels
+	// zero and MaxSkillLevel.
+	MinElo     = 800
+	MaxElo     = 2400
+	DefaultElo = 1500
+
+	// skillMultiPV is the number of lines searched to choose among when
+	// the strength is limited.
+	skillMultiPV = 4
+)
+
+// Strength weakens the search so that it makes the mistakes of a weaker
+// player. Below full strength the search is cut short, by depth and by
+// nodes, and its move is chosen among its best lines with noise added to
+// their scores, so that a weaker level plays the second best move more
+// often and errs by more when it does.
+type Strength struct {
+	// LimitStrength plays at the level of Elo rather than at full
+	// strength.
+	LimitStrength bool
+	Elo           int
+	// SkillLevel ranges from zero to MaxSkillLevel.
+	SkillLevel int
+}
+
+// DefaultStrength plays at full strength.
+func DefaultStrength() Strength {
+	return Strength{Elo: DefaultElo, SkillLevel: MaxSkillLevel}
+}
+
+// level returns the skill level to play at, the lower of the Skill Level
+// and that of the Elo when strength is limited, and false at full
+// strength. The Elo maps linearly onto the levels, a scale the match
+// package checks the spacing of but which is anchored to no rated
+// opponent.
+func (s Strength) level() (float64, bool) {
+	level := float64(s.SkillLevel)
+	if s.LimitStrength {
+		level = min(level, MaxSkillLevel*float64(s.Elo-MinElo)/(MaxElo-MinElo))
+	}
+	return level, level < MaxSkillLevel
+}
+
+// elo returns the rating the engine plays at, that of its skill level.
+func (s Strength) elo() int {
+	level, _ := s.level()
+	return MinElo + int(level*(MaxElo-MinElo)/MaxSkillLevel)
+}
+
+// weakenLimits caps the depth of the search at level, from one ply at
+// level zero, and its nodes, from a hundred.
+func weakenLimits(limits SearchLimits, level float64) SearchLimits {
+	depth := 1 + int(level/2)
+	if limits.Depth == 0 || limits.Depth > depth {
+		limits.Depth = depth
+	}
+	nodes := int(100 * math.Pow(2, level/2))
+	if limits.Nodes == 0 || limits.Nodes > nodes {
+		limits.Nodes = nodes
+	}
+	return limits
+}
+
+// pickWeakLine chooses the line to play at level among lines, sorted best
+// first. Each line keeps only part of its deficit to the best line, the
+// less the lower the level, and gains random noise of up to the spread of
+// the scores but at most a pawn; the line then scoring best is played.
+func pickWeakLine(lines []SearchInfo, level float64, rng *rand.Rand) SearchInfo {
+	weakness := 120 - int(2*level)
+	top := lines[0].Score
+	delta := min(top-lines[len(lines)-1].Score, evaluation.PawnValue)
+
+	best := lines[0]
+	bestValue := math.MinInt
+	for _, line := range lines {
+		if line.Score < 0 && IsMateScore(line.Score) {
+			// Walking into a mate is not a plausible mistake
+			continue
+		}
+		push := (weakness*(top-line.Score) + delta*rng.Intn(weakness)) / 128
+		if value := line.Score + push; value > bestValue {
+			best, bestValue = line, value
+		}
+	}
+	return best
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestStrengthLevel(t *testing.T) {
+	tests := []struct {
+		strength Strength
+		level    float64
+		weakened bool
+	}{
+		{DefaultStrength(), MaxSkillLevel, false},
+		{Strength{Elo: MinElo, SkillLevel: MaxSkillLevel}, MaxSkillLevel, false},
+		{Strength{LimitStrength: true, Elo: MinElo, SkillLevel: MaxSkillLevel}, 0, true},
+		{Strength{LimitStrength: true, Elo: MaxElo, SkillLevel: MaxSkillLevel}, MaxSkillLevel, false},
+		{Strength{LimitStrength: true, Elo: (MinElo + MaxElo) / 2, SkillLevel: MaxSkillLevel}, MaxSkillLevel / 2, true},
+		{Strength{LimitStrength: true, Elo: MaxElo, SkillLevel: 5}, 5, true},
+	}
+	for _, test := range tests {
+		level, weakened := test.strength.level()
+		if level != test.level || weakened != test.weakened {
+			t.Errorf("%+v: expected level %v, weakened %v, got %v, %v", test.strength, test.level, test.weakened, level, weakened)
+		}
+	}
+}
+
+func TestWeakenLimits(t *testing.T) {
+	limits := weakenLimits(SearchLimits{}, 0)
+	if limits.Depth != 1 || limits.Nodes != 100 {
+		t.Errorf("expected depth 1 and 100 nodes at level 0, got %+v", limits)
+	}
+	limits = weakenLimits(SearchLimits{Depth: 3, Nodes: 50}, 10)
+	if limits.Depth != 3 || limits.Nodes != 50 {
+		t.Errorf("expected tighter limits to stand, got %+v", limits)
+	}
+}
+
+func TestPickWeakLine(t *testing.T) {
+	lines := []SearchInfo{{Score: 50}, {Score: 30}, {Score: -250}, {Score: -MateScore + 4}}
+	rng := rand.New(rand.NewSource(1))
+	picks := make([]int, len(lines))
+	for i := 0; i < 1000; i++ {
+		picked := pickWeakLine(lines, 0, rng)
+		for j, line := range lines {
+			if line.Score == picked.Score {
+				picks[j]++
+			}
+		}
+	}
+	if picks[0] == 0 || picks[1] == 0 {
+		t.Errorf("expected the weakest level to play both close lines, got %v", picks)
+	}
+	if picks[2] >= picks[1] || picks[3] > 0 {
+		t.Errorf("expected blunders to be rarer than small mistakes and mates avoided, got %v", picks)
+	}
+}
+
+func TestSkillLevelLimitsTheSearch(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 6)
+	if err := finder.SetOption("Skill Level", "0"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	var infos []SearchInfo
+	finder.SetInfoCallback(func(info SearchInfo) {
+		infos = append(infos, info)
+	})
+	result, err := finder.FindBestMove(context.Background(), newTestBoard(t, "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"), SearchLimits{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	found := false
+	for _, info := range infos {
+		if info.Depth != 1 {
+			t.Errorf("expected a single ply at the lowest level, got depth %d", info.Depth)
+		}
+		found = found || info.PV[0].Equal(result.BestMove)
+	}
+	if len(infos) != skillMultiPV || !found {
+		t.Errorf("expected the move played among %d reported lines, got %d", skillMultiPV, len(infos))
+	}
+	if finder.multiPV != 1 {
+		t.Errorf("expected the MultiPV setting restored, got %d", finder.multiPV)
+	}
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"time"
+
+	board "jesus_chess/domain/board"
+)
+
+const (
+	// DefaultMoveOverhead is the time reserved per move for communication
+	// with the GUI.
+	DefaultMoveOverhead = 10 * time.Millisecond
+	// MaxMoveOverhead bounds the Move Overhead option.
+	MaxMoveOverhead = 5 * time.Second
+
+	// defaultMovesToGo is the number of moves the remaining time is spread
+	// over when the time control does not say.
+	defaultMovesToGo = 30
+	// hardLimitFactor is how far the hard limit stretches beyond the soft one.
+	hardLimitFactor = 3
+	// obviousMoveIterations is how many iterations in a row a recapture has
+	// to stay best before it is played early.
+	obviousMoveIterations = 4
+	// obviousMoveFraction is the share of the soft limit spent on an
+	// obvious recapture.
+	obviousMoveFraction = 4
+)
+
+// TimeManager decides how long to think about a move. Start allocates a
+// soft limit, after which no new iteration is started, and a hard limit,
+// after which the search is interrupted. Between iterations Update reports
+// the best move and score so far, and the soft limit grows while the best
+// move keeps changing or the score drops.
+type TimeManager struct {
+	moveOverhead time.Duration
+
+	active bool
+	soft   time.Duration
+	hard   time.Duration
+
+	singleReply     bool
+	recapture       bool
+	recaptureSquare board.Square
+
+	iterations       int
+	previousBest     board.Move
+	previousScore    int
+	stableIterations int
+	// instability counts best move changes, halving every iteration so
+	// that recent changes weigh most
+	instability float64
+	scoreDrop   int
+}
+
+func (tm *TimeManager) SetMoveOverhead(overhead time.Duration) {
+	tm.moveOverhead = overhead
+}
+
+// Start allocates time for a search of chessBoard under limits. Without a
+// clock or a move time the search is not timed.
+func (tm *TimeManager) Start(chessBoard board.ChessBoard, limits SearchLimits) {
+	*tm = TimeManager{moveOverhead: tm.moveOverhead}
+
+	remaining, increment := limits.WhiteTime, limits.WhiteIncrement
+	if chessBoard.SideToMove() == board.Black {
+		remaining, increment = limits.BlackTime, limits.BlackIncrement
+	}
+
+	switch {
+	case limits.Infinite:
+		return
+	case limits.MoveTime > 0:
+		tm.soft = max(limits.MoveTime-tm.moveOverhead, 0)
+		tm.hard = tm.soft
+	case remaining > 0:
+		movesToGo := defaultMovesToGo
+		if limits.MovesToGo > 0 {
+			movesToGo = limits.MovesToGo
+		}
+		// Spread the clock and the increments still to come over the moves
+		// to go, setting aside the overhead of each of them
+		horizon := time.Duration(movesToGo)
+		available := max(remaining+increment*(horizon-1)-tm.moveOverhead*(horizon+2), 0)
+		// Keep a reserve so that a move never uses the whole clock
+		maximum := max(remaining-tm.moveOverhead, 0) * 8 / 10
+		tm.soft = min(available/horizon, maximum)
+		tm.hard = min(tm.soft*hardLimitFactor, maximum)
+	default:
+		return
+	}
+	tm.active = true
+
+	legalMoves := len(chessBoard.GenerateLegalMoves())
+	if len(limits.SearchMoves) > 0 {
+		legalMoves = min(legalMoves, len(limits.SearchMoves))
+	}
+	tm.singleReply = legalMoves == 1
+
+	if lastMove, ok := chessBoard.LastMove(); ok && lastMove.CapturedPiece != nil {
+		tm.recapture = true
+		tm.recaptureSquare = lastMove.To
+	}
+}
+
+// Active reports whether the current search is timed.
+func (tm *TimeManager) Active() bool {
+	return tm.active
+}
+
+// HardLimit returns the time after which the search must stop, and false
+// when the search is not timed.
+func (tm *TimeManager) HardLimit() (time.Duration, bool) {
+	return tm.hard, tm.active
+}
+
+// SoftLimit returns the time after which no new iteration is started,
+// extended for an unstable best move or a falling score.
+func (tm *TimeManager) SoftLimit() time.Duration {
+	factor := 1 + 0.6*tm.instability
+	if tm.scoreDrop > 0 {
+		factor *= 1 + float64(min(tm.scoreDrop, 100))/200
+	}
+	return min(time.Duration(float64(tm.soft)*factor), tm.hard)
+}
+
+// Update records the best move and score of a completed iteration.
+func (tm *TimeManager) Update(bestMove board.Move, score int) {
+	tm.instability /= 2
+	if tm.iterations > 0 {
+		if bestMove.Equal(tm.previousBest) {
+			tm.stableIterations++
+		} else {
+			tm.stableIterations = 0
+			tm.instability++
+		}
+		tm.scoreDrop = max(tm.previousScore-score, 0)
+	}
+	tm.iterations++
+	tm.previousBest = bestMove
+	tm.previousScore = score
+}
+
+// ShouldStop reports whether the search should end rather than start
+// another iteration, elapsed after it started.
+func (tm *TimeManager) ShouldStop(elapsed time.Duration) bool {
+	if !tm.active {
+		return false
+	}
+	if tm.singleReply && tm.iterations > 0 {
+		return true
+	}
+	if tm.obviousRecapture() && elapsed >= tm.soft/obviousMoveFraction {
+		return true
+	}
+	return elapsed >= tm.SoftLimit()
+}
+
+// obviousRecapture reports whether the best move has long been a
+// recapture of the piece just taken, without the score dropping.
+func (tm *TimeManager) obviousRecapture() bool {
+	return tm.recapture &&
+		tm.previousBest.CapturedPiece != nil &&
+		tm.previousBest.To == tm.recaptureSquare &&
+		tm.stableIterations+1 >= obviousMoveIterations &&
+		tm.scoreDrop == 0
+}
+
+func NewTimeManager(moveOverhead time.Duration) *TimeManager {
+	return &TimeManager{moveOverhead: moveOverhead}
+}
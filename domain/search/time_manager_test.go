@@ -0,0 +1,312 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	board "jesus_chess/domain/board"
+)
+
+func findMove(t testing.TB, cb board.ChessBoard, uci string) board.Move {
+	t.Helper()
+	for _, move := range cb.GenerateLegalMoves() {
+		name := squareString(move.From) + squareString(move.To)
+		if move.Promotion != nil {
+			name += strings.ToLower(string(move.Promotion.Name))
+		}
+		if name == uci {
+			return move
+		}
+	}
+	t.Fatalf("move %s is not legal in %s", uci, cb.FEN())
+	return board.Move{}
+}
+
+func TestTimeManagerIsInactiveWithoutAClock(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	tm := NewTimeManager(DefaultMoveOverhead)
+
+	for _, limits := range []SearchLimits{
+		{Depth: 5},
+		{Nodes: 1000},
+		{Infinite: true, WhiteTime: time.Minute},
+		{BlackTime: time.Minute},
+	} {
+		tm.Start(cb, limits)
+		if tm.Active() {
+			t.Errorf("expected no time management for %+v", limits)
+		}
+		if _, ok := tm.HardLimit(); ok {
+			t.Errorf("expected no hard limit for %+v", limits)
+		}
+		tm.Update(findMove(t, cb, "e2e4"), 0)
+		if tm.ShouldStop(time.Hour) {
+			t.Errorf("expected an untimed search never to stop for %+v", limits)
+		}
+	}
+}
+
+func TestTimeManagerMoveTime(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	tm := NewTimeManager(50 * time.Millisecond)
+	tm.Start(cb, SearchLimits{MoveTime: time.Second, WhiteTime: time.Hour})
+
+	hard, ok := tm.HardLimit()
+	if !ok || hard != 950*time.Millisecond {
+		t.Fatalf("expected a hard limit of 950ms, got %v", hard)
+	}
+	e2e4 := findMove(t, cb, "e2e4")
+	d2d4 := findMove(t, cb, "d2d4")
+	tm.Update(e2e4, 0)
+	tm.Update(d2d4, -80)
+	if tm.SoftLimit() != hard {
+		t.Errorf("expected a fixed move time not to be extended, got %v", tm.SoftLimit())
+	}
+	if tm.ShouldStop(949 * time.Millisecond) {
+		t.Errorf("stopped before the move time")
+	}
+	if !tm.ShouldStop(950 * time.Millisecond) {
+		t.Errorf("did not stop at the move time")
+	}
+}
+
+func TestTimeManagerUsesTheSideToMovesClock(t *testing.T) {
+	white := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	black := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	limits := SearchLimits{WhiteTime: 60 * time.Second, BlackTime: 6 * time.Second}
+
+	tm := NewTimeManager(0)
+	tm.Start(white, limits)
+	whiteSoft := tm.SoftLimit()
+	tm.Start(black, limits)
+	blackSoft := tm.SoftLimit()
+
+	if whiteSoft != 2*time.Second || blackSoft != 200*time.Millisecond {
+		t.Errorf("expected 2s for white and 200ms for black, got %v and %v", whiteSoft, blackSoft)
+	}
+}
+
+func TestTimeManagerAccountsForMoveOverhead(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	limits := SearchLimits{WhiteTime: 3 * time.Second, MovesToGo: 1}
+
+	tm := NewTimeManager(0)
+	tm.Start(cb, limits)
+	withoutOverhead, _ := tm.HardLimit()
+
+	tm.SetMoveOverhead(time.Second)
+	tm.Start(cb, limits)
+	withOverhead, _ := tm.HardLimit()
+
+	if withoutOverhead >= 3*time.Second || withOverhead >= 2*time.Second {
+		t.Errorf("expected hard limits within the clock less the overhead, got %v and %v", withoutOverhead, withOverhead)
+	}
+	if withOverhead >= withoutOverhead {
+		t.Errorf("expected the overhead to shorten the hard limit, got %v and %v", withOverhead, withoutOverhead)
+	}
+}
+
+func TestTimeManagerExtendsWhenUnstable(t *testing.T) {
+	cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	limits := SearchLimits{WhiteTime: 60 * time.Second}
+	e2e4 := findMove(t, cb, "e2e4")
+	d2d4 := findMove(t, cb, "d2d4")
+
+	stable := NewTimeManager(0)
+	stable.Start(cb, limits)
+	for i := 0; i < 6; i++ {
+		stable.Update(e2e4, 20)
+	}
+
+	changing := NewTimeManager(0)
+	changing.Start(cb, limits)
+	for i := 0; i < 6; i++ {
+		if i%2 == 0 {
+			changing.Update(e2e4, 20)
+		} else {
+			changing.Update(d2d4, 20)
+		}
+	}
+
+	dropping := NewTimeManager(0)
+	dropping.Start(cb, limits)
+	for i := 0; i < 6; i++ {
+		dropping.Update(e2e4, 20-15*i)
+	}
+
+	hard, _ := stable.HardLimit()
+	if stable.SoftLimit() != 2*time.Second {
+		t.Errorf("expected a stable search to keep its soft limit of 2s, got %v", stable.SoftLimit())
+	}
+	if changing.SoftLimit() <= stable.SoftLimit() || changing.SoftLimit() > hard {
+		t.Errorf("expected a changing best move to extend the soft limit up to %v, got %v", hard, changing.SoftLimit())
+	}
+	if dropping.SoftLimit() <= stable.SoftLimit() || dropping.SoftLimit() > hard {
+		t.Errorf("expected a dropping score to extend the soft limit up to %v, got %v", hard, dropping.SoftLimit())
+	}
+}
+
+func TestTimeManagerStopsEarlyOnASingleReply(t *testing.T) {
+	cb := newTestBoard(t, "k7/8/8/8/8/8/r7/7K w - - 0 1")
+	tm := NewTimeManager(0)
+	tm.Start(cb, SearchLimits{WhiteTime: 60 * time.Second})
+
+	if tm.ShouldStop(0) {
+		t.Errorf("stopped before finding the only move")
+	}
+	tm.Update(findMove(t, cb, "h1g1"), 0)
+	if !tm.ShouldStop(time.Millisecond) {
+		t.Errorf("kept searching with a single legal move")
+	}
+}
+
+func TestTimeManagerStopsEarlyOnAnObviousRecapture(t *testing.T) {
+	cb := newTestBoard(t, "4k3/8/8/4p3/3N4/8/8/3QK3 b - - 0 1")
+	if err := cb.MakeMove(findMove(t, cb, "e5d4")); err != nil {
+		t.Fatalf("failed to make move: %v", err)
+	}
+	recapture := findMove(t, cb, "d1d4")
+	quiet := findMove(t, cb, "d1d3")
+
+	tm := NewTimeManager(0)
+	tm.Start(cb, SearchLimits{WhiteTime: 60 * time.Second})
+	soft := tm.SoftLimit()
+	for i := 0; i < obviousMoveIterations; i++ {
+		if tm.ShouldStop(soft / obviousMoveFraction) {
+			t.Fatalf("stopped after %d iterations", i)
+		}
+		tm.Update(recapture, 300)
+	}
+	if !tm.ShouldStop(soft / obviousMoveFraction) {
+		t.Errorf("kept searching an obvious recapture")
+	}
+
+	tm.Start(cb, SearchLimits{WhiteTime: 60 * time.Second})
+	for i := 0; i < 2*obviousMoveIterations; i++ {
+		tm.Update(quiet, 0)
+	}
+	if tm.ShouldStop(soft / obviousMoveFraction) {
+		t.Errorf("stopped early on a move that is not a recapture")
+	}
+}
+
+// timeControl describes a clock for simulated games. Sessions of
+// movesPerSession moves each add base to the clock; zero means the whole
+// game is one session.
+type timeControl struct {
+	name            string
+	base            time.Duration
+	increment       time.Duration
+	movesPerSession int
+	// overhead is the time lost per move outside the search.
+	overhead time.Duration
+}
+
+// TestTimeManagerPlaysFullGames plays long games under several time
+// controls, with each iteration taking twice as long as the last and the
+// best move sometimes changing, and checks that the engine never loses on
+// time yet uses a fair share of its clock.
+func TestTimeManagerPlaysFullGames(t *testing.T) {
+	controls := []timeControl{
+		{name: "sudden death", base: 60 * time.Second, overhead: 5 * time.Millisecond},
+		{name: "bullet", base: time.Second, overhead: 2 * time.Millisecond},
+		{name: "increment", base: 3 * time.Minute, increment: 2 * time.Second, overhead: 10 * time.Millisecond},
+		{name: "fast increment", base: 10 * time.Second, increment: 100 * time.Millisecond, overhead: 10 * time.Millisecond},
+		{name: "moves per session", base: 40 * time.Second, movesPerSession: 40, overhead: 10 * time.Millisecond},
+		{name: "move per session", base: time.Second, movesPerSession: 1, overhead: 10 * time.Millisecond},
+	}
+	const gameLength = 150
+
+	for _, control := range controls {
+		t.Run(control.name, func(t *testing.T) {
+			cb := newTestBoard(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+			shuffle := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+			tm := NewTimeManager(DefaultMoveOverhead)
+			clocks := map[board.Color]time.Duration{board.White: control.base, board.Black: control.base}
+			used := map[board.Color]time.Duration{}
+			available := map[board.Color]time.Duration{board.White: control.base, board.Black: control.base}
+
+			for ply := 0; ply < 2*gameLength; ply++ {
+				side := cb.SideToMove()
+				moveNumber := ply / 2
+				limits := SearchLimits{
+					WhiteTime:      clocks[board.White],
+					BlackTime:      clocks[board.Black],
+					WhiteIncrement: control.increment,
+					BlackIncrement: control.increment,
+				}
+				if control.movesPerSession > 0 {
+					limits.MovesToGo = control.movesPerSession - moveNumber%control.movesPerSession
+				}
+
+				elapsed := simulateSearch(t, cb, tm, limits, moveNumber)
+				if ply < 2 && limits.MovesToGo != 1 && elapsed > clocks[side]/4 {
+					t.Errorf("spent %v of %v on the first move", elapsed, clocks[side])
+				}
+				clocks[side] -= elapsed + control.overhead
+				used[side] += elapsed + control.overhead
+				if clocks[side] < 0 {
+					t.Fatalf("%s lost on time at move %d", colorString(side), moveNumber+1)
+				}
+				clocks[side] += control.increment
+				available[side] += control.increment
+				if control.movesPerSession > 0 && (moveNumber+1)%control.movesPerSession == 0 {
+					clocks[side] += control.base
+					available[side] += control.base
+				}
+
+				if err := cb.MakeMove(findMove(t, cb, shuffle[ply%len(shuffle)])); err != nil {
+					t.Fatalf("failed to make move: %v", err)
+				}
+			}
+
+			for _, side := range []board.Color{board.White, board.Black} {
+				if used[side] < available[side]/2 {
+					t.Errorf("%s used only %v of %v", colorString(side), used[side], available[side])
+				}
+			}
+		})
+	}
+}
+
+// simulateSearch runs iterations that double in length until the time
+// manager stops the search or the hard limit interrupts it, and returns
+// the time spent.
+func simulateSearch(t *testing.T, cb board.ChessBoard, tm *TimeManager, limits SearchLimits, moveNumber int) time.Duration {
+	t.Helper()
+	tm.Start(cb, limits)
+	hard, ok := tm.HardLimit()
+	if !ok {
+		t.Fatalf("expected a timed search for %+v", limits)
+	}
+
+	moves := cb.GenerateLegalMoves()
+	elapsed := time.Duration(0)
+	iteration := 50 * time.Microsecond
+	for depth := 1; depth < MaxPly; depth++ {
+		if depth > 1 && elapsed+iteration > hard {
+			return max(hard, elapsed)
+		}
+		elapsed += iteration
+		iteration *= 2
+
+		// Every third game move the best move changes and the score drops
+		best, score := moves[0], 0
+		if moveNumber%3 == 0 && depth > 4 {
+			best, score = moves[depth%len(moves)], -10*depth
+		}
+		tm.Update(best, score)
+		if tm.ShouldStop(elapsed) {
+			break
+		}
+	}
+	return elapsed
+}
+
+func colorString(color board.Color) string {
+	if color == board.White {
+		return "white"
+	}
+	return "black"
+}
@@ -0,0 +1,205 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	board "jesus_chess/domain/board"
+)
+
+// PruneReason says why a node was settled without searching all its moves,
+// or why a move was not searched at all.
+type PruneReason string
+
+const (
+	TTCutoff        PruneReason = "tt cutoff"
+	ReverseFutility PruneReason = "reverse futility"
+	Razoring        PruneReason = "razoring"
+	NullMoveCutoff  PruneReason = "null move"
+	MultiCut        PruneReason = "multi-cut"
+	BetaCutoff      PruneReason = "beta cutoff"
+	LateMovePruned  PruneReason = "late move pruning"
+	FutilityPruned  PruneReason = "futility"
+	DrawnPosition   PruneReason = "draw"
+	// QuiescenceLeaf marks the nodes left to the quiescence search, whose
+	// own nodes are not traced.
+	QuiescenceLeaf PruneReason = "quiescence"
+	Stopped        PruneReason = "stopped"
+)
+
+// TraceNode is a call of the search on a position. A move that was pruned
+// without being searched is a node with a reason but no score.
+type TraceNode struct {
+	// Move is the move that led to the node in UCI notation, "null" for a
+	// null move and empty for the root
+	Move     string       `json:"move"`
+	Ply      int          `json:"ply"`
+	Depth    int          `json:"depth"`
+	Alpha    int          `json:"alpha"`
+	Beta     int          `json:"beta"`
+	Score    *int         `json:"score,omitempty"`
+	Reason   PruneReason  `json:"reason,omitempty"`
+	Children []*TraceNode `json:"children,omitempty"`
+}
+
+// Tracer records the tree an AlphaBetaMoveFinder explores, to see why a
+// move was pruned. Every search of the root, one per iteration and per
+// aspiration window or MultiPV line, is a root of the trace, and the
+// searches of a node's moves, including reduced searches and re-searches,
+// are its children. Nodes deeper than maxPly and those beyond the first
+// maxNodes are searched but not recorded.
+type Tracer struct {
+	maxPly   int
+	maxNodes int
+
+	roots    []*TraceNode
+	recorded int
+	// truncated is set once a node is left out for the budget
+	truncated bool
+	// stack holds the nodes being searched, nil for those not recorded
+	stack []*TraceNode
+}
+
+func NewTracer(maxPly, maxNodes int) *Tracer {
+	return &Tracer{maxPly: maxPly, maxNodes: maxNodes}
+}
+
+// Reset drops the recorded tree.
+func (t *Tracer) Reset() {
+	t.roots = nil
+	t.recorded = 0
+	t.truncated = false
+	t.stack = t.stack[:0]
+}
+
+// Roots returns the recorded searches of the root.
+func (t *Tracer) Roots() []*TraceNode {
+	return t.roots
+}
+
+// Truncated reports whether the budget left nodes out of the trace.
+func (t *Tracer) Truncated() bool {
+	return t.truncated
+}
+
+// add records node below the node being searched, if the budget allows.
+func (t *Tracer) add(node *TraceNode) bool {
+	var parent *TraceNode
+	if len(t.stack) > 0 {
+		if parent = t.stack[len(t.stack)-1]; parent == nil {
+			return false
+		}
+	}
+	if node.Ply > t.maxPly || t.recorded >= t.maxNodes {
+		t.truncated = true
+		return false
+	}
+	t.recorded++
+	if parent == nil {
+		t.roots = append(t.roots, node)
+	} else {
+		parent.Children = append(parent.Children, node)
+	}
+	return true
+}
+
+// enter starts searching the node at ply reached by move, which is empty
+// for the root.
+func (t *Tracer) enter(move string, ply, depth, alpha, beta int) {
+	node := &TraceNode{Move: move, Ply: ply, Depth: depth, Alpha: alpha, Beta: beta}
+	if !t.add(node) {
+		node = nil
+	}
+	t.stack = append(t.stack, node)
+}
+
+// exit ends the search of the current node with score.
+func (t *Tracer) exit(score int) {
+	node := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	if node != nil {
+		node.Score = &score
+	}
+}
+
+// prune gives the reason the current node was settled early.
+func (t *Tracer) prune(reason PruneReason) {
+	if node := t.stack[len(t.stack)-1]; node != nil && node.Reason == "" {
+		node.Reason = reason
+	}
+}
+
+// skip records that move was not searched from the current node.
+func (t *Tracer) skip(move string, ply, depth, alpha, beta int, reason PruneReason) {
+	t.add(&TraceNode{Move: move, Ply: ply, Depth: depth, Alpha: alpha, Beta: beta, Reason: reason})
+}
+
+// WriteJSON writes the trace as a JSON object holding its roots.
+func (t *Tracer) WriteJSON(w io.Writer) error {
+	roots := t.roots
+	if roots == nil {
+		roots = []*TraceNode{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Truncated bool         `json:"truncated"`
+		Roots     []*TraceNode `json:"roots"`
+	}{t.truncated, roots})
+}
+
+// WriteDOT writes the trace as a Graphviz digraph. Each node is labelled
+// with its move, depth, window and score, and pruned nodes are drawn in
+// red with their reason.
+func (t *Tracer) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph search {\n\tnode [shape=box, fontname=monospace];\n")
+	id := 0
+	var write func(node *TraceNode) int
+	write = func(node *TraceNode) int {
+		nodeID := id
+		id++
+		move := node.Move
+		if move == "" {
+			move = "root"
+		}
+		label := fmt.Sprintf("%s\\nd=%d [%d, %d]", move, node.Depth, node.Alpha, node.Beta)
+		if node.Score != nil {
+			label += fmt.Sprintf("\\nscore %d", *node.Score)
+		}
+		attributes := ""
+		switch node.Reason {
+		case "":
+		case BetaCutoff, QuiescenceLeaf:
+			label += "\\n" + string(node.Reason)
+		default:
+			label += "\\n" + string(node.Reason)
+			attributes = ", color=red"
+			if node.Score == nil {
+				attributes += ", style=dashed"
+			}
+		}
+		fmt.Fprintf(&sb, "\tn%d [label=\"%s\"%s];\n", nodeID, label, attributes)
+		for _, child := range node.Children {
+			fmt.Fprintf(&sb, "\tn%d -> n%d;\n", nodeID, write(child))
+		}
+		return nodeID
+	}
+	for _, root := range t.roots {
+		write(root)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// traceMove formats move in UCI notation.
+func traceMove(move board.Move) string {
+	s := fmt.Sprintf("%c%c%c%c", 'a'+move.From.File, '1'+move.From.Rank, 'a'+move.To.File, '1'+move.To.Rank)
+	if move.Promotion != nil {
+		s += strings.ToLower(string(move.Promotion.Name))
+	}
+	return s
+}
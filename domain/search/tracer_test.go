@@ -0,0 +1,179 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const tracedFEN = "r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4"
+
+// walkTrace calls visit on every node of the trace below parent.
+func walkTrace(parent *TraceNode, nodes []*TraceNode, visit func(parent, node *TraceNode)) {
+	for _, node := range nodes {
+		visit(parent, node)
+		walkTrace(node, node.Children, visit)
+	}
+}
+
+func TestTracerRecordsTheTree(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 5)
+	untraced, err := finder.FindBestMove(context.Background(), newTestBoard(t, tracedFEN), SearchLimits{Depth: 5})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	finder.NewGame()
+	tracer := NewTracer(MaxPly, 1<<20)
+	finder.SetTracer(tracer)
+	traced, err := finder.FindBestMove(context.Background(), newTestBoard(t, tracedFEN), SearchLimits{Depth: 5})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !traced.BestMove.Equal(untraced.BestMove) || traced.Score != untraced.Score {
+		t.Errorf("expected tracing not to change the search, got %+v and %+v", traced, untraced)
+	}
+
+	if tracer.Truncated() {
+		t.Fatalf("expected the whole tree within the budget")
+	}
+	if len(tracer.Roots()) < 5 {
+		t.Fatalf("expected a root for every iteration, got %d", len(tracer.Roots()))
+	}
+	reasons := make(map[PruneReason]int)
+	nodes := len(tracer.Roots())
+	walkTrace(nil, tracer.Roots(), func(parent, node *TraceNode) {
+		reasons[node.Reason]++
+		if parent == nil {
+			return
+		}
+		nodes++
+		if node.Ply != parent.Ply+1 && node.Ply != parent.Ply {
+			t.Errorf("expected a child of a node at ply %d one ply deeper or at the same ply, got %d", parent.Ply, node.Ply)
+		}
+		if node.Score == nil && node.Reason != LateMovePruned && node.Reason != FutilityPruned {
+			t.Errorf("expected only pruned moves without a score, got %+v", node)
+		}
+	})
+	t.Logf("%d nodes traced, reasons %v", nodes, reasons)
+	for _, reason := range []PruneReason{BetaCutoff, TTCutoff, NullMoveCutoff, QuiescenceLeaf} {
+		if reasons[reason] == 0 {
+			t.Errorf("expected nodes settled by %s", reason)
+		}
+	}
+	if reasons[LateMovePruned]+reasons[FutilityPruned] == 0 {
+		t.Errorf("expected moves pruned without being searched")
+	}
+
+	finder.SetTracer(nil)
+	if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, tracedFEN), SearchLimits{Depth: 2}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(tracer.Roots()) < 5 {
+		t.Errorf("expected a removed tracer to keep its trace, got %d roots", len(tracer.Roots()))
+	}
+}
+
+func TestTracerBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPly   int
+		maxNodes int
+	}{
+		{"depth", 2, 1 << 20},
+		{"nodes", MaxPly, 300},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finder := newTestAlphaBetaMoveFinder(t, 4)
+			tracer := NewTracer(test.maxPly, test.maxNodes)
+			finder.SetTracer(tracer)
+			if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, tracedFEN), SearchLimits{Depth: 4}); err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+			if !tracer.Truncated() {
+				t.Errorf("expected the budget to truncate the trace")
+			}
+			nodes := 0
+			walkTrace(nil, tracer.Roots(), func(parent, node *TraceNode) {
+				nodes++
+				if node.Ply > test.maxPly {
+					t.Errorf("expected no node beyond ply %d, got one at %d", test.maxPly, node.Ply)
+				}
+			})
+			if nodes > test.maxNodes {
+				t.Errorf("expected at most %d nodes, got %d", test.maxNodes, nodes)
+			}
+		})
+	}
+}
+
+func TestTracerExports(t *testing.T) {
+	finder := newTestAlphaBetaMoveFinder(t, 3)
+	tracer := NewTracer(MaxPly, 200)
+	finder.SetTracer(tracer)
+	if _, err := finder.FindBestMove(context.Background(), newTestBoard(t, tracedFEN), SearchLimits{Depth: 3}); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	nodes := 0
+	walkTrace(nil, tracer.Roots(), func(parent, node *TraceNode) {
+		nodes++
+	})
+
+	var buf bytes.Buffer
+	if err := tracer.WriteJSON(&buf); err != nil {
+		t.Fatalf("failed to write JSON: %v", err)
+	}
+	var decoded struct {
+		Truncated bool         `json:"truncated"`
+		Roots     []*TraceNode `json:"roots"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode the trace: %v", err)
+	}
+	if !decoded.Truncated || len(decoded.Roots) != len(tracer.Roots()) || decoded.Roots[0].Children[0].Move == "" {
+		t.Errorf("expected the trace back, got %d roots", len(decoded.Roots))
+	}
+
+	buf.Reset()
+	if err := tracer.WriteDOT(&buf); err != nil {
+		t.Fatalf("failed to write DOT: %v", err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph search {") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("expected a digraph, got %q", dot)
+	}
+	if labels, edges := strings.Count(dot, "[label="), strings.Count(dot, " -> "); labels != nodes || edges != nodes-len(tracer.Roots()) {
+		t.Errorf("expected %d nodes and %d edges, got %d and %d", nodes, nodes-len(tracer.Roots()), labels, edges)
+	}
+}
+
+// BenchmarkTracing compares the node throughput of a search with and
+// without a tracer, whose cost an untraced search should not pay.
+func BenchmarkTracing(b *testing.B) {
+	for _, traced := range []bool{false, true} {
+		name := "untraced"
+		if traced {
+			name = "traced"
+		}
+		b.Run(name, func(b *testing.B) {
+			finder := newTestAlphaBetaMoveFinder(b, 5)
+			cb := newTestBoard(b, tracedFEN)
+			nodes := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				finder.NewGame()
+				if traced {
+					finder.SetTracer(NewTracer(MaxPly, 1<<20))
+				}
+				if _, err := finder.FindBestMove(context.Background(), cb, SearchLimits{Depth: 5}); err != nil {
+					b.Fatalf("search failed: %v", err)
+				}
+				nodes += finder.nodes
+			}
+			b.ReportMetric(float64(nodes)/b.Elapsed().Seconds(), "nodes/s")
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package search
+
+import (
+	"sync/atomic"
+
+	board "jesus_chess/domain/board"
+)
+
+const (
+	// DefaultHashSize is the default transposition table size in megabytes.
+	DefaultHashSize = 16
+	// MaxHashSize bounds the Hash option, in megabytes.
+	MaxHashSize = 1024
+
+	// bucketSize is the number of entries sharing one index.
+	bucketSize = 4
+	// entrySize is the size of a ttEntry in bytes.
+	entrySize = 16
+)
+
+// Bound tells how a stored score relates to the true score of a position.
+type Bound uint8
+
+const (
+	// NoBound marks an empty entry.
+	NoBound Bound = iota
+	// ExactBound scores are exact: the search raised alpha without failing
+	// high.
+	ExactBound
+	// LowerBound scores failed high; the true score is at least this.
+	LowerBound
+	// UpperBound scores failed low; the true score is at most this.
+	UpperBound
+)
+
+// TTEntry is what the transposition table knows about a position.
+type TTEntry struct {
+	// Move is the best move found if HasMove is set. Only its squares and
+	// promotion are filled in.
+	Move    board.Move
+	HasMove bool
+	Score   int
+	Depth   int
+	Bound   Bound
+}
+
+// ttEntry packs an entry into two 8-byte words that search threads read
+// and write atomically, without locks. The full key is kept so that
+// positions sharing a bucket are told apart, xored with the data so that
+// an entry torn by two threads writing it at once fails the key check and
+// reads as empty.
+type ttEntry struct {
+	check atomic.Uint64
+	data  atomic.Uint64
+}
+
+// ttData is the unpacked data word of an entry.
+type ttData struct {
+	move  uint16
+	score int16
+	depth int8
+	bound Bound
+	age   uint8
+}
+
+func (d ttData) pack() uint64 {
+	return uint64(d.move) | uint64(uint16(d.score))<<16 | uint64(uint8(d.depth))<<32 | uint64(d.bound)<<40 | uint64(d.age)<<48
+}
+
+func unpackData(data uint64) ttData {
+	return ttData{
+		move:  uint16(data),
+		score: int16(data >> 16),
+		depth: int8(data >> 32),
+		bound: Bound(data >> 40),
+		age:   uint8(data >> 48),
+	}
+}
+
+// load returns the entry's data if it holds key.
+func (e *ttEntry) load(key uint64) (ttData, bool) {
+	data := e.data.Load()
+	if e.check.Load()^data != key {
+		return ttData{}, false
+	}
+	entry := unpackData(data)
+	return entry, entry.bound != NoBound
+}
+
+func (e *ttEntry) store(key uint64, entry ttData) {
+	data := entry.pack()
+	e.check.Store(key ^ data)
+	e.data.Store(data)
+}
+
+// TranspositionTable caches search results by Zobrist hash. Entries are
+// grouped in buckets; a new entry replaces the same position if present
+// and otherwise the entry that is oldest and shallowest. Probe and Store
+// may be called from several search threads at once; the other methods
+// must not run during a search.
+type TranspositionTable struct {
+	entries []ttEntry
+	// mask selects a bucket; the bucket count is a power of two
+	mask uint64
+	age  uint8
+}
+
+// NewTranspositionTable returns a table of sizeMB megabytes.
+func NewTranspositionTable(sizeMB int) *TranspositionTable {
+	tt := &TranspositionTable{}
+	tt.Resize(sizeMB)
+	return tt
+}
+
+// Resize reallocates the table to sizeMB megabytes, discarding its
+// contents. The size is rounded down to a power of two number of buckets.
+func (tt *TranspositionTable) Resize(sizeMB int) {
+	buckets := uint64(max(sizeMB, 1)) << 20 / (bucketSize * entrySize)
+	for buckets&(buckets-1) != 0 {
+		buckets &= buckets - 1
+	}
+	tt.entries = make([]ttEntry, buckets*bucketSize)
+	tt.mask = buckets - 1
+	tt.age = 0
+}
+
+// Size returns the memory the table takes in bytes.
+func (tt *TranspositionTable) Size() int {
+	return len(tt.entries) * entrySize
+}
+
+// Clear empties the table.
+func (tt *TranspositionTable) Clear() {
+	for i := range tt.entries {
+		tt.entries[i].store(0, ttData{})
+	}
+	tt.age = 0
+}
+
+// NewSearch ages the table so that entries from earlier searches are
+// replaced first.
+func (tt *TranspositionTable) NewSearch() {
+	tt.age++
+}
+
+func (tt *TranspositionTable) bucket(key uint64) []ttEntry {
+	start := (key & tt.mask) * bucketSize
+	return tt.entries[start : start+bucketSize]
+}
+
+// Probe looks key up. Scores are returned relative to ply, the distance of
+// the probing node from the root.
+func (tt *TranspositionTable) Probe(key uint64, ply int) (TTEntry, bool) {
+	bucket := tt.bucket(key)
+	for i := range bucket {
+		if entry, ok := bucket[i].load(key); ok {
+			if entry.age != tt.age {
+				// Refresh the entry so that it survives this search
+				entry.age = tt.age
+				bucket[i].store(key, entry)
+			}
+			return TTEntry{
+				Move:    unpackMove(entry.move),
+				HasMove: entry.move != 0,
+				Score:   scoreFromTT(int(entry.score), ply),
+				Depth:   int(entry.depth),
+				Bound:   entry.bound,
+			}, true
+		}
+	}
+	return TTEntry{}, false
+}
+
+// Store records a search result for key, found ply moves from the root.
+// move may be nil, in which case a move already stored for the position is
+// kept.
+func (tt *TranspositionTable) Store(key uint64, move *board.Move, score, depth int, bound Bound, ply int) {
+	bucket := tt.bucket(key)
+	replace := &bucket[0]
+	replaceValue := 0
+	var existing ttData
+	found := false
+	for i := range bucket {
+		if entry, ok := bucket[i].load(key); ok {
+			replace, existing, found = &bucket[i], entry, true
+			break
+		}
+		entry := unpackData(bucket[i].data.Load())
+		if entry.bound == NoBound {
+			replace = &bucket[i]
+			break
+		}
+		if value := tt.replacementValue(entry); i == 0 || value < replaceValue {
+			replace, replaceValue = &bucket[i], value
+		}
+	}
+
+	packed := packMove(move)
+	if move == nil && found {
+		packed = existing.move
+	}
+	replace.store(key, ttData{
+		move:  packed,
+		score: int16(scoreToTT(score, ply)),
+		depth: int8(max(min(depth, MaxPly-1), -1)),
+		bound: bound,
+		age:   tt.age,
+	})
+}
+
+// replacementValue ranks entries for replacement: the lowest is replaced
+// first. Every search of age counts as much as eight plies of depth.
+func (tt *TranspositionTable) replacementValue(entry ttData) int {
+	return int(entry.depth) - 8*int(tt.age-entry.age)
+}
+
+// HashFull returns how full the table is in permille, counting entries
+// written in the current search among the first thousand.
+func (tt *TranspositionTable) HashFull() int {
+	sample := min(1000, len(tt.entries))
+	used := 0
+	for i := 0; i < sample; i++ {
+		if entry := unpackData(tt.entries[i].data.Load()); entry.bound != NoBound && entry.age == tt.age {
+			used++
+		}
+	}
+	return used * 1000 / sample
+}
+
+// scoreToTT makes mate scores relative to the node being stored rather
+// than to the root, so that they stay correct when the position is reached
+// at another ply.
+func scoreToTT(score, ply int) int {
+	switch {
+	case score >= MateScore-MaxPly:
+		return score + ply
+	case score <= -MateScore+MaxPly:
+		return score - ply
+	}
+	return score
+}
+
+// scoreFromTT is the inverse of scoreToTT.
+func scoreFromTT(score, ply int) int {
+	switch {
+	case score >= MateScore-MaxPly:
+		return score - ply
+	case score <= -MateScore+MaxPly:
+		return score + ply
+	}
+	return score
+}
+
+// promotionPieces is indexed by the promotion code of a packed move.
+var promotionPieces = [...]board.Piece{{}, {Name: board.Knight}, {Name: board.Bishop}, {Name: board.Rook}, {Name: board.Queen}}
+
+// packMove encodes a move's squares and promotion in 15 bits; zero means
+// no move.
+func packMove(move *board.Move) uint16 {
+	if move == nil {
+		return 0
+	}
+	packed := uint16(move.From.Index()) | uint16(move.To.Index())<<6
+	if move.Promotion != nil {
+		for code := 1; code < len(promotionPieces); code++ {
+			if promotionPieces[code].Name == move.Promotion.Name {
+				packed |= uint16(code) << 12
+			}
+		}
+	}
+	return packed
+}
+
+func unpackMove(packed uint16) board.Move {
+	move := board.Move{
+		From: board.Square{Rank: int(packed>>3) & 7, File: int(packed) & 7},
+		To:   board.Square{Rank: int(packed>>9) & 7, File: int(packed>>6) & 7},
+	}
+	if code := packed >> 12 & 7; code != 0 {
+		move.Promotion = &promotionPieces[code]
+	}
+	return move
+}
@@ -0,0 +1,187 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestTranspositionTableStoresAndProbes(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	cb := newTestBoard(t, "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1")
+
+	for _, move := range cb.GenerateLegalMoves() {
+		tt.Store(1, &move, -45, 7, LowerBound, 3)
+		entry, ok := tt.Probe(1, 3)
+		if !ok {
+			t.Fatalf("stored entry not found")
+		}
+		if !entry.HasMove || !entry.Move.Equal(move) {
+			t.Errorf("expected move %v, got %v", move, entry.Move)
+		}
+		if entry.Score != -45 || entry.Depth != 7 || entry.Bound != LowerBound {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+	}
+
+	if _, ok := tt.Probe(2, 0); ok {
+		t.Errorf("found an entry that was never stored")
+	}
+
+	tt.Store(1, nil, 10, 8, UpperBound, 0)
+	if entry, _ := tt.Probe(1, 0); !entry.HasMove {
+		t.Errorf("expected a store without a move to keep the previous move")
+	}
+
+	tt.Clear()
+	if _, ok := tt.Probe(1, 0); ok {
+		t.Errorf("found an entry after clearing")
+	}
+}
+
+func TestTranspositionTableAdjustsMateScores(t *testing.T) {
+	tt := NewTranspositionTable(1)
+
+	// Mate in 3 plies from a node 5 plies deep is mate in 8 from the root
+	tt.Store(1, nil, mateIn(8), 3, ExactBound, 5)
+	entry, _ := tt.Probe(1, 2)
+	if entry.Score != mateIn(5) {
+		t.Errorf("expected mate in 5 plies when probed at ply 2, got %d", entry.Score)
+	}
+
+	tt.Store(2, nil, matedIn(6), 3, ExactBound, 4)
+	entry, _ = tt.Probe(2, 1)
+	if entry.Score != matedIn(3) {
+		t.Errorf("expected mated in 3 plies when probed at ply 1, got %d", entry.Score)
+	}
+
+	tt.Store(3, nil, 250, 3, ExactBound, 9)
+	if entry, _ := tt.Probe(3, 0); entry.Score != 250 {
+		t.Errorf("expected an ordinary score to be unchanged, got %d", entry.Score)
+	}
+}
+
+func TestTranspositionTableReplacesOldAndShallowEntries(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	buckets := uint64(len(tt.entries) / bucketSize)
+
+	// Keys that are equal modulo the bucket count share a bucket
+	for i := uint64(0); i < bucketSize; i++ {
+		tt.Store(i*buckets, nil, 0, int(10+i), ExactBound, 0)
+	}
+	tt.Store(bucketSize*buckets, nil, 0, 20, ExactBound, 0)
+	if _, ok := tt.Probe(0, 0); ok {
+		t.Errorf("expected the shallowest entry to be replaced")
+	}
+	for i := uint64(1); i <= bucketSize; i++ {
+		if _, ok := tt.Probe(i*buckets, 0); !ok {
+			t.Errorf("expected entry %d to survive", i)
+		}
+	}
+
+	// A deep entry from an old search gives way to a shallow new one
+	for i := 0; i < 4; i++ {
+		tt.NewSearch()
+	}
+	for i := uint64(2); i <= bucketSize; i++ {
+		tt.Probe(i*buckets, 0)
+	}
+	tt.Store((bucketSize+1)*buckets, nil, 0, 1, ExactBound, 0)
+	if _, ok := tt.Probe(buckets, 0); ok {
+		t.Errorf("expected the entry from an old search to be replaced")
+	}
+}
+
+func TestTranspositionTableHashFull(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	if tt.HashFull() != 0 {
+		t.Errorf("expected an empty table, got %d", tt.HashFull())
+	}
+	for key := uint64(0); key < uint64(len(tt.entries)); key++ {
+		tt.Store(key, nil, 0, 1, ExactBound, 0)
+	}
+	if full := tt.HashFull(); full < 900 {
+		t.Errorf("expected a nearly full table, got %d", full)
+	}
+	tt.NewSearch()
+	if tt.HashFull() != 0 {
+		t.Errorf("expected entries from an earlier search not to count, got %d", tt.HashFull())
+	}
+}
+
+func TestTranspositionTableResize(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	tt.Store(1, nil, 0, 1, ExactBound, 0)
+	tt.Resize(4)
+	if len(tt.entries)*entrySize != 4<<20 {
+		t.Errorf("expected 4MB of entries, got %d bytes", len(tt.entries)*entrySize)
+	}
+	if _, ok := tt.Probe(1, 0); ok {
+		t.Errorf("expected resizing to discard entries")
+	}
+}
+
+func TestAlphaBetaReusesTheTranspositionTable(t *testing.T) {
+	f := newTestAlphaBetaMoveFinder(t, 5)
+	cb := newTestBoard(t, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	var first, second SearchInfo
+	f.SetInfoCallback(func(info SearchInfo) { first = info })
+	if _, err := f.FindBestMove(context.Background(), cb, SearchLimits{Depth: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.SetInfoCallback(func(info SearchInfo) { second = info })
+	if _, err := f.FindBestMove(context.Background(), cb, SearchLimits{Depth: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if second.Nodes >= first.Nodes/2 {
+		t.Errorf("expected the second search to reuse the first, searched %d then %d nodes", first.Nodes, second.Nodes)
+	}
+	if second.Score != first.Score {
+		t.Errorf("expected the same score, got %d then %d", first.Score, second.Score)
+	}
+	if first.HashFull == 0 {
+		t.Errorf("expected hashfull to be reported")
+	}
+
+	f.NewGame()
+	var third SearchInfo
+	f.SetInfoCallback(func(info SearchInfo) { third = info })
+	if _, err := f.FindBestMove(context.Background(), cb, SearchLimits{Depth: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if third.Nodes != first.Nodes {
+		t.Errorf("expected a new game to search from scratch, searched %d then %d nodes", first.Nodes, third.Nodes)
+	}
+}
+
+// TestTranspositionTableIsSafeForConcurrentUse has threads store and probe
+// overlapping keys in a tiny table, where entries are overwritten all the
+// time. Every hit must be an entry stored for the probed key, never a mix
+// of two entries.
+func TestTranspositionTableIsSafeForConcurrentUse(t *testing.T) {
+	tt := NewTranspositionTable(1)
+	buckets := uint64(len(tt.entries) / bucketSize)
+
+	var wg sync.WaitGroup
+	for thread := 0; thread < 8; thread++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20000; i++ {
+				// Keys share a handful of buckets; each stores a score and
+				// depth derived from the key
+				key := uint64(i%64)*buckets + uint64(thread%2)
+				tt.Store(key, nil, int(key%1000), int(key%32), ExactBound, 0)
+				if entry, ok := tt.Probe(key^buckets, 0); ok {
+					if entry.Score != int((key^buckets)%1000) || entry.Depth != int((key^buckets)%32) {
+						t.Errorf("probed key %d got a torn entry %+v", key^buckets, entry)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
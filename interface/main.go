@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	board "jesus_chess/domain/board"
 	logging "jesus_chess/domain/logging"
@@ -11,6 +12,11 @@ import (
 )
 
 func main() {
+	finder := flag.String("finder", "alphabeta", "move finder to play with: alphabeta, mcts or random")
+	depth := flag.Int("depth", 4, "search depth in plies for the alphabeta finder")
+	seed := flag.Int64("seed", 0, "seed for the random and mcts finders; 0 takes one from the clock")
+	flag.Parse()
+
 	logger, err := logging.NewLogger("engine.log")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to open log file: %v\n", err)
@@ -18,8 +24,21 @@ func main() {
 	}
 	defer logger.Close()
 
+	var moveFinder search.MoveFinder
+	switch *finder {
+	case "alphabeta":
+		moveFinder = search.NewAlphaBetaMoveFinder(logger, *depth)
+	case "mcts":
+		moveFinder = search.NewMCTSMoveFinder(logger, *seed)
+	case "random":
+		moveFinder = search.NewRandomMoveFinder(logger, *seed)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown move finder: %s\n", *finder)
+		os.Exit(1)
+	}
+	logger.Info("using move finder: " + *finder)
+
 	board := board.NewArrayChessBoard(logger)
-	moveFinder := search.NewRandomMoveFinder(logger)
 	handler := uci.NewUCIHandler(logger, board, moveFinder)
 
 	scanner := bufio.NewScanner(os.Stdin)
@@ -1,26 +1,53 @@
 package uci
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	board "jesus_chess/domain/board"
 	logging "jesus_chess/domain/logging"
+	render "jesus_chess/domain/render"
 	search "jesus_chess/domain/search"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type UCIHandler struct {
 	logger     *logging.Logger
 	board      board.ChessBoard
 	moveFinder search.MoveFinder
+
+	output      io.Writer
+	outputMutex sync.Mutex
+
+	// cancelSearch and searchDone are set while a search is running
+	cancelSearch context.CancelFunc
+	searchDone   chan struct{}
+	// ponderHit is set while a search ponders, and closed on ponderhit
+	ponderHit chan struct{}
 }
 
 func NewUCIHandler(logger *logging.Logger, board board.ChessBoard, moveFinder search.MoveFinder) *UCIHandler {
-	return &UCIHandler{
+	h := &UCIHandler{
 		logger:     logger,
 		board:      board,
 		moveFinder: moveFinder,
+		output:     os.Stdout,
+	}
+	if reporter, ok := moveFinder.(search.InfoReporter); ok {
+		reporter.SetInfoCallback(func(info search.SearchInfo) {
+			h.respond(formatInfo(info))
+			for _, rootMove := range info.RootMoves {
+				h.respond(formatRootMove(rootMove))
+			}
+		})
 	}
+	return h
 }
 
 func (h *UCIHandler) Handle(command string) {
@@ -33,15 +60,43 @@ func (h *UCIHandler) Handle(command string) {
 	case "uci":
 		h.respond("id name JesusChess")
 		h.respond("id author Issa Memari")
+		if configurable, ok := h.moveFinder.(search.Configurable); ok {
+			for _, option := range configurable.Options() {
+				h.respond(formatOption(option))
+			}
+		}
 		h.respond("uciok")
 
+	case "setoption":
+		name, value, err := parseSetOptionCommand(tokens)
+		if err != nil {
+			h.logger.Error("failed to parse setoption command: " + err.Error())
+			return
+		}
+		configurable, ok := h.moveFinder.(search.Configurable)
+		if !ok {
+			h.logger.Error("move finder has no options: " + name)
+			return
+		}
+		h.stopSearch()
+		if err := configurable.SetOption(name, value); err != nil {
+			h.logger.Error("failed to set option: " + err.Error())
+			return
+		}
+		h.logger.Debug("option set: " + name + " = " + value)
+
 	case "isready":
 		h.respond("readyok")
 
 	case "ucinewgame":
+		h.stopSearch()
 		h.board = board.NewArrayChessBoard(h.logger)
+		if resetter, ok := h.moveFinder.(search.GameResetter); ok {
+			resetter.NewGame()
+		}
 
 	case "position":
+		h.stopSearch()
 		fen, moves, err := parsePositionCommand(tokens)
 		if err != nil {
 			h.logger.Error("failed to parse position command: " + err.Error())
@@ -65,27 +120,45 @@ func (h *UCIHandler) Handle(command string) {
 		}
 
 	case "go":
-		move, err := h.moveFinder.FindBestMove(h.board)
+		// The running search uses the board the search moves are checked on
+		h.stopSearch()
+		limits, err := parseGoCommand(tokens)
 		if err != nil {
-			h.logger.Error("failed to find best move: " + err.Error())
-			return
-		}
-		if move == nil {
-			h.logger.Error("no best move found")
+			h.logger.Error("failed to parse go command: " + err.Error())
+			h.respond("bestmove " + firstLegalMove(h.board))
 			return
 		}
-		moveString := moveToUCI(*move)
-		h.logger.Debug("best move found: " + moveString)
-		h.respond("info depth 1 multipv 1 score cp -27 pv " + moveString)
-		h.respond("bestmove " + moveString)
+		limits.SearchMoves = h.legalSearchMoves(limits.SearchMoves)
+		h.startSearch(limits)
+
+	case "d":
+		h.stopSearch()
+		h.respond(render.Text(h.board, render.Options{
+			Charset:        render.Unicode,
+			Coordinates:    true,
+			HighlightCheck: true,
+			FEN:            true,
+		}))
+
+	case "stats":
+		h.stopSearch()
+		h.reportStats(len(tokens) > 1 && tokens[1] == "json")
 
 	case "quit":
 		h.logger.Debug("quitting")
+		h.stopSearch()
 		os.Exit(0)
 
 	case "stop":
 		h.logger.Debug("stop command received")
-		h.respond("stop")
+		h.stopSearch()
+
+	case "ponderhit":
+		h.logger.Debug("ponderhit command received")
+		if h.ponderHit != nil {
+			close(h.ponderHit)
+			h.ponderHit = nil
+		}
 
 	default:
 		h.logger.Error("unknown command: " + command)
@@ -93,11 +166,241 @@ func (h *UCIHandler) Handle(command string) {
 	}
 }
 
+// startSearch runs the move finder in the background so that commands such
+// as stop are still read while it searches. The best move is reported when
+// the search ends, with the expected reply to ponder on when there is one.
+func (h *UCIHandler) startSearch(limits search.SearchLimits) {
+	h.stopSearch()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	h.cancelSearch = cancel
+	h.searchDone = done
+	if limits.Ponder {
+		h.ponderHit = make(chan struct{})
+		limits.PonderHit = h.ponderHit
+	}
+
+	go func() {
+		defer close(done)
+		result, err := h.moveFinder.FindBestMove(ctx, h.board, limits)
+		if err != nil {
+			// The GUI still needs a move, if only the first legal one
+			switch {
+			case errors.Is(err, search.ErrNoMateFound):
+				h.respond("info string no mate found")
+			case errors.Is(err, search.ErrMateSearchStopped):
+				h.respond("info string mate search stopped")
+			default:
+				h.logger.Error("failed to find best move: " + err.Error())
+			}
+			h.respond("bestmove " + firstLegalMove(h.board))
+			return
+		}
+		moveString := moveToUCI(result.BestMove)
+		h.logger.Debug("best move found: " + moveString)
+		if result.PonderMove != nil {
+			moveString += " ponder " + moveToUCI(*result.PonderMove)
+		}
+		h.respond("bestmove " + moveString)
+	}()
+}
+
+// stopSearch cancels the running search, if any, and waits for it to report
+// its best move.
+func (h *UCIHandler) stopSearch() {
+	if h.cancelSearch == nil {
+		return
+	}
+	h.cancelSearch()
+	<-h.searchDone
+	h.cancelSearch = nil
+	h.searchDone = nil
+	h.ponderHit = nil
+}
+
+// reportStats prints the statistics of the last search's iterations, one
+// info string line per iteration, or as a JSON array on one line.
+func (h *UCIHandler) reportStats(asJSON bool) {
+	reporter, ok := h.moveFinder.(search.StatsReporter)
+	if !ok {
+		h.respond("info string no statistics available")
+		return
+	}
+	stats := reporter.Stats()
+	if asJSON {
+		if stats == nil {
+			stats = []search.SearchStats{}
+		}
+		data, err := json.Marshal(stats)
+		if err != nil {
+			h.logger.Error("failed to encode statistics: " + err.Error())
+			return
+		}
+		h.respond(string(data))
+		return
+	}
+	for _, iteration := range stats {
+		h.respond("info string " + iteration.String())
+	}
+}
+
 func (h *UCIHandler) respond(s string) {
-	fmt.Println(s)
+	h.outputMutex.Lock()
+	fmt.Fprintln(h.output, s)
+	h.outputMutex.Unlock()
 	h.logger.Debug("engine responded: " + s)
 }
 
+// parseSetOptionCommand splits "setoption name <id> [value <x>]" into the
+// option name and value, both of which may contain spaces.
+func parseSetOptionCommand(tokens []string) (string, string, error) {
+	if len(tokens) < 3 || tokens[1] != "name" {
+		return "", "", fmt.Errorf("expected setoption name <id> [value <x>]")
+	}
+	nameEnd := len(tokens)
+	for i := 2; i < len(tokens); i++ {
+		if tokens[i] == "value" {
+			nameEnd = i
+			break
+		}
+	}
+	name := strings.Join(tokens[2:nameEnd], " ")
+	if name == "" {
+		return "", "", fmt.Errorf("missing option name")
+	}
+	value := ""
+	if nameEnd < len(tokens) {
+		value = strings.Join(tokens[nameEnd+1:], " ")
+	}
+	return name, value, nil
+}
+
+// formatOption describes option for the uci command.
+func formatOption(option search.Option) string {
+	switch option.Type {
+	case search.SpinOption:
+		return fmt.Sprintf("option name %s type spin default %s min %d max %d", option.Name, option.Default, option.Min, option.Max)
+	case search.CheckOption:
+		return fmt.Sprintf("option name %s type check default %s", option.Name, option.Default)
+	case search.ComboOption:
+		return fmt.Sprintf("option name %s type combo default %s var %s", option.Name, option.Default, strings.Join(option.Vars, " var "))
+	case search.StringOption:
+		if option.Default == "" {
+			return fmt.Sprintf("option name %s type string default <empty>", option.Name)
+		}
+	}
+	return fmt.Sprintf("option name %s type string default %s", option.Name, option.Default)
+}
+
+// parseGoCommand reads the search limits of a go command. Times are given
+// in milliseconds.
+func parseGoCommand(tokens []string) (search.SearchLimits, error) {
+	limits := search.SearchLimits{}
+	for i := 1; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "infinite":
+			limits.Infinite = true
+			continue
+		case "ponder":
+			limits.Ponder = true
+			continue
+		case "searchmoves":
+			// The moves run up to the next parameter, which is not a move
+			end := i + 1
+			for end < len(tokens) {
+				if _, err := parseMove(tokens[end]); err != nil {
+					break
+				}
+				end++
+			}
+			if end == i+1 {
+				return search.SearchLimits{}, fmt.Errorf("missing moves for searchmoves")
+			}
+			moves, err := parseMoves(tokens[i+1 : end])
+			if err != nil {
+				return search.SearchLimits{}, err
+			}
+			limits.SearchMoves = moves
+			i = end - 1
+			continue
+		case "depth", "nodes", "mate", "movestogo", "movetime", "wtime", "btime", "winc", "binc":
+		default:
+			return search.SearchLimits{}, fmt.Errorf("unknown go parameter: %s", tokens[i])
+		}
+
+		if i+1 >= len(tokens) {
+			return search.SearchLimits{}, fmt.Errorf("missing value for %s", tokens[i])
+		}
+		value, err := strconv.Atoi(tokens[i+1])
+		if err != nil {
+			return search.SearchLimits{}, fmt.Errorf("invalid value for %s: %s", tokens[i], tokens[i+1])
+		}
+		milliseconds := time.Duration(value) * time.Millisecond
+
+		switch tokens[i] {
+		case "depth":
+			limits.Depth = value
+		case "nodes":
+			limits.Nodes = value
+		case "mate":
+			limits.Mate = value
+		case "movestogo":
+			limits.MovesToGo = value
+		case "movetime":
+			limits.MoveTime = milliseconds
+		case "wtime":
+			limits.WhiteTime = milliseconds
+		case "btime":
+			limits.BlackTime = milliseconds
+		case "winc":
+			limits.WhiteIncrement = milliseconds
+		case "binc":
+			limits.BlackIncrement = milliseconds
+		}
+		i++
+	}
+	return limits, nil
+}
+
+// legalSearchMoves matches the searchmoves of a go command against the
+// legal moves of the position, which fill in what the UCI notation leaves
+// out. The moves that are not legal are dropped, so that the search still
+// answers with a legal move; if none is left, every move is searched.
+func (h *UCIHandler) legalSearchMoves(moves []board.Move) []board.Move {
+	if len(moves) == 0 {
+		return nil
+	}
+	legalMoves := h.board.GenerateLegalMoves()
+	var searchMoves []board.Move
+	for _, move := range moves {
+		legal := false
+		for _, legalMove := range legalMoves {
+			if legalMove.Equal(move) {
+				searchMoves = append(searchMoves, legalMove)
+				legal = true
+				break
+			}
+		}
+		if !legal {
+			h.logger.Error("ignoring illegal searchmoves move: " + moveToUCI(move))
+			h.respond("info string ignoring illegal search move " + moveToUCI(move))
+		}
+	}
+	return searchMoves
+}
+
+// firstLegalMove returns the first legal move of the position in UCI
+// notation, for a bestmove the search could not provide, or the null move
+// 0000 if the game is over.
+func firstLegalMove(chessBoard board.ChessBoard) string {
+	legalMoves := chessBoard.GenerateLegalMoves()
+	if len(legalMoves) == 0 {
+		return "0000"
+	}
+	return moveToUCI(legalMoves[0])
+}
+
 func parsePositionCommand(tokens []string) (string, []board.Move, error) {
 	if len(tokens) < 2 {
 		return "", nil, fmt.Errorf("expected at least 2 tokens")
@@ -133,7 +436,12 @@ func parseMoves(moveTokens []string) ([]board.Move, error) {
 	return moves, nil
 }
 
+// parseMove reads a move in UCI notation, whose fifth letter, if any,
+// names the piece a pawn promotes to. The board fills in the rest.
 func parseMove(moveToken string) (board.Move, error) {
+	if len(moveToken) != 4 && len(moveToken) != 5 {
+		return board.Move{}, fmt.Errorf("invalid move length: %s", moveToken)
+	}
 	from_file := int(moveToken[0] - 'a')
 	from_rank := int(moveToken[1] - '1')
 	to_file := int(moveToken[2] - 'a')
@@ -148,17 +456,62 @@ func parseMove(moveToken string) (board.Move, error) {
 		return board.Move{}, err
 	}
 
-	return board.Move{
+	move := board.Move{
 		From: from,
 		To:   to,
-	}, nil
+	}
+	if len(moveToken) == 5 {
+		var promotion board.PieceName
+		switch moveToken[4] {
+		case 'q':
+			promotion = board.Queen
+		case 'r':
+			promotion = board.Rook
+		case 'b':
+			promotion = board.Bishop
+		case 'n':
+			promotion = board.Knight
+		default:
+			return board.Move{}, fmt.Errorf("invalid promotion piece: %c", moveToken[4])
+		}
+		move.Promotion = &board.Piece{Name: promotion}
+	}
+
+	return move, nil
+}
+
+// formatInfo turns a completed search iteration into a UCI info line.
+func formatInfo(info search.SearchInfo) string {
+	score := fmt.Sprintf("cp %d", info.Score)
+	if search.IsMateScore(info.Score) {
+		score = fmt.Sprintf("mate %d", search.MateDistance(info.Score))
+	}
+
+	pv := make([]string, len(info.PV))
+	for i, move := range info.PV {
+		pv[i] = moveToUCI(move)
+	}
+
+	return fmt.Sprintf("info depth %d seldepth %d multipv %d score %s nodes %d nps %d hashfull %d time %d pv %s",
+		info.Depth, info.SelDepth, max(info.MultiPV, 1), score, info.Nodes, info.NPS(), info.HashFull, info.Time.Milliseconds(), strings.Join(pv, " "))
 }
 
+// formatRootMove turns the statistics of a root move into an info string
+// line.
+func formatRootMove(rootMove search.RootMoveInfo) string {
+	return fmt.Sprintf("info string %s visits %d winrate %.3f", moveToUCI(rootMove.Move), rootMove.Visits, rootMove.WinRate)
+}
+
+// moveToUCI writes move in UCI notation, the inverse of parseMove.
 func moveToUCI(move board.Move) string {
 	from_rank := move.From.Rank
 	from_file := move.From.File
 	to_rank := move.To.Rank
 	to_file := move.To.File
 
-	return fmt.Sprintf("%c%c%c%c", 'a'+from_file, '1'+from_rank, 'a'+to_file, '1'+to_rank)
+	uci := fmt.Sprintf("%c%c%c%c", 'a'+from_file, '1'+from_rank, 'a'+to_file, '1'+to_rank)
+	if move.Promotion != nil {
+		uci += strings.ToLower(string(move.Promotion.Name))
+	}
+	return uci
 }
@@ -0,0 +1,498 @@
+package uci
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	board "jesus_chess/domain/board"
+	logging "jesus_chess/domain/logging"
+	search "jesus_chess/domain/search"
+)
+
+func TestFormatInfo(t *testing.T) {
+	e2e4, _ := parseMove("e2e4")
+	e7e8q, _ := parseMove("e7e8q")
+
+	info := search.SearchInfo{
+		Depth:    5,
+		SelDepth: 7,
+		Score:    -35,
+		Nodes:    20000,
+		Time:     2 * time.Second,
+		HashFull: 12,
+		PV:       []board.Move{e2e4, e7e8q},
+	}
+	expected := "info depth 5 seldepth 7 multipv 1 score cp -35 nodes 20000 nps 10000 hashfull 12 time 2000 pv e2e4 e7e8q"
+	if got := formatInfo(info); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	info.Score = search.MateScore - 3
+	expected = "info depth 5 seldepth 7 multipv 1 score mate 2 nodes 20000 nps 10000 hashfull 12 time 2000 pv e2e4 e7e8q"
+	if got := formatInfo(info); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	info.Score = -search.MateScore + 4
+	expected = "info depth 5 seldepth 7 multipv 1 score mate -2 nodes 20000 nps 10000 hashfull 12 time 2000 pv e2e4 e7e8q"
+	if got := formatInfo(info); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	info.MultiPV = 3
+	expected = "info depth 5 seldepth 7 multipv 3 score mate -2 nodes 20000 nps 10000 hashfull 12 time 2000 pv e2e4 e7e8q"
+	if got := formatInfo(info); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestFormatRootMove(t *testing.T) {
+	e2e4, _ := parseMove("e2e4")
+	expected := "info string e2e4 visits 1200 winrate 0.541"
+	if got := formatRootMove(search.RootMoveInfo{Move: e2e4, Visits: 1200, WinRate: 0.5412}); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestParseMove(t *testing.T) {
+	move, err := parseMove("e7e8q")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if move.From.Rank != 6 || move.From.File != 4 || move.To.Rank != 7 || move.To.File != 4 {
+		t.Errorf("expected e7 to e8, got %+v", move)
+	}
+	if move.Promotion == nil || move.Promotion.Name != board.Queen {
+		t.Fatalf("expected a queen promotion, got %+v", move.Promotion)
+	}
+
+	for _, token := range []string{"e2e4", "e7e8q", "a2a1n", "h7g8r", "b7c8b"} {
+		move, err := parseMove(token)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", token, err)
+			continue
+		}
+		if got := moveToUCI(move); got != token {
+			t.Errorf("expected %s back, got %s", token, got)
+		}
+	}
+
+	for _, token := range []string{"e7e8k", "e7", "e7e8qq", "i7e8q"} {
+		if _, err := parseMove(token); err == nil {
+			t.Errorf("expected an error for %s", token)
+		}
+	}
+}
+
+func TestPositionAppliesPromotions(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	cb := board.NewArrayChessBoard(logger)
+	h := NewUCIHandler(logger, cb, search.NewAlphaBetaMoveFinder(logger, 1))
+	h.output = &bytes.Buffer{}
+	h.Handle("position fen 8/4P3/8/8/8/8/k7/4K3 w - - 0 1 moves e7e8n")
+	square, _ := board.NewSquare(7, 4)
+	if piece := cb.PieceAt(square); piece == nil || piece.Name != board.Knight || piece.Color != board.White {
+		t.Errorf("expected a white knight on e8, got %+v", piece)
+	}
+}
+
+func TestParseGoCommand(t *testing.T) {
+	limits, err := parseGoCommand(strings.Fields("go wtime 60000 btime 55000 winc 1000 binc 500 movestogo 20"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := search.SearchLimits{
+		WhiteTime:      60 * time.Second,
+		BlackTime:      55 * time.Second,
+		WhiteIncrement: time.Second,
+		BlackIncrement: 500 * time.Millisecond,
+		MovesToGo:      20,
+	}
+	if limits.WhiteTime != expected.WhiteTime || limits.BlackTime != expected.BlackTime ||
+		limits.WhiteIncrement != expected.WhiteIncrement || limits.BlackIncrement != expected.BlackIncrement ||
+		limits.MovesToGo != expected.MovesToGo {
+		t.Errorf("expected %+v, got %+v", expected, limits)
+	}
+
+	limits, err = parseGoCommand(strings.Fields("go depth 6 nodes 100000 movetime 250 mate 3"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limits.Depth != 6 || limits.Nodes != 100000 || limits.MoveTime != 250*time.Millisecond || limits.Mate != 3 {
+		t.Errorf("unexpected limits: %+v", limits)
+	}
+
+	limits, err = parseGoCommand(strings.Fields("go infinite"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !limits.Infinite {
+		t.Errorf("expected an infinite search")
+	}
+
+	limits, err = parseGoCommand(strings.Fields("go ponder wtime 1000 btime 1000"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !limits.Ponder || limits.WhiteTime != time.Second {
+		t.Errorf("expected a pondering search with a clock, got %+v", limits)
+	}
+
+	limits, err = parseGoCommand(strings.Fields("go searchmoves e2e4 d2d4 depth 3"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(limits.SearchMoves) != 2 || moveToUCI(limits.SearchMoves[1]) != "d2d4" || limits.Depth != 3 {
+		t.Errorf("expected two search moves and a depth, got %+v", limits)
+	}
+
+	limits, err = parseGoCommand(strings.Fields("go infinite searchmoves e7e8q"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(limits.SearchMoves) != 1 || moveToUCI(limits.SearchMoves[0]) != "e7e8q" || !limits.Infinite {
+		t.Errorf("expected a promotion as the only search move, got %+v", limits)
+	}
+
+	for _, command := range []string{"go depth", "go depth six", "go wtime -", "go sideways 3", "go searchmoves", "go searchmoves depth 3", "go searchmoves e2e4 wtime"} {
+		if _, err := parseGoCommand(strings.Fields(command)); err == nil {
+			t.Errorf("expected an error for %q", command)
+		}
+	}
+}
+
+func TestGoInfiniteStopsOnStop(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("go infinite")
+	time.Sleep(50 * time.Millisecond)
+	h.outputMutex.Lock()
+	early := output.String()
+	h.outputMutex.Unlock()
+	if strings.Contains(early, "bestmove") {
+		t.Fatalf("infinite search reported a best move before stop: %q", early)
+	}
+
+	h.Handle("stop")
+	if !strings.Contains(output.String(), "bestmove ") {
+		t.Errorf("expected a best move after stop, got %q", output.String())
+	}
+}
+
+func TestGoPonderSwitchesToTimedSearchOnPonderhit(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("go ponder wtime 1000 btime 1000")
+	// A timed search would have stopped by now, but the clock only starts
+	// on ponderhit
+	time.Sleep(200 * time.Millisecond)
+	h.outputMutex.Lock()
+	early := output.String()
+	h.outputMutex.Unlock()
+	if strings.Contains(early, "bestmove") {
+		t.Fatalf("pondering search reported a best move before ponderhit: %q", early)
+	}
+
+	h.Handle("ponderhit")
+	select {
+	case <-h.searchDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the search to stop on its own after ponderhit")
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if fields := strings.Fields(lines[len(lines)-1]); len(fields) != 4 || fields[0] != "bestmove" || fields[2] != "ponder" {
+		t.Errorf("expected bestmove with a ponder move, got %q", lines[len(lines)-1])
+	}
+}
+
+func TestGoPonderStopsOnStop(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("go ponder wtime 1000 btime 1000")
+	time.Sleep(50 * time.Millisecond)
+	h.Handle("stop")
+	if !strings.Contains(output.String(), "bestmove ") {
+		t.Errorf("expected a best move after stop, got %q", output.String())
+	}
+	// A ponderhit arriving after the search stopped is ignored
+	h.Handle("ponderhit")
+}
+
+func TestGoMateReportsTheMateOrItsAbsence(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	const opening = "position fen rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	tests := []struct {
+		position  string
+		goCommand string
+		expected  []string
+	}{
+		{"position fen 6k1/8/8/8/8/8/R7/1R4K1 w - - 0 1", "go mate 2", []string{"score mate 2", "pv b1b7 ", "bestmove b1b7 ponder "}},
+		{opening, "go mate 2", []string{"info string no mate found", "bestmove "}},
+		{opening, "go mate 5 nodes 100", []string{"info string mate search stopped", "bestmove "}},
+	}
+	for _, test := range tests {
+		output := &bytes.Buffer{}
+		h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+		h.output = output
+
+		h.Handle(test.position)
+		h.Handle(test.goCommand)
+		<-h.searchDone
+		for _, expected := range test.expected {
+			if !strings.Contains(output.String(), expected) {
+				t.Errorf("%s, %s: expected %q in %q", test.position, test.goCommand, expected, output.String())
+			}
+		}
+		if strings.Contains(output.String(), "bestmove 0000") {
+			t.Errorf("%s, %s: expected a legal best move, got %q", test.position, test.goCommand, output.String())
+		}
+	}
+}
+
+func TestParseSetOptionCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		name    string
+		value   string
+	}{
+		{"setoption name Move Overhead value 30", "Move Overhead", "30"},
+		{"setoption name Hash value 64", "Hash", "64"},
+		{"setoption name Clear Hash", "Clear Hash", ""},
+		{"setoption name UCI_Opponent value GM 2800 human Gary Kasparov", "UCI_Opponent", "GM 2800 human Gary Kasparov"},
+	}
+	for _, test := range tests {
+		name, value, err := parseSetOptionCommand(strings.Fields(test.command))
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", test.command, err)
+			continue
+		}
+		if name != test.name || value != test.value {
+			t.Errorf("expected %q = %q for %q, got %q = %q", test.name, test.value, test.command, name, value)
+		}
+	}
+
+	for _, command := range []string{"setoption", "setoption Hash 64", "setoption name value 3"} {
+		if _, _, err := parseSetOptionCommand(strings.Fields(command)); err == nil {
+			t.Errorf("expected an error for %q", command)
+		}
+	}
+}
+
+func TestUCIListsAndSetsOptions(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	finder := search.NewAlphaBetaMoveFinder(logger, 4)
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), finder)
+	h.output = output
+
+	h.Handle("uci")
+	for _, option := range []string{
+		"option name Hash type spin default 16 min 1 max 1024\n",
+		"option name Move Overhead type spin default 10 min 0 max 5000\n",
+		"option name Threads type spin default 1 min 1 max 64\n",
+		"option name MultiPV type spin default 1 min 1 max 256\n",
+		"option name Null Move Pruning type check default true\n",
+		"option name Aspiration Windows type check default true\n",
+		"option name Singular Extensions type check default true\n",
+		"option name Contempt type spin default 0 min -100 max 100\n",
+		"option name UCI_Opponent type string default <empty>\n",
+	} {
+		if !strings.Contains(output.String(), option) {
+			t.Errorf("expected %q, got %q", option, output.String())
+		}
+	}
+
+	h.Handle("setoption name move overhead value 250")
+	h.Handle("setoption name Hash value 1")
+	h.Handle("setoption name Threads value 2")
+	h.Handle("setoption name MultiPV value 3")
+	h.Handle("setoption name late move reductions value false")
+	if err := finder.SetOption("Razoring", "maybe"); err == nil {
+		t.Errorf("expected a malformed check value to be rejected")
+	}
+	if err := finder.SetOption("Move Overhead", "6000"); err == nil {
+		t.Errorf("expected an out of range value to be rejected")
+	}
+	if err := finder.SetOption("Move Overhead", "fast"); err == nil {
+		t.Errorf("expected a malformed value to be rejected")
+	}
+	if err := finder.SetOption("Ponder", "true"); err == nil {
+		t.Errorf("expected an unknown option to be rejected")
+	}
+}
+
+func TestFormatComboOption(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	options := search.NewRandomMoveFinder(logger, 9).Options()
+	expected := []string{
+		"option name Seed type spin default 9 min 0 max 2147483647",
+		"option name Policy type combo default Uniform var Uniform var Captures var Checks var Greedy",
+	}
+	for i, option := range options {
+		if got := formatOption(option); got != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], got)
+		}
+	}
+}
+
+func TestStatsReportsTheLastSearch(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("position startpos")
+	h.Handle("go depth 3")
+	h.Handle("stats")
+	for _, expected := range []string{"info string depth 1 nodes ", "info string depth 3 nodes "} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("expected %q in %q", expected, output.String())
+		}
+	}
+
+	output.Reset()
+	h.Handle("stats json")
+	var stats []search.SearchStats
+	if err := json.Unmarshal(output.Bytes(), &stats); err != nil {
+		t.Fatalf("expected a JSON dump, got %q: %v", output.String(), err)
+	}
+	if len(stats) != 3 || stats[2].Depth != 3 || stats[2].Nodes == 0 {
+		t.Errorf("expected the statistics of 3 iterations, got %+v", stats)
+	}
+}
+
+func TestGoSearchMovesRestrictsTheSearch(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("setoption name MultiPV value 3")
+	h.Handle("position fen 4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	h.Handle("go depth 3 searchmoves e1f1 d2d4")
+	<-h.searchDone
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if strings.Contains(line, " multipv 3 ") {
+			t.Errorf("expected two lines for two search moves, got %q", line)
+		}
+		if strings.HasPrefix(line, "info depth") && !strings.Contains(line, " pv e1f1") && !strings.Contains(line, " pv d2d4") {
+			t.Errorf("expected only the search moves reported, got %q", line)
+		}
+	}
+	if !strings.Contains(output.String(), "bestmove e1f1") && !strings.Contains(output.String(), "bestmove d2d4") {
+		t.Errorf("expected one of the search moves played, got %q", output.String())
+	}
+
+	output.Reset()
+	h.Handle("go depth 3 searchmoves d2d8 e1e2")
+	<-h.searchDone
+	if !strings.Contains(output.String(), "info string ignoring illegal search move d2d8") || !strings.Contains(output.String(), "bestmove e1e2") {
+		t.Errorf("expected the illegal search move dropped and the rest searched, got %q", output.String())
+	}
+
+	output.Reset()
+	h.Handle("go depth 1 searchmoves d2d8")
+	<-h.searchDone
+	if !strings.Contains(output.String(), "bestmove d2d5") {
+		t.Errorf("expected every move searched without a legal search move, got %q", output.String())
+	}
+}
+
+// TestGoDuringASearchStopsItFirst sends a go command with search moves,
+// which are checked on the board, while a search is running on it.
+func TestGoDuringASearchStopsItFirst(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("go infinite")
+	time.Sleep(20 * time.Millisecond)
+	h.Handle("go infinite searchmoves e2e4")
+	h.Handle("stop")
+	h.outputMutex.Lock()
+	defer h.outputMutex.Unlock()
+	if bestMoves := strings.Count(output.String(), "bestmove"); bestMoves != 2 || !strings.Contains(output.String(), "bestmove e2e4") {
+		t.Errorf("expected both searches to answer, the second with e2e4, got %q", output.String())
+	}
+}
+
+func TestGoWithAnInvalidCommandStillAnswers(t *testing.T) {
+	logger, err := logging.NewLogger("test.log")
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	output := &bytes.Buffer{}
+	h := NewUCIHandler(logger, board.NewArrayChessBoard(logger), search.NewAlphaBetaMoveFinder(logger, 4))
+	h.output = output
+
+	h.Handle("position fen 4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	h.Handle("go depth x")
+	if !strings.HasPrefix(output.String(), "bestmove ") || strings.Contains(output.String(), "0000") {
+		t.Errorf("expected a legal best move, got %q", output.String())
+	}
+}